@@ -0,0 +1,75 @@
+package ctree
+
+import "time"
+
+// AgeBucket is a range of ages, by time since last modification
+type AgeBucket int
+
+const (
+	// LastDay is modified within the last 24 hours, or in the future
+	LastDay AgeBucket = iota
+	// LastWeek is modified within the last 7 days
+	LastWeek
+	// LastMonth is modified within the last 30 days
+	LastMonth
+	// LastYear is modified within the last 365 days
+	LastYear
+	// Older is everything else
+	Older
+
+	numAgeBuckets
+)
+
+const day = 24 * time.Hour
+
+var ageLimits = [...]time.Duration{
+	LastDay:   day,
+	LastWeek:  7 * day,
+	LastMonth: 30 * day,
+	LastYear:  365 * day,
+}
+
+func (b AgeBucket) String() string {
+	switch b {
+	case LastDay:
+		return "last day"
+	case LastWeek:
+		return "last week"
+	case LastMonth:
+		return "last month"
+	case LastYear:
+		return "last year"
+	case Older:
+		return "older"
+	}
+	return "unknown"
+}
+
+// AgeOf returns the bucket for something last modified age ago
+func AgeOf(age time.Duration) AgeBucket {
+	for b, limit := range ageLimits {
+		if age < limit {
+			return AgeBucket(b)
+		}
+	}
+	return Older
+}
+
+// AgeReport totals the leaves of a tree in each AgeBucket
+type AgeReport [numAgeBuckets]Usage
+
+// Ages totals the leaves beneath dn by how long before now they were
+// last modified, in one pass over the tree
+func Ages(dn *DNode, now time.Time) AgeReport {
+	var report AgeReport
+
+	dn.walk(func(_ string, n Node) bool {
+		if l, ok := n.(*Leaf); ok {
+			age := now.Sub((*l.info).ModTime())
+			report[AgeOf(age)].add(l)
+		}
+		return true
+	})
+
+	return report
+}
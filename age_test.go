@@ -0,0 +1,39 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAges(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	touch := func(where string, age time.Duration) {
+		then := now.Add(-age)
+		require.NoError(t, os.Chtimes(where, then, then))
+	}
+
+	dn := scanCopy(t, func(where string) {
+		touch(path.Join(where, "ceswift", ".cshrc"), time.Hour)
+		touch(path.Join(where, "ceswift", "bin", "worms"), 3*day)
+		touch(path.Join(where, "wsfitzpa", ".cshrc"), 100*day)
+		// wsfitzpa/bin/zrun keeps the epoch time
+	})
+
+	report := Ages(dn, now)
+	assert.Equal(Usage{Files: 1, Bytes: 14}, report[LastDay])
+	assert.Equal(Usage{Files: 1, Bytes: 10}, report[LastWeek])
+	assert.Equal(Usage{}, report[LastMonth])
+	assert.Equal(Usage{Files: 1, Bytes: 20}, report[LastYear])
+	assert.Equal(Usage{Files: 1, Bytes: 18}, report[Older])
+
+	assert.Equal(LastDay, AgeOf(-time.Hour))
+	assert.Equal(LastMonth, AgeOf(8*day))
+	assert.Equal("last week", LastWeek.String())
+}
@@ -0,0 +1,158 @@
+package ctree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Usage totals the leaves in part of a tree
+type Usage struct {
+	Files int64
+	Bytes int64
+}
+
+func (u *Usage) add(l *Leaf) {
+	u.Files++
+	u.Bytes += (*l.info).Size()
+}
+
+// Usage totals the leaves beneath dn
+func (dn *DNode) Usage() Usage {
+	var u Usage
+	dn.walk(func(_ string, n Node) bool {
+		if l, ok := n.(*Leaf); ok {
+			u.add(l)
+		}
+		return true
+	})
+	return u
+}
+
+// Aggregate merges trees scanned on different hosts, usually read from
+// snapshots, into one tree. The result has a directory for each host,
+// named by the Hostname in its ScanInfo, holding a copy of its tree; so
+// a path "p" in a tree from host "h" becomes "h/p" in the aggregate. Each
+// host's directory keeps the ScanInfo of its tree, while the aggregate
+// itself has none.
+func Aggregate(trees ...*DNode) (*DNode, error) {
+	var fi os.FileInfo = &nodeInfo{
+		name:    ".",
+		mode:    os.ModeDir | 0555,
+		modTime: time.Now(),
+	}
+	agg := newNode(".", &fi).(*DNode)
+
+	for _, dn := range trees {
+		si := dn.ScanInfo()
+		if si == nil || si.Hostname == "" {
+			return nil, fmt.Errorf("%q: tree has no hostname", dn.Path())
+		}
+		host := si.Hostname
+		if strings.Contains(host, "/") || host == "." || host == ".." {
+			return nil, fmt.Errorf("%q: bad hostname", host)
+		}
+		for _, other := range agg.children {
+			if other.name == host {
+				return nil, fmt.Errorf("%q: %w", host, ErrDuplicateHost)
+			}
+		}
+
+		hdn := rebase(dn, host, agg)
+		hdn.name = host
+		hdn.scan = si
+		agg.children = append(agg.children, hdn)
+	}
+
+	sort.Slice(agg.children, func(i, j int) bool {
+		return agg.children[i].name < agg.children[j].name
+	})
+
+	return agg, nil
+}
+
+// ErrDuplicateHost is returned by Aggregate when two trees come from the
+// same host
+var ErrDuplicateHost = errors.New("host appears more than once")
+
+// rebase copies the tree at dn to p, beneath parent
+func rebase(dn *DNode, p string, parent *DNode) *DNode {
+	cp := &DNode{
+		name:   dn.name,
+		path:   p,
+		parent: parent,
+		info:   dn.info,
+		err:    dn.err,
+		tags:   dn.tags,
+	}
+
+	for _, leaf := range dn.leaves {
+		cp.leaves = append(cp.leaves, &Leaf{
+			name:        leaf.name,
+			path:        path.Join(p, leaf.name),
+			parent:      cp,
+			info:        leaf.info,
+			hashes:      leaf.hashes,
+			tags:        leaf.tags,
+			annotations: leaf.annotations,
+		})
+	}
+	for _, child := range dn.children {
+		cp.children = append(cp.children,
+			rebase(child, path.Join(p, child.name), cp))
+	}
+
+	return cp
+}
+
+// UsageByHost totals the leaves beneath each host of a tree made by
+// Aggregate
+func UsageByHost(agg *DNode) map[string]Usage {
+	usage := map[string]Usage{}
+
+	for _, host := range agg.children {
+		usage[host.name] = host.Usage()
+	}
+
+	return usage
+}
+
+// SameContent finds leaves with the same content on more than one host of
+// a tree made by Aggregate. Content is identified by key, such as a hash
+// of the leaf's data; leaves for which key returns "" are ignored. The
+// result maps each key found on several hosts to its leaves.
+func SameContent(agg *DNode, key func(*Leaf) string) map[string][]*Leaf {
+	byKey := map[string][]*Leaf{}
+	hosts := map[string]map[string]bool{}
+
+	for _, host := range agg.children {
+		host.walk(func(_ string, n Node) bool {
+			l, ok := n.(*Leaf)
+			if !ok {
+				return true
+			}
+			k := key(l)
+			if k == "" {
+				return true
+			}
+			byKey[k] = append(byKey[k], l)
+			if hosts[k] == nil {
+				hosts[k] = map[string]bool{}
+			}
+			hosts[k][host.name] = true
+			return true
+		})
+	}
+
+	for k := range byKey {
+		if len(hosts[k]) < 2 {
+			delete(byKey, k)
+		}
+	}
+
+	return byKey
+}
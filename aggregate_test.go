@@ -0,0 +1,86 @@
+package ctree
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	scanHost := func(host string, edit func(string)) *DNode {
+		dn := scanCopy(t, edit)
+		dn.scan.Hostname = host
+		return dn
+	}
+
+	alpha := scanHost("alpha", nil)
+	beta := scanHost("beta", func(where string) {
+		writeFile(t, path.Join(where, "ceswift", "bin", "worms"), "different")
+		writeFile(t, path.Join(where, "extra"), "0123456789")
+	})
+
+	t.Run("hosts are namespaced", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		agg, err := Aggregate(beta, alpha)
+		require.NoError(err)
+		require.Len(agg.children, 2)
+		assert.Equal("alpha", agg.children[0].name)
+		assert.Equal("alpha", agg.children[0].ScanInfo().Hostname)
+		assert.Nil(agg.ScanInfo())
+		assert.Nil(agg.Lookup("beta/wsfitzpa").(*DNode).ScanInfo())
+		assert.Equal(alpha.TotalLength()+beta.TotalLength()+1, agg.TotalLength())
+
+		zrun := agg.Lookup("beta/wsfitzpa/bin/zrun")
+		require.NotNil(zrun)
+		assert.Equal("beta/wsfitzpa/bin/zrun", zrun.Path())
+
+		// the originals are untouched
+		assert.Equal(alpha.Path(), alpha.children[0].parent.Path())
+	})
+
+	t.Run("usage by host", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		agg, err := Aggregate(alpha, beta)
+		require.NoError(err)
+		usage := UsageByHost(agg)
+		assert.Equal(Usage{Files: 4, Bytes: 62}, usage["alpha"])
+		assert.Equal(Usage{Files: 5, Bytes: 71}, usage["beta"])
+	})
+
+	t.Run("same content", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		agg, err := Aggregate(alpha, beta)
+		require.NoError(err)
+
+		// stand in for a content hash
+		key := func(l *Leaf) string {
+			return fmt.Sprintf("%s/%d", l.name, (*l.Info()).Size())
+		}
+		same := SameContent(agg, key)
+		assert.Len(same, 3)
+		assert.NotContains(same, "worms/10")
+		assert.NotContains(same, "extra/10")
+		require.Contains(same, "zrun/18")
+		assert.Len(same["zrun/18"], 2)
+	})
+
+	t.Run("hosts must be known and distinct", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := Aggregate(alpha, scanHost("alpha", nil))
+		assert.ErrorIs(err, ErrDuplicateHost)
+		_, err = Aggregate(scanHost("", nil))
+		assert.Error(err)
+		_, err = Aggregate(scanHost("a/b", nil))
+		assert.Error(err)
+	})
+}
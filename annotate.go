@@ -0,0 +1,46 @@
+package ctree
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Annotate attaches v to the leaf under name, replacing anything there, so
+// that Processors can record what they find. v should marshal as JSON,
+// since that is how it is kept in snapshots.
+func (l *Leaf) Annotate(name string, v any) {
+	if l.annotations == nil {
+		l.annotations = map[string]any{}
+	}
+	l.annotations[name] = v
+}
+
+// Annotation stores the annotation named name in v, a pointer to the type
+// it was made with, returning false if the leaf has no such annotation.
+// Annotations read from snapshots are JSON until decoded this way.
+func (l *Leaf) Annotation(name string, v any) (bool, error) {
+	a, ok := l.annotations[name]
+	if !ok {
+		return false, nil
+	}
+
+	raw, ok := a.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(a); err != nil {
+			return true, err
+		}
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// Annotations returns the names of the leaf's annotations, sorted
+func (l *Leaf) Annotations() []string {
+	names := make([]string, 0, len(l.annotations))
+	for name := range l.annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
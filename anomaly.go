@@ -0,0 +1,187 @@
+package ctree
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AnomalyKind describes why a directory looks wrong
+type AnomalyKind int
+
+const (
+	// ManyEntries means the directory holds more entries than
+	// AnomalyOptions.MaxEntries
+	ManyEntries AnomalyKind = iota + 1
+	// SuddenGrowth means the directory holds many more entries than it
+	// did in AnomalyOptions.Previous
+	SuddenGrowth
+	// DeepNesting means the directory is deeper than
+	// AnomalyOptions.MaxDepth
+	DeepNesting
+	// RansomNames means the directory holds files named as ransomware
+	// names what it encrypts, or its ransom notes
+	RansomNames
+)
+
+func (k AnomalyKind) String() string {
+	switch k {
+	case ManyEntries:
+		return "many entries"
+	case SuddenGrowth:
+		return "sudden growth"
+	case DeepNesting:
+		return "deep nesting"
+	case RansomNames:
+		return "ransom names"
+	}
+	return "unknown"
+}
+
+// Anomaly describes a directory which looks wrong. Path is relative to
+// the root of the tree.
+type Anomaly struct {
+	Path    string
+	Kind    AnomalyKind
+	Message string
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s: %s: %s", a.Kind, a.Path, a.Message)
+}
+
+// The defaults of AnomalyOptions
+const (
+	DefaultAnomalyEntries = 10000
+	DefaultAnomalyDepth   = 32
+	DefaultGrowthFactor   = 10
+	DefaultGrowthEntries  = 1000
+)
+
+// DefaultRansomPatterns match, in lower case, the names of files
+// encrypted by well known ransomware, and of the notes it leaves
+var DefaultRansomPatterns = []string{
+	"*.locked", "*.encrypted", "*.crypted", "*.crypt", "*.cry",
+	"*.wncry", "*.wcry", "*.wnry", "*.locky", "*.zepto", "*.odin",
+	"*.thor", "*.aesir", "*.cerber", "*.cerber3", "*.crypz", "*.cryp1",
+	"*.ryk", "*.ryuk", "*.conti", "*.lockbit", "*.djvu", "*.stop",
+	"*.phobos", "*.dharma", "*.petya",
+	"how_to_decrypt*", "how-to-decrypt*", "*decrypt_instructions*",
+	"*decrypt-instructions*", "readme_for_decrypt*", "*restore_files*",
+	"*restore-my-files*", "_readme.txt",
+}
+
+// AnomalyOptions controls Anomalies
+type AnomalyOptions struct {
+	// MaxEntries is how many entries a directory may hold;
+	// DefaultAnomalyEntries if zero
+	MaxEntries int
+	// MaxDepth is how deep beneath the root a directory may be;
+	// DefaultAnomalyDepth if zero
+	MaxDepth int
+
+	// Previous, if set, is an earlier scan of the tree, against which
+	// directories which have grown by GrowthFactor times, and by at
+	// least GrowthEntries entries, are reported; DefaultGrowthFactor
+	// and DefaultGrowthEntries if zero
+	Previous      *DNode
+	GrowthFactor  float64
+	GrowthEntries int
+
+	// RansomPatterns match the lower case names of files left by
+	// ransomware, as path.Match does; DefaultRansomPatterns if nil
+	RansomPatterns []string
+}
+
+// Anomalies looks for directories beneath dn, and dn itself, which look
+// wrong: those with very many entries, or many more than before, those
+// nested very deep, and those whose files look to have been encrypted by
+// ransomware. The anomalies are sorted by path. They are hints for
+// security and hygiene monitoring, not proof of anything.
+func Anomalies(dn *DNode, opts *AnomalyOptions) []Anomaly {
+	if opts == nil {
+		opts = &AnomalyOptions{}
+	}
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultAnomalyEntries
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultAnomalyDepth
+	}
+	factor := opts.GrowthFactor
+	if factor <= 0 {
+		factor = DefaultGrowthFactor
+	}
+	growth := opts.GrowthEntries
+	if growth <= 0 {
+		growth = DefaultGrowthEntries
+	}
+	patterns := opts.RansomPatterns
+	if patterns == nil {
+		patterns = DefaultRansomPatterns
+	}
+
+	anomalies := []Anomaly{}
+	report := func(rel string, kind AnomalyKind, format string, args ...any) {
+		anomalies = append(anomalies, Anomaly{Path: rel, Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var visit func(dn, prev *DNode, rel string, depth int)
+	visit = func(dn, prev *DNode, rel string, depth int) {
+		if depth > maxDepth {
+			// only the first too deep, not everything beneath it
+			report(rel, DeepNesting, "%d levels deep", depth)
+			return
+		}
+
+		entries := len(dn.leaves) + len(dn.children)
+		if entries > maxEntries {
+			report(rel, ManyEntries, "%d entries", entries)
+		}
+		if prev != nil {
+			before := len(prev.leaves) + len(prev.children)
+			if entries-before >= growth && float64(entries) >= factor*float64(before) {
+				report(rel, SuddenGrowth, "%d entries, up from %d", entries, before)
+			}
+		}
+
+		var ransom []string
+		for _, l := range dn.leaves {
+			name := strings.ToLower(l.name)
+			for _, p := range patterns {
+				if ok, _ := path.Match(p, name); ok {
+					ransom = append(ransom, l.name)
+					break
+				}
+			}
+		}
+		if len(ransom) > 0 {
+			sort.Strings(ransom)
+			report(rel, RansomNames, "%d files named like ransomware's, such as %q", len(ransom), ransom[0])
+		}
+
+		var prevChildren map[string]*DNode
+		if prev != nil {
+			prevChildren = make(map[string]*DNode, len(prev.children))
+			for _, c := range prev.children {
+				prevChildren[c.name] = c
+			}
+		}
+		for _, child := range dn.children {
+			visit(child, prevChildren[child.name], path.Join(rel, child.name), depth+1)
+		}
+	}
+	visit(dn, opts.Previous, ".", 0)
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].Path != anomalies[j].Path {
+			return anomalies[i].Path < anomalies[j].Path
+		}
+		return anomalies[i].Kind < anomalies[j].Kind
+	})
+
+	return anomalies
+}
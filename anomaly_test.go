@@ -0,0 +1,68 @@
+package ctree
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnomalies(t *testing.T) {
+	scan := func(mfs fstest.MapFS) *DNode {
+		r := NewRoot(".")
+		r.FS = mfs
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	before := fstest.MapFS{
+		"busy/0":      {},
+		"busy/1":      {},
+		"src/main.go": {},
+	}
+	after := fstest.MapFS{
+		"src/main.go":                {},
+		"a/b/c/d/e/f/deep":           {},
+		"docs/report.docx.locked":    {},
+		"docs/HOW_TO_DECRYPT.txt":    {},
+		"docs/notes.txt":             {},
+		"docs/photo.JPG.ENCRYPTED":   {},
+		"locks/package-lock.json":    {},
+		"locks/locked-out/README.md": {},
+	}
+	for i := 0; i < 30; i++ {
+		after[fmt.Sprintf("busy/%d", i)] = &fstest.MapFile{}
+	}
+
+	old, cur := scan(before), scan(after)
+	anomalies := Anomalies(cur, &AnomalyOptions{
+		MaxEntries:    20,
+		MaxDepth:      3,
+		Previous:      old,
+		GrowthEntries: 10,
+	})
+
+	assert.Equal(t, []Anomaly{
+		{Path: "a/b/c/d", Kind: DeepNesting, Message: "4 levels deep"},
+		{Path: "busy", Kind: ManyEntries, Message: "30 entries"},
+		{Path: "busy", Kind: SuddenGrowth, Message: "30 entries, up from 2"},
+		{Path: "docs", Kind: RansomNames, Message: `3 files named like ransomware's, such as "HOW_TO_DECRYPT.txt"`},
+	}, anomalies)
+	assert.Equal(t, "deep nesting: a/b/c/d: 4 levels deep", anomalies[0].String())
+
+	t.Run("defaults", func(t *testing.T) {
+		anomalies := Anomalies(cur, nil)
+		require.Len(t, anomalies, 1)
+		assert.Equal(t, RansomNames, anomalies[0].Kind)
+	})
+
+	t.Run("growth needs both factor and entries", func(t *testing.T) {
+		anomalies := Anomalies(cur, &AnomalyOptions{Previous: old, GrowthEntries: 10, GrowthFactor: 20})
+		assert.Len(t, anomalies, 1)
+		anomalies = Anomalies(cur, &AnomalyOptions{Previous: old, GrowthEntries: 50})
+		assert.Len(t, anomalies, 1)
+	})
+}
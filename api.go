@@ -1,102 +1,353 @@
 package ctree
 
 import (
-	"fmt"
-	"os"
+	"errors"
+	"io"
+	"io/fs"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
-	// DefaultThreads is how many threads are assigned by default
+	// DefaultThreads is how many threads are assigned when there is no
+	// better guess; see DefaultThreadsFor
 	DefaultThreads = 4
 	// DefaultWorkListSize is how many directory nodes can be enqueued for
 	// worker threads to process
 	DefaultWorkListSize = 1024
+	// MaxAutoWorkListSize is the largest that AutoTune will grow
+	// WorkListSize
+	MaxAutoWorkListSize = 1 << 20
+	// AutoTuneThreshold is the fraction of directories walked inline,
+	// because the work list was full, above which AutoTune grows
+	// WorkListSize
+	AutoTuneThreshold = 0.1
 )
 
 type workStream chan *DNode
 type stopStream chan struct{}
 
-// Root is the root of a directory tree to be walked
+// Root is the root of a directory tree to be walked. A Root may be Run
+// any number of times, each Run walking the tree afresh with the Root's
+// settings at the time, but only one Run at a time; see Running.
+//
+// The paths of the nodes of the tree are separated by "/" whatever the
+// host, as are those of an fs.FS, so that snapshots are portable; see
+// LocalPath.
 type Root struct {
-	Path         string
+	Path string
+	// Threads is how many workers walk the tree. With just one, the
+	// walk is sequential: it recurses through the tree in order of name
+	// on the goroutine calling Run, hashing every file itself, whatever
+	// HashThreads says, so that it is deterministic and cheap for small
+	// trees and debugging.
 	Threads      int
 	WorkListSize int
 
+	// FS, if set, is walked instead of the operating system's
+	// filesystem, and Path is a path within it
+	FS fs.FS
+
+	// Backend, if set, is walked instead of FS or the operating system's
+	// filesystem, and Path is a path within it. What only the operating
+	// system can tell, such as BirthTimes and Attributes, is only
+	// recorded when walking an OSBackend.
+	Backend Backend
+
+	// Budgets, if set, limits how many workers may walk the subtrees
+	// beneath the named directories, which must be directly beneath
+	// Path, so that a slow subtree cannot tie up every worker
+	Budgets map[string]int
+
+	// LowPriority, if set, runs the workers at idle CPU and I/O
+	// priority, where the platform supports it, so that background
+	// scans don't slow down other work
+	LowPriority bool
+
+	// AutoTune, if set, doubles WorkListSize after any Run where too
+	// many directories were walked inline because the work list was full
+	AutoTune bool
+
+	// Hashes, if set, are computed for every regular file, reading
+	// each file once however many there are; see Leaf.Hash
+	Hashes []Hasher
+
+	// HashThreads is how many goroutines hash files of LargeFileSize
+	// or more, alongside the workers walking the tree, so that large
+	// files are hashed in parallel; Threads if zero
+	HashThreads int
+
+	// ReadMethod is how files are read to hash them
+	ReadMethod ReadMethod
+
+	// MaxDepth, if set, is how many levels beneath Path are read.
+	// Directories deeper than that are recorded, but not read, and
+	// have an error matching ErrTooDeep.
+	MaxDepth int
+
+	// Files and directories which are removed between being listed and
+	// being read are counted in Stats.Vanished, and otherwise ignored,
+	// so that scans of busy filesystems don't report errors for them.
+	// RecordVanished also keeps their paths; see Vanished.
+	// VanishedErrors records them as errors matching ErrVanished.
+	RecordVanished bool
+	VanishedErrors bool
+
+	// StaleRetries is how many times reading a directory or a file's
+	// details is retried, when it fails with a stale file handle, as
+	// NFS clients do when their server fails over. Each retry looks the
+	// path up again from Path. DefaultStaleRetries if zero; no retries
+	// if negative.
+	StaleRetries int
+
+	// IncludePseudoFS, if set, walks the pseudo filesystems beneath
+	// Path, such as /proc, /sys and /dev, which are otherwise left
+	// empty, since walking them yields meaningless data and can hang.
+	// Path itself is always walked.
+	IncludePseudoFS bool
+
+	// BirthTimes, if set, records when each file was created on
+	// platforms where that takes another system call per node, such as
+	// Linux. Elsewhere, birth times are recorded whenever the platform
+	// gives them.
+	BirthTimes bool
+
+	// Attributes, if set, records the attributes of each file, such as
+	// whether it is immutable or compressed, on platforms where that
+	// takes more system calls per node, such as Linux; see Attributes.
+	// Elsewhere, they are recorded whenever stat gives them.
+	Attributes bool
+
+	// QuickHash, if set, gives every regular file a cheap digest
+	// named QuickHashName, of its size and its first and last
+	// QuickHash bytes; see Duplicates
+	QuickHash int64
+
+	// Pool, if set, bounds the workers and open files of this Root
+	// together with every other Root sharing it
+	Pool *Pool
+
+	// Priority decides which Roots sharing a Pool get its workers
+	// first, so that interactive scans need not wait behind background
+	// ones
+	Priority Priority
+
+	// MaxNodes and MaxBytes, if set, stop the walk once it has found
+	// that many files and directories, or files totalling that many
+	// bytes, so that pathological trees can't exhaust a service. What
+	// was found before then is kept; directories not read, or not read
+	// to the end, have errors matching ErrLimitReached, and the tree's
+	// ScanInfo has LimitReached set.
+	MaxNodes int
+	MaxBytes int64
+
+	// Classify names the registered classifiers to run on every regular
+	// file, tagging it with what they find; see RegisterClassifier
+	Classify []string
+
+	// Processors read the contents of every regular file they want,
+	// in the workers, after Classify
+	Processors []Processor
+
+	// Journal, if set, has each directory written to it, with its
+	// leaves, as soon as everything beneath it is finished, one JSON
+	// object per line, so that a scan which is killed still leaves what
+	// it finished behind; see ReadJournal. Each line is written with one
+	// call to Write.
+	Journal io.Writer
+
+	// Sinks, if set, are each given every directory, with its leaves,
+	// as soon as everything beneath it is finished, as Journal is; see
+	// Sink
+	Sinks []Sink
+
+	// Git, if set, gives every file in a git work tree beneath Path a
+	// GitStatus, once the walk is done, from the repository's index and
+	// ignore files; see GitStatusOf and GitLeaves
+	Git bool
+
+	// Compact, if set, compacts the tree that Run returns; see
+	// DNode.Compact
+	Compact *CompactOptions
+
+	// Audit, if set, is called with the path of everything the walk
+	// reads from the filesystem, and how: OpStat, OpReadDir, or OpOpen.
+	// It is called from many goroutines at once.
+	Audit func(op, path string)
+
+	// ReadOnly, if set, keeps the walk from changing even the access
+	// times of what it reads, by opening files and directories with
+	// O_NOATIME, where the platform has it. The operating system only
+	// allows that for the owner of a file, or a process with
+	// CAP_FOWNER; other files and directories are not read, with errors
+	// matching ErrAccessTime. Whether or not it is set, ctree never
+	// writes to the filesystems it walks.
+	ReadOnly bool
+
+	root    string // Path, "/"-separated
+	fsys    Backend
 	work    workStream
 	stop    stopStream
 	pending int32
 	wg      sync.WaitGroup
+	stats   Stats
+	budgets map[string]chan struct{}
+
+	classifiers []Classifier
+
+	hashWork    chan *Leaf
+	hashWG      sync.WaitGroup
+	hashThreads int
+	errMu       sync.Mutex // guards DNode errors set by hashing, and vanished
+	vanished    []string
+
+	journalMu  sync.Mutex
+	journalErr error
+	sinks      []*sinkState
+
+	visitedMu sync.Mutex
+	visited   map[fileID]string // the path of each directory walked
+
+	nodes, bytes int64 // found, for MaxNodes and MaxBytes
+	limited      int32
+
+	counters usageCounters
+	usageMu  sync.Mutex
+	usage    ResourceUsage
+
+	running int32
 }
 
 // NewRoot creates a Root node
 func NewRoot(path string) *Root {
 	return &Root{
 		Path:         path,
-		Threads:      DefaultThreads,
+		Threads:      DefaultThreadsFor(path),
 		WorkListSize: DefaultWorkListSize,
 	}
 }
 
-// Run walks the directory tree at the Root, returning a DNode
+// ErrRunning is returned by Run when the Root is already running
+var ErrRunning = errors.New("root is already running")
+
+// Running reports whether a Run of the Root is under way
+func (r *Root) Running() bool {
+	return atomic.LoadInt32(&r.running) != 0
+}
+
+// Run walks the directory tree at the Root, returning a DNode. If the
+// walk succeeds, but writing its Journal, feeding its Sinks, or reading
+// git repositories fails, the tree is returned with the error.
 func (r *Root) Run() (*DNode, error) {
-	r.setup()
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 1) {
+		return nil, ErrRunning
+	}
+	defer atomic.StoreInt32(&r.running, 0)
 
-	fi, err := os.Stat(r.Path)
-	if err != nil {
+	if err := r.setup(); err != nil {
 		return nil, err
 	}
-	if !fi.IsDir() {
-		return nil, fmt.Errorf("%q: not a directory", r.Path)
-	}
-	dn := newNode(r.Path, &fi).(*DNode)
+	scan := newScanInfo(r)
+	before := r.startUsage()
+	defer r.endUsage(scan.Start, before)
+
+	fi, err := r.stat(r.root)
 	if err != nil {
 		return nil, err
 	}
-
-	for i := 0; i < r.Threads; i++ {
-		r.wg.Add(1)
-		go r.allWork()
+	if !fi.IsDir() {
+		return nil, &NodeError{Path: r.root, Kind: ErrNotDir}
 	}
+	fi = r.withBirthTime(r.root, fi, true)
+	fi = r.withAttributes(r.root, fi)
+	dn := newNode(r.root, &fi).(*DNode)
+	dn.scan = scan
+	r.startJournal(scan)
+	r.startSinks()
 
-	r.work <- dn
+	if r.sequential() {
+		r.walkSequential(dn)
+	} else {
+		r.walkParallel(dn)
+	}
+	scan.End = time.Now()
+	scan.LimitReached = r.limitReached()
+	r.autoTune()
 
-	r.wg.Wait()
+	err = errors.Join(r.journalErr, r.sinkErr())
+	if r.Git {
+		err = errors.Join(err, r.annotateGit(dn))
+	}
+	if r.Compact != nil {
+		dn.Compact(r.Compact)
+	}
 
-	return dn, nil
+	return dn, err
 }
 
-func (r *Root) allWork() {
-	var dn *DNode
-
-	defer r.wg.Done()
-
-	for {
-		select {
-		case <-r.stop:
-			return
-		case dn = <-r.work:
-			dn.work(r.work, r.stop, &r.pending)
-			remaining := atomic.AddInt32(&r.pending, -1)
-			if remaining < 1 {
-				close(r.stop)
-				return
-			}
-		}
+func (r *Root) setup() error {
+	classifiers, err := lookupClassifiers(r.Classify)
+	if err != nil {
+		return err
 	}
-}
+	r.classifiers = classifiers
+	r.fsys = r.backend()
 
-func (r *Root) setup() {
 	if r.Threads <= 0 {
 		r.Threads = DefaultThreads
+		if r.onOS() {
+			r.Threads = DefaultThreadsFor(r.Path)
+		}
 	}
 
 	if r.WorkListSize < 0 {
 		r.WorkListSize = DefaultWorkListSize
 	}
 
-	r.work = make(workStream, r.WorkListSize)
-	r.stop = make(stopStream)
+	// the paths of the tree are "/"-separated whatever the host; the
+	// operating system accepts them too
+	r.root = r.Path
+	if r.onOS() {
+		r.root = slash(r.Path)
+	}
+
+	r.work, r.stop, r.hashWork = nil, nil, nil
+	if !r.sequential() {
+		r.work = make(workStream, r.WorkListSize)
+		r.stop = make(stopStream)
+		r.hashWork = make(chan *Leaf, r.WorkListSize)
+	}
 	r.pending = 1
+	r.stats.reset()
+
+	r.visitedMu.Lock()
+	r.visited = map[fileID]string{}
+	r.visitedMu.Unlock()
+
+	atomic.StoreInt64(&r.nodes, 1) // the root
+	atomic.StoreInt64(&r.bytes, 0)
+	atomic.StoreInt32(&r.limited, 0)
+
+	r.errMu.Lock()
+	r.vanished = nil
+	r.errMu.Unlock()
+
+	r.hashThreads = r.HashThreads
+	if r.hashThreads <= 0 {
+		r.hashThreads = r.Threads
+	}
+	if len(r.Hashes) == 0 || r.sequential() {
+		r.hashThreads = 0
+	}
+
+	r.budgets = map[string]chan struct{}{}
+	for name, n := range r.Budgets {
+		if n < 1 {
+			n = 1
+		}
+		r.budgets[name] = make(chan struct{}, n)
+	}
+
+	return nil
 }
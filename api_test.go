@@ -0,0 +1,62 @@
+package ctree
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAgain(t *testing.T) {
+	t.Run("each run starts afresh", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		r.HashThreads = 3
+		r.RecordVanished = true
+
+		first, err := r.Run()
+		require.NoError(err)
+		stats := r.Stats()
+
+		r.Hashes = []Hasher{SHA256}
+		second, err := r.Run()
+		require.NoError(err)
+
+		assert.NotSame(first, second)
+		assert.Empty(Diff(first, second))
+		assert.Equal(stats, r.Stats())
+		assert.Equal(3, r.HashThreads)
+		assert.NotNil(second.Lookup("ceswift/.cshrc").(*Leaf).Hash("sha256"))
+		assert.Nil(first.Lookup("ceswift/.cshrc").(*Leaf).Hash("sha256"))
+	})
+
+	t.Run("one run at a time", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = &FaultFS{FS: tfs, Faults: []Fault{
+			{Pattern: "home/*", Ops: []string{OpReadDir}, Delay: 100 * time.Millisecond},
+		}}
+		assert.False(r.Running())
+
+		done := make(chan error)
+		go func() {
+			_, err := r.Run()
+			done <- err
+		}()
+
+		require.Eventually(r.Running, time.Second, time.Millisecond)
+		_, err := r.Run()
+		assert.ErrorIs(err, ErrRunning)
+
+		require.NoError(<-done)
+		assert.False(r.Running())
+		_, err = r.Run()
+		assert.NoError(err)
+	})
+}
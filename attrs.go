@@ -0,0 +1,100 @@
+package ctree
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// FileAttr is a set of the attributes that filesystems keep for a file
+// beyond its mode, such as those chattr(1) sets on Linux, or chflags(1)
+// on the BSDs
+type FileAttr uint32
+
+const (
+	// AttrImmutable files may not be changed, renamed, or removed
+	AttrImmutable FileAttr = 1 << iota
+	// AttrAppendOnly files may only be appended to
+	AttrAppendOnly
+	// AttrCompressed files are compressed by the filesystem
+	AttrCompressed
+	// AttrEncrypted files are encrypted by the filesystem
+	AttrEncrypted
+	// AttrNoDump files are left out of backups by dump(8)
+	AttrNoDump
+	// AttrNoCOW files are rewritten in place, rather than copied on
+	// write, as btrfs otherwise does
+	AttrNoCOW
+)
+
+var attrNames = []string{"immutable", "append", "compressed", "encrypted", "nodump", "nocow"}
+
+// String returns the names of the attributes, separated by commas, or
+// "none"
+func (a FileAttr) String() string {
+	var names []string
+	for i, name := range attrNames {
+		if a&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// MarshalText returns the String of a
+func (a FileAttr) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText reads what MarshalText wrote. Names it does not know,
+// from later versions, are ignored.
+func (a *FileAttr) UnmarshalText(text []byte) error {
+	*a = 0
+	for _, name := range strings.Split(string(text), ",") {
+		for i, known := range attrNames {
+			if name == known {
+				*a |= 1 << i
+			}
+		}
+	}
+	return nil
+}
+
+// Attributes returns n's attributes, if they are known. On Linux, they
+// are only known if the Root asked for Attributes.
+func Attributes(n Node) (FileAttr, bool) {
+	si := statOf(*n.Info())
+	return si.attrs, si.hasAttrs
+}
+
+// Compression returns the algorithm the filesystem is set to compress n
+// with, such as "zstd", if it is known: on Linux, it is the
+// btrfs.compression property that btrfs(8) sets, if the Root asked for
+// Attributes. Filesystems which compress whole datasets alike, such as
+// ZFS, say so for none of their files; see AttrCompressed.
+func Compression(n Node) (string, bool) {
+	si := statOf(*n.Info())
+	return si.compress, si.compress != ""
+}
+
+// withAttributes returns fi, with the attributes of the file at p if the
+// Root wants them and fi lacks them, but the platform can find them
+func (r *Root) withAttributes(p string, fi fs.FileInfo) fs.FileInfo {
+	if !r.Attributes || !r.onOS() {
+		return fi
+	}
+	si := statOf(fi)
+	if si.hasAttrs {
+		return fi
+	}
+
+	attrs, compress, ok := r.fileAttrs(p, fi.Mode())
+	if !ok {
+		return fi
+	}
+	si.attrs, si.hasAttrs, si.compress = attrs, true, compress
+
+	return withStat(fi, si)
+}
@@ -0,0 +1,33 @@
+//go:build darwin || freebsd || netbsd
+
+package ctree
+
+import "runtime"
+
+// File flags, from sys/stat.h
+const (
+	ufNoDump     = 0x00000001
+	ufImmutable  = 0x00000002
+	ufAppend     = 0x00000004
+	ufCompressed = 0x00000020 // macOS only
+	sfImmutable  = 0x00020000
+	sfAppend     = 0x00040000
+)
+
+// bsdAttrs returns the attributes of a file with the given st_flags
+func bsdAttrs(flags uint32) FileAttr {
+	var attrs FileAttr
+	if flags&(ufImmutable|sfImmutable) != 0 {
+		attrs |= AttrImmutable
+	}
+	if flags&(ufAppend|sfAppend) != 0 {
+		attrs |= AttrAppendOnly
+	}
+	if flags&ufNoDump != 0 {
+		attrs |= AttrNoDump
+	}
+	if flags&ufCompressed != 0 && runtime.GOOS == "darwin" {
+		attrs |= AttrCompressed
+	}
+	return attrs
+}
@@ -0,0 +1,105 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Inode flags, from linux/fs.h. The attributes statx(2) gives share their
+// values.
+const (
+	fsComprFL     = 0x00000004
+	fsImmutableFL = 0x00000010
+	fsAppendFL    = 0x00000020
+	fsNoDumpFL    = 0x00000040
+	fsEncryptFL   = 0x00000800
+	fsNoCOWFL     = 0x00800000
+)
+
+var inodeFlagAttrs = map[uint32]FileAttr{
+	fsComprFL:     AttrCompressed,
+	fsImmutableFL: AttrImmutable,
+	fsAppendFL:    AttrAppendOnly,
+	fsNoDumpFL:    AttrNoDump,
+	fsEncryptFL:   AttrEncrypted,
+	fsNoCOWFL:     AttrNoCOW,
+}
+
+// fileAttrs finds the attributes of the file at p, whose mode is mode: from
+// its inode flags, with FS_IOC_GETFLAGS, for files and directories, which
+// can be opened, or otherwise from statx(2), which knows all but
+// AttrNoCOW; and its btrfs compression property
+func (r *Root) fileAttrs(p string, mode fs.FileMode) (FileAttr, string, bool) {
+	var flags uint32
+	var ok bool
+	if mode.IsRegular() || mode.IsDir() {
+		flags, ok = r.inodeFlags(p)
+	}
+	if !ok {
+		flags, ok = statxAttrs(p)
+	}
+	if !ok {
+		return 0, "", false
+	}
+
+	var attrs FileAttr
+	for flag, attr := range inodeFlagAttrs {
+		if flags&flag != 0 {
+			attrs |= attr
+		}
+	}
+	return attrs, btrfsCompression(p), true
+}
+
+// inodeFlags asks the filesystem for the inode flags of the file at p,
+// which not every filesystem has
+func (r *Root) inodeFlags(p string) (uint32, bool) {
+	f, err := r.open(p)
+	if err != nil {
+		return 0, false
+	}
+	defer r.close(f)
+
+	of, ok := f.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	conn, err := of.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var flags uint32
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		flags, ioctlErr = unix.IoctlGetUint32(int(fd), unix.FS_IOC_GETFLAGS)
+	}); err != nil || ioctlErr != nil {
+		return 0, false
+	}
+	return flags, true
+}
+
+// statxAttrs returns the attributes statx(2) gives for the file at p, as
+// inode flags, if the filesystem supports any
+func statxAttrs(p string) (uint32, bool) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, p, unix.AT_STATX_DONT_SYNC|unix.AT_SYMLINK_NOFOLLOW, 0, &stx)
+	if err != nil || stx.Attributes_mask == 0 {
+		return 0, false
+	}
+	return uint32(stx.Attributes & stx.Attributes_mask), true
+}
+
+// btrfsCompression returns the compression property of the file at p, if
+// it is on btrfs and has one
+func btrfsCompression(p string) string {
+	var buf [32]byte
+	n, err := unix.Lgetxattr(p, "btrfs.compression", buf[:])
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(buf[:n]), "\x00")
+}
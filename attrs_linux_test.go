@@ -0,0 +1,60 @@
+//go:build linux
+
+package ctree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestAttributesLinux(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("keep me out of backups"), 0o644))
+	require.NoError(t, os.Symlink("file", filepath.Join(dir, "link")))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	err = unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, fsNoDumpFL)
+	f.Close()
+	if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skip("the filesystem has no inode flags")
+	}
+	require.NoError(t, err)
+
+	scan := func(attributes bool) *DNode {
+		r := NewRoot(dir)
+		r.Attributes = attributes
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	t.Run("recorded", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dn := scan(true)
+		attrs, ok := Attributes(dn.Lookup("file"))
+		assert.True(ok)
+		assert.Equal(AttrNoDump, attrs)
+
+		// symbolic links can't be opened, so statx tells
+		attrs, ok = Attributes(dn.Lookup("link"))
+		assert.True(ok)
+		assert.Zero(attrs)
+
+		_, ok = Attributes(dn)
+		assert.True(ok)
+	})
+
+	t.Run("only if asked", func(t *testing.T) {
+		_, ok := Attributes(scan(false).Lookup("file"))
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,62 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAttr(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("none", FileAttr(0).String())
+	assert.Equal("immutable,nodump", (AttrImmutable | AttrNoDump).String())
+
+	all := AttrImmutable | AttrAppendOnly | AttrCompressed | AttrEncrypted | AttrNoDump | AttrNoCOW
+	text, err := json.Marshal(all)
+	require.NoError(t, err)
+	assert.Equal(`"immutable,append,compressed,encrypted,nodump,nocow"`, string(text))
+
+	var a FileAttr
+	require.NoError(t, json.Unmarshal([]byte(`"append,sparkly,nocow"`), &a))
+	assert.Equal(AttrAppendOnly|AttrNoCOW, a)
+	require.NoError(t, json.Unmarshal([]byte(`"none"`), &a))
+	assert.Zero(a)
+}
+
+func TestAttributesSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	var snap bytes.Buffer
+	snap.WriteString(`{"root": {"path": "top", "mode": 2147484141, "mtime": "2020-01-01T00:00:00Z",
+		"leaves": [
+			{"path": "top/log", "mode": 420, "size": 3, "mtime": "2020-01-01T00:00:00Z",
+			 "attrs": "append,compressed", "compression": "zstd"},
+			{"path": "top/plain", "mode": 420, "size": 3, "mtime": "2020-01-01T00:00:00Z"}
+		]}}`)
+	dn, err := ReadSnapshot(&snap)
+	require.NoError(t, err)
+
+	check := func(dn *DNode) {
+		attrs, ok := Attributes(dn.Lookup("log"))
+		assert.True(ok)
+		assert.Equal(AttrAppendOnly|AttrCompressed, attrs)
+		compress, ok := Compression(dn.Lookup("log"))
+		assert.True(ok)
+		assert.Equal("zstd", compress)
+
+		_, ok = Attributes(dn.Lookup("plain"))
+		assert.False(ok)
+		_, ok = Compression(dn.Lookup("plain"))
+		assert.False(ok)
+	}
+	check(dn)
+
+	require.NoError(t, WriteSnapshot(&snap, dn))
+	loaded, err := ReadSnapshot(&snap)
+	require.NoError(t, err)
+	check(loaded)
+}
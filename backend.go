@@ -0,0 +1,149 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Backend is the filesystem a Root walks: the operating system's, an
+// fs.FS, or anything else that can list directories and read files, such
+// as an archive or a remote store. Names are "/"-separated paths, like
+// the Root's Path. Its methods are called from many goroutines at once.
+type Backend interface {
+	// ReadDir returns the entries of the directory at name, in any
+	// order. The Info of each describes the entry itself, rather than
+	// what it links to.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Stat returns the FileInfo of the file at name, following
+	// symbolic links
+	Stat(name string) (fs.FileInfo, error)
+	// Open opens the file at name for reading
+	Open(name string) (fs.File, error)
+	// Readlink returns the target of the symbolic link at name
+	Readlink(name string) (string, error)
+}
+
+// OSBackend is the operating system's filesystem, which a Root walks
+// unless it has a Backend or an FS. If ReadOnly, or the Root's ReadOnly,
+// is set, files and directories are opened without changing their access
+// times; see Root.ReadOnly. A Root with an OSBackend, or a pointer to
+// one, records what only the operating system can tell, as it does with
+// no Backend; one wrapping an OSBackend, to change what it does, does
+// not.
+type OSBackend struct {
+	ReadOnly bool
+}
+
+// ReadDir returns the entries of the directory at name, in no particular
+// order
+func (b OSBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := b.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.ReadDir(-1)
+}
+
+// Stat returns the FileInfo of the file at name, following symbolic links
+func (b OSBackend) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Open opens the file at name for reading
+func (b OSBackend) Open(name string) (fs.File, error) {
+	f, err := b.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Readlink returns the target of the symbolic link at name
+func (b OSBackend) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (b OSBackend) open(name string) (*os.File, error) {
+	if b.ReadOnly {
+		return openNoAtime(name)
+	}
+	return os.Open(name)
+}
+
+// FSBackend returns a Backend reading fsys, as a Root with an FS does.
+// Readlink works if fsys has a ReadLink method, as the filesystems of
+// ReadImage do, and otherwise fails with fs.ErrInvalid.
+func FSBackend(fsys fs.FS) Backend {
+	return fsBackend{fsys}
+}
+
+type fsBackend struct {
+	fsys fs.FS
+}
+
+func (b fsBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(b.fsys, name)
+}
+
+func (b fsBackend) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(b.fsys, name)
+}
+
+func (b fsBackend) Open(name string) (fs.File, error) {
+	return b.fsys.Open(name)
+}
+
+func (b fsBackend) Readlink(name string) (string, error) {
+	if rl, ok := b.fsys.(interface {
+		ReadLink(name string) (string, error)
+	}); ok {
+		return rl.ReadLink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+// backendFS presents a Backend as an fs.FS, for fs.WalkDir. Unlike an
+// fs.FS's, its names need not be valid by fs.ValidPath.
+type backendFS struct {
+	b Backend
+}
+
+func (bfs backendFS) Open(name string) (fs.File, error) {
+	return bfs.b.Open(name)
+}
+
+func (bfs backendFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return bfs.b.ReadDir(name)
+}
+
+func (bfs backendFS) Stat(name string) (fs.FileInfo, error) {
+	return bfs.b.Stat(name)
+}
+
+// backend returns what the Root walks: its Backend, its FS, or the
+// operating system's filesystem, which is always an OSBackend, with the
+// Root's ReadOnly
+func (r *Root) backend() Backend {
+	switch {
+	case r.Backend != nil:
+		switch b := r.Backend.(type) {
+		case OSBackend:
+			return OSBackend{ReadOnly: b.ReadOnly || r.ReadOnly}
+		case *OSBackend:
+			return OSBackend{ReadOnly: b.ReadOnly || r.ReadOnly}
+		}
+		return r.Backend
+	case r.FS != nil:
+		return FSBackend(r.FS)
+	}
+	return OSBackend{ReadOnly: r.ReadOnly}
+}
+
+// onOS reports whether the Root walks the operating system's filesystem,
+// whose paths it may use directly, for what no Backend offers
+func (r *Root) onOS() bool {
+	_, ok := r.fsys.(OSBackend)
+	return ok
+}
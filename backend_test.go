@@ -0,0 +1,205 @@
+package ctree
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend checks that b, holding the test tree beneath root, with a
+// symbolic link from ceswift/link to bin/worms, works, and can be walked
+func testBackend(t *testing.T, b Backend, root string) {
+	home := path.Join(root, "home")
+
+	t.Run("ReadDir", func(t *testing.T) {
+		entries, err := b.ReadDir(path.Join(home, "ceswift"))
+		require.NoError(t, err)
+		types := map[string]fs.FileMode{}
+		for _, e := range entries {
+			types[e.Name()] = e.Type()
+		}
+		assert.Equal(t, map[string]fs.FileMode{
+			".cshrc": 0,
+			"bin":    fs.ModeDir,
+			"link":   fs.ModeSymlink,
+		}, types)
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		fi, err := b.Stat(path.Join(home, "ceswift/bin/worms"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), fi.Size())
+
+		fi, err = b.Stat(path.Join(home, "ceswift/link"))
+		require.NoError(t, err)
+		assert.True(t, fi.Mode().IsRegular(), "follows links")
+
+		_, err = b.Stat(path.Join(home, "nobody"))
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		f, err := b.Open(path.Join(home, "wsfitzpa/.cshrc"))
+		require.NoError(t, err)
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "echo hello, william.", string(data))
+	})
+
+	t.Run("Readlink", func(t *testing.T) {
+		target, err := b.Readlink(path.Join(home, "ceswift/link"))
+		require.NoError(t, err)
+		assert.Equal(t, "bin/worms", target)
+
+		_, err = b.Readlink(path.Join(home, "ceswift/.cshrc"))
+		assert.Error(t, err)
+	})
+
+	for _, threads := range []int{1, 4} {
+		r := NewRoot(home)
+		r.Backend = b
+		r.Threads = threads
+		r.Hashes = []Hasher{SHA256}
+		dn, err := r.Run()
+		require.NoError(t, err)
+		assert.Empty(t, dn.Errors())
+		assert.Equal(t, 10, dn.TotalLength())
+		assert.NotNil(t, dn.Lookup("ceswift/bin/worms").(*Leaf).Hash("sha256"), "threads %d", threads)
+
+		problems, err := r.Verify(dn)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	t.Run("OSBackend", func(t *testing.T) {
+		where := t.TempDir()
+		ttree.build(t, where)
+		require.NoError(t, os.Symlink("bin/worms", path.Join(where, "home/ceswift/link")))
+		testBackend(t, OSBackend{}, slash(where))
+	})
+
+	t.Run("FSBackend", func(t *testing.T) {
+		mfs := fstest.MapFS{"home/ceswift/link": {Data: []byte("bin/worms"), Mode: fs.ModeSymlink}}
+		for name, f := range tfs {
+			mfs[name] = f
+		}
+		testBackend(t, FSBackend(mfs), ".")
+	})
+
+	t.Run("LayerFS", func(t *testing.T) {
+		var hdrs []tar.Header
+		for name, f := range tfs {
+			hdrs = append(hdrs, tar.Header{Name: name, Typeflag: tar.TypeReg, Linkname: string(f.Data)})
+		}
+		sort.Slice(hdrs, func(i, j int) bool { return hdrs[i].Name < hdrs[j].Name })
+		hdrs = append(hdrs, tar.Header{Name: "home/ceswift/link", Typeflag: tar.TypeSymlink, Linkname: "bin/worms"})
+
+		lfs, err := LayerFS(bytes.NewReader(layerTar(t, hdrs...)))
+		require.NoError(t, err)
+		testBackend(t, FSBackend(lfs), ".")
+	})
+}
+
+// countingBackend counts the calls to its Backend
+type countingBackend struct {
+	Backend
+	readDirs, opens int32
+}
+
+func (b *countingBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	atomic.AddInt32(&b.readDirs, 1)
+	return b.Backend.ReadDir(name)
+}
+
+func (b *countingBackend) Open(name string) (fs.File, error) {
+	atomic.AddInt32(&b.opens, 1)
+	return b.Backend.Open(name)
+}
+
+// countingOS is an OSBackend counting its calls to ReadDir
+type countingOS struct {
+	OSBackend
+	readDirs int32
+}
+
+func (b *countingOS) ReadDir(name string) ([]fs.DirEntry, error) {
+	atomic.AddInt32(&b.readDirs, 1)
+	return b.OSBackend.ReadDir(name)
+}
+
+func TestRootBackend(t *testing.T) {
+	t.Run("is walked", func(t *testing.T) {
+		b := &countingBackend{Backend: FSBackend(tfs)}
+		r := NewRoot("home")
+		r.Backend = b
+		r.Hashes = []Hasher{SHA256}
+		_, err := r.Run()
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), b.readDirs)
+		assert.Equal(t, int32(4), b.opens)
+	})
+
+	t.Run("instead of FS", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = fstest.MapFS{}
+		r.Backend = FSBackend(tfs)
+		dn, err := r.Run()
+		require.NoError(t, err)
+		assert.Equal(t, 9, dn.TotalLength())
+	})
+
+	t.Run("the OS, however it is given", func(t *testing.T) {
+		for _, b := range []Backend{OSBackend{}, &OSBackend{}} {
+			r := NewRoot(t.TempDir())
+			r.Backend = b
+			r.ReadOnly = true
+			_, err := r.Run()
+			require.NoError(t, err)
+			assert.True(t, r.onOS(), "%T", b)
+			assert.Equal(t, OSBackend{ReadOnly: true}, r.backend(), "%T", b)
+		}
+	})
+
+	t.Run("a wrapped OSBackend is walked", func(t *testing.T) {
+		where := t.TempDir()
+		ttree.build(t, where)
+		b := &countingOS{}
+		r := NewRoot(where)
+		r.Backend = b
+		_, err := r.Run()
+		require.NoError(t, err)
+		assert.Equal(t, int32(6), b.readDirs)
+		assert.False(t, r.onOS())
+	})
+
+	t.Run("no links without ReadLink", func(t *testing.T) {
+		_, err := FSBackend(loopFS{}).Readlink("loop")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("only the OS has birth times", func(t *testing.T) {
+		r := NewRoot("home")
+		r.Backend = FSBackend(tfs)
+		r.BirthTimes = true
+		r.Attributes = true
+		dn, err := r.Run()
+		require.NoError(t, err)
+		_, ok := BirthTime(dn.Lookup("ceswift/.cshrc"))
+		assert.False(t, ok)
+		_, ok = Attributes(dn.Lookup("ceswift/.cshrc"))
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,172 @@
+package ctree
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// shape describes a synthetic tree: every directory down to depth has
+// width subdirectories and files leaves
+type shape struct {
+	name  string
+	depth int
+	width int
+	files int
+}
+
+var treeShapes = []shape{
+	{name: "wide-shallow", depth: 1, width: 500, files: 20},
+	{name: "narrow-deep", depth: 200, width: 1, files: 5},
+	{name: "mixed", depth: 4, width: 6, files: 8},
+}
+
+func (s shape) build(b *testing.B, where string) {
+	s.fill(b, where, 0)
+}
+
+func (s shape) fill(b *testing.B, where string, depth int) {
+	for i := 0; i < s.files; i++ {
+		p := path.Join(where, fmt.Sprintf("f%d", i))
+		if err := os.WriteFile(p, nil, 0666); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if depth == s.depth {
+		return
+	}
+
+	for i := 0; i < s.width; i++ {
+		p := path.Join(where, fmt.Sprintf("d%d", i))
+		if err := os.Mkdir(p, 0777); err != nil {
+			b.Fatal(err)
+		}
+		s.fill(b, p, depth+1)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	configs := []struct {
+		threads int
+		queue   int
+	}{
+		{1, 0},
+		{4, 0},
+		{4, DefaultWorkListSize},
+		{16, DefaultWorkListSize},
+	}
+
+	for _, s := range treeShapes {
+		where := b.TempDir()
+		s.build(b, where)
+
+		for _, c := range configs {
+			name := fmt.Sprintf("%s/threads=%d/queue=%d", s.name, c.threads, c.queue)
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					r := NewRoot(where)
+					r.Threads = c.threads
+					r.WorkListSize = c.queue
+					if _, err := r.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			name = fmt.Sprintf("%s/latency/threads=%d/queue=%d", s.name, c.threads, c.queue)
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					r := NewRoot(".")
+					r.FS = &FaultFS{
+						FS:     os.DirFS(where),
+						Faults: []Fault{{Delay: 100 * time.Microsecond}},
+					}
+					r.Threads = c.threads
+					r.WorkListSize = c.queue
+					if _, err := r.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkHash(b *testing.B) {
+	where := b.TempDir()
+	data := make([]byte, 16<<20)
+	for i := 0; i < 4; i++ {
+		if err := os.WriteFile(path.Join(where, fmt.Sprintf("f%d", i)), data, 0666); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, h := range []Hasher{SHA256, BLAKE3, XXH3} {
+		b.Run(h.Name, func(b *testing.B) {
+			b.SetBytes(int64(4 * len(data)))
+			for i := 0; i < b.N; i++ {
+				r := NewRoot(where)
+				r.Hashes = []Hasher{h}
+				if _, err := r.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadMethod(b *testing.B) {
+	where := b.TempDir()
+	data := make([]byte, 64<<20)
+	if err := os.WriteFile(path.Join(where, "big"), data, 0666); err != nil {
+		b.Fatal(err)
+	}
+
+	methods := []struct {
+		name   string
+		method ReadMethod
+	}{
+		{"plain", ReadPlain},
+		{"auto", ReadAuto},
+		{"mmap", ReadMmap},
+	}
+	for _, m := range methods {
+		b.Run(m.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				r := NewRoot(where)
+				r.Hashes = []Hasher{XXH3}
+				r.ReadMethod = m.method
+				if _, err := r.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSelect(b *testing.B) {
+	for _, s := range treeShapes {
+		where := b.TempDir()
+		s.build(b, where)
+		dn, err := NewRoot(where).Run()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, query := range []string{`size > 1PB`, `name == f0 && depth > 3`} {
+			b.Run(s.name+"/"+query, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					filter, err := ParseFilter(query)
+					if err != nil {
+						b.Fatal(err)
+					}
+					Select(dn, filter)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package ctree
+
+import "io/fs"
+
+// withBirthTime returns fi, with the birth time of the file at p if the
+// Root wants it and fi lacks it, but the platform can find it. follow says
+// whether fi came from following a symbolic link at p.
+func (r *Root) withBirthTime(p string, fi fs.FileInfo, follow bool) fs.FileInfo {
+	if !r.BirthTimes || !r.onOS() {
+		return fi
+	}
+	si := statOf(fi)
+	if !si.btime.IsZero() {
+		return fi
+	}
+
+	bt, ok := birthTime(p, follow)
+	if !ok {
+		return fi
+	}
+	si.btime = bt
+
+	return withStat(fi, si)
+}
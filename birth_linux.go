@@ -0,0 +1,23 @@
+package ctree
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime asks statx(2) when the file at p was created, which not every
+// filesystem records
+func birthTime(p string, follow bool) (time.Time, bool) {
+	flags := unix.AT_STATX_DONT_SYNC
+	if !follow {
+		flags |= unix.AT_SYMLINK_NOFOLLOW
+	}
+
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, p, flags, unix.STATX_BTIME, &stx)
+	if err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
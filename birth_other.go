@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ctree
+
+import "time"
+
+// birthTime finds nothing that stat did not
+func birthTime(p string, follow bool) (time.Time, bool) {
+	return time.Time{}, false
+}
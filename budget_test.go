@@ -0,0 +1,69 @@
+package ctree
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// busyFS records the most directories read at once beneath each top
+// level directory
+type busyFS struct {
+	fs.FS
+
+	mu   sync.Mutex
+	busy map[string]int
+	most map[string]int
+}
+
+func (bfs *busyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	top := strings.SplitN(name, "/", 2)[0]
+
+	bfs.mu.Lock()
+	bfs.busy[top]++
+	if bfs.busy[top] > bfs.most[top] {
+		bfs.most[top] = bfs.busy[top]
+	}
+	bfs.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	bfs.mu.Lock()
+	bfs.busy[top]--
+	bfs.mu.Unlock()
+
+	return fs.ReadDir(bfs.FS, name)
+}
+
+func TestBudgets(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	mfs := fstest.MapFS{}
+	for _, top := range []string{"slow", "fast"} {
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 4; j++ {
+				mfs[fmt.Sprintf("%s/d%d/d%d/f", top, i, j)] = &fstest.MapFile{}
+			}
+		}
+	}
+	bfs := &busyFS{FS: mfs, busy: map[string]int{}, most: map[string]int{}}
+
+	r := NewRoot(".")
+	r.FS = bfs
+	r.Threads = 8
+	r.Budgets = map[string]int{"slow": 2}
+	dn, err := r.Run()
+	require.NoError(err)
+
+	assert.Equal(2*(1+10+40+40)+1, dn.TotalLength())
+	assert.LessOrEqual(bfs.most["slow"], 2)
+	assert.Positive(r.Stats().OverBudget)
+}
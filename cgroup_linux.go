@@ -0,0 +1,124 @@
+//go:build linux
+
+package ctree
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cgroups finds the control files for the cgroups of a process
+type cgroups struct {
+	root  string            // where cgroupfs is mounted
+	paths map[string]string // cgroup path for each controller; "" for v2
+}
+
+// readCgroups parses a /proc/<pid>/cgroup file
+func readCgroups(procFile, root string) *cgroups {
+	cg := &cgroups{root: root, paths: map[string]string{}}
+
+	f, err := os.Open(procFile)
+	if err != nil {
+		return cg
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" {
+			cg.paths[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			cg.paths[controller] = fields[2]
+		}
+	}
+
+	return cg
+}
+
+// read returns the contents of a control file for controller, which is
+// "" for cgroup v2. Since the cgroup path is often not visible inside a
+// container, the top of the hierarchy is tried as well.
+func (cg *cgroups) read(controller, file string) (string, bool) {
+	p, ok := cg.paths[controller]
+	if !ok {
+		return "", false
+	}
+
+	base := path.Join(cg.root, controller)
+	for _, dir := range []string{path.Join(base, p), base} {
+		data, err := os.ReadFile(path.Join(dir, file))
+		if err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+
+	return "", false
+}
+
+// cpus returns the CPU quota, in CPUs, if there is one
+func (cg *cgroups) cpus() (float64, bool) {
+	if max, ok := cg.read("", "cpu.max"); ok {
+		fields := strings.Fields(max)
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return ratio(fields[0], fields[1])
+	}
+
+	quota, ok := cg.read("cpu", "cpu.cfs_quota_us")
+	if !ok {
+		return 0, false
+	}
+	period, ok := cg.read("cpu", "cpu.cfs_period_us")
+	if !ok {
+		return 0, false
+	}
+	return ratio(quota, period)
+}
+
+// memory returns the memory limit in bytes, if there is one
+func (cg *cgroups) memory() (int64, bool) {
+	limit, ok := cg.read("", "memory.max")
+	if !ok {
+		limit, ok = cg.read("memory", "memory.limit_in_bytes")
+	}
+	if !ok || limit == "max" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(limit, 10, 64)
+	// cgroup v1 reports no limit as a huge number
+	if err != nil || n <= 0 || n >= 1<<62 {
+		return 0, false
+	}
+	return n, true
+}
+
+func ratio(num, den string) (float64, bool) {
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return n / d, true
+}
+
+func cpuQuota() (float64, bool) {
+	return readCgroups("/proc/self/cgroup", "/sys/fs/cgroup").cpus()
+}
+
+func memoryLimit() (int64, bool) {
+	return readCgroups("/proc/self/cgroup", "/sys/fs/cgroup").memory()
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroups(t *testing.T) {
+	write := func(t *testing.T, where, contents string) {
+		require.NoError(t, os.MkdirAll(path.Dir(where), 0777))
+		writeFile(t, where, contents)
+	}
+
+	t.Run("v2", func(t *testing.T) {
+		assert := assert.New(t)
+
+		root := t.TempDir()
+		proc := path.Join(root, "cgroup")
+		write(t, proc, "0::/box\n")
+		write(t, path.Join(root, "box", "cpu.max"), "150000 100000\n")
+		write(t, path.Join(root, "box", "memory.max"), "268435456\n")
+
+		cg := readCgroups(proc, root)
+		cpus, ok := cg.cpus()
+		assert.True(ok)
+		assert.Equal(1.5, cpus)
+		mem, ok := cg.memory()
+		assert.True(ok)
+		assert.Equal(int64(256<<20), mem)
+	})
+
+	t.Run("v2 without limits", func(t *testing.T) {
+		assert := assert.New(t)
+
+		root := t.TempDir()
+		proc := path.Join(root, "cgroup")
+		write(t, proc, "0::/\n")
+		write(t, path.Join(root, "cpu.max"), "max 100000\n")
+		write(t, path.Join(root, "memory.max"), "max\n")
+
+		cg := readCgroups(proc, root)
+		_, ok := cg.cpus()
+		assert.False(ok)
+		_, ok = cg.memory()
+		assert.False(ok)
+	})
+
+	t.Run("v1 from the top of the hierarchy", func(t *testing.T) {
+		assert := assert.New(t)
+
+		root := t.TempDir()
+		proc := path.Join(root, "cgroup")
+		write(t, proc, "4:memory:/hidden\n2:cpu,cpuacct:/hidden\n")
+		write(t, path.Join(root, "cpu", "cpu.cfs_quota_us"), "200000")
+		write(t, path.Join(root, "cpu", "cpu.cfs_period_us"), "100000")
+		write(t, path.Join(root, "memory", "memory.limit_in_bytes"), "9223372036854771712")
+
+		cg := readCgroups(proc, root)
+		cpus, ok := cg.cpus()
+		assert.True(ok)
+		assert.Equal(2.0, cpus)
+		_, ok = cg.memory()
+		assert.False(ok)
+	})
+
+	t.Run("v1 without a quota", func(t *testing.T) {
+		root := t.TempDir()
+		proc := path.Join(root, "cgroup")
+		write(t, proc, "2:cpu:/\n")
+		write(t, path.Join(root, "cpu", "cpu.cfs_quota_us"), "-1")
+		write(t, path.Join(root, "cpu", "cpu.cfs_period_us"), "100000")
+
+		_, ok := readCgroups(proc, root).cpus()
+		assert.False(t, ok)
+	})
+}
+
+func TestDefaultThreadsFor(t *testing.T) {
+	threads := DefaultThreadsFor(t.TempDir())
+	assert.GreaterOrEqual(t, threads, 1)
+	assert.LessOrEqual(t, threads, MaxDefaultThreads)
+}
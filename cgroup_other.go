@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ctree
+
+func cpuQuota() (float64, bool) {
+	return 0, false
+}
+
+func memoryLimit() (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+package ctree
+
+import "path"
+
+// Chain follows dn down through directories which hold nothing but one
+// other directory, returning the last of them, and their names from dn's
+// joined with "/", such as "a/b/c" for a directory "a". Views use it to
+// show such chains as one entry, as code hosts do. A directory with an
+// error ends its chain, so that the error is seen.
+func (dn *DNode) Chain() (*DNode, string) {
+	last, name := dn, dn.name
+	for last.err == nil && len(last.leaves) == 0 && len(last.children) == 1 {
+		last = last.children[0]
+		name = path.Join(name, last.name)
+	}
+	return last, name
+}
@@ -0,0 +1,47 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanChains scans the test tree with chains of directories added
+func scanChains(t *testing.T) *DNode {
+	return scanCopy(t, func(where string) {
+		require.NoError(t, os.MkdirAll(path.Join(where, "src/github.com/samf/ctree"), 0777))
+		writeFile(t, path.Join(where, "src/github.com/samf/ctree/go.mod"), "module")
+		require.NoError(t, os.MkdirAll(path.Join(where, "empty/a/b"), 0777))
+	})
+}
+
+func TestChain(t *testing.T) {
+	assert := assert.New(t)
+
+	dn := scanChains(t)
+
+	last, name := dn.Lookup("src").(*DNode).Chain()
+	assert.Equal("src/github.com/samf/ctree", name)
+	assert.Same(dn.Lookup("src/github.com/samf/ctree"), last)
+
+	last, name = dn.Lookup("empty").(*DNode).Chain()
+	assert.Equal("empty/a/b", name)
+	assert.Same(dn.Lookup("empty/a/b"), last)
+
+	ceswift := dn.Lookup("ceswift").(*DNode)
+	last, name = ceswift.Chain()
+	assert.Equal("ceswift", name)
+	assert.Same(ceswift, last)
+
+	last, name = dn.Lookup("ceswift/bin").(*DNode).Chain()
+	assert.Equal("bin", name)
+
+	bad := dn.Lookup("src/github.com").(*DNode)
+	bad.err = os.ErrPermission
+	last, name = dn.Lookup("src").(*DNode).Chain()
+	assert.Equal("src/github.com", name)
+	assert.Same(bad, last)
+}
@@ -0,0 +1,95 @@
+package ctree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ClassifierHeaderSize is how much of the start of each file is given to
+// classifiers
+const ClassifierHeaderSize = 4 << 10
+
+// Classifier looks at a regular file, given as much of the start of its
+// contents as there is, up to ClassifierHeaderSize bytes, and returns
+// tags describing it, such as "contains-secrets" or "container-layer".
+// Classifiers are called by many workers at once.
+type Classifier func(l *Leaf, header []byte) []string
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   = map[string]Classifier{}
+)
+
+// ErrNoClassifier is returned by Run when Root.Classify names a
+// classifier which was never registered
+var ErrNoClassifier = errors.New("no such classifier")
+
+// RegisterClassifier makes a classifier available to Roots by name,
+// replacing any registered before under the same name
+func RegisterClassifier(name string, c Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+
+	classifiers[name] = c
+}
+
+// Classifiers returns the names of the registered classifiers, sorted
+func Classifiers() []string {
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+
+	names := make([]string, 0, len(classifiers))
+	for name := range classifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupClassifiers finds the registered classifiers of the given names
+func lookupClassifiers(names []string) ([]Classifier, error) {
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+
+	var cs []Classifier
+	for _, name := range names {
+		c, ok := classifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("%q: %w", name, ErrNoClassifier)
+		}
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// classify reads the start of the leaf, and tags it with what the Root's
+// classifiers make of it
+func (l *Leaf) classify(w *worker) error {
+	r := w.r
+
+	f, err := r.open(l.path)
+	if err != nil {
+		return err
+	}
+	defer r.close(f)
+
+	header := make([]byte, ClassifierHeaderSize)
+	n, err := io.ReadFull(r.counted(f), header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	header = header[:n]
+
+	for _, c := range r.classifiers {
+		for _, tag := range c(l, header) {
+			l.AddTag(tag)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package ctree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifiers(t *testing.T) {
+	RegisterClassifier("test-shebang", func(l *Leaf, header []byte) []string {
+		if bytes.HasPrefix(header, []byte("#!")) {
+			return []string{"script"}
+		}
+		return nil
+	})
+	RegisterClassifier("test-size", func(l *Leaf, header []byte) []string {
+		tags := []string{"seen"}
+		if len(header) == ClassifierHeaderSize {
+			tags = append(tags, "big")
+		}
+		if strings.HasPrefix(string(header), "#!") {
+			tags = append(tags, "script")
+		}
+		return tags
+	})
+
+	mfs := fstest.MapFS{
+		"bin/run":  {Data: []byte("#!/bin/sh\n")},
+		"data/big": {Data: make([]byte, 2*ClassifierHeaderSize)},
+		"empty":    {},
+	}
+
+	t.Run("tags", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		r := NewRoot(".")
+		r.FS = mfs
+		r.Classify = []string{"test-shebang", "test-size"}
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.Empty(dn.Errors())
+
+		assert.Equal([]string{"script", "seen"}, dn.Lookup("bin/run").(*Leaf).Tags())
+		assert.Equal([]string{"big", "seen"}, dn.Lookup("data/big").(*Leaf).Tags())
+		assert.Equal([]string{"seen"}, dn.Lookup("empty").(*Leaf).Tags())
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		read, err := ReadSnapshot(&buf)
+		require.NoError(err)
+		assert.Equal([]string{"script", "seen"}, read.Lookup("bin/run").(*Leaf).Tags())
+
+		assert.Equal([]string{"big", "seen"}, RecordOf(dn.Lookup("data/big")).Tags)
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		r := NewRoot(".")
+		r.FS = mfs
+		r.Classify = []string{"test-nonesuch"}
+		_, err := r.Run()
+		assert.ErrorIs(t, err, ErrNoClassifier)
+		assert.False(t, r.Running())
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		assert.Subset(t, Classifiers(), []string{"test-shebang", "test-size"})
+	})
+}
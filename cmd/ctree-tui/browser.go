@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/samf/ctree"
+)
+
+// sortOrder is how a directory's entries are listed
+type sortOrder int
+
+const (
+	bySize sortOrder = iota
+	byName
+)
+
+func (o sortOrder) String() string {
+	if o == byName {
+		return "name"
+	}
+	return "size"
+}
+
+// browser is the state of the interface: where it is in the tree, and
+// what has been marked for deletion
+type browser struct {
+	root     *ctree.DNode
+	cwd      *ctree.DNode
+	entries  []ctree.Node
+	cursor   int
+	top      int // the first entry on screen
+	order    sortOrder
+	marked   map[ctree.Node]bool
+	readOnly bool // the tree is a snapshot, not the filesystem
+	chains   bool // show chains of lone directories as one entry
+	status   string
+
+	usage map[*ctree.DNode]ctree.Usage
+}
+
+func newBrowser(root *ctree.DNode, readOnly bool) *browser {
+	b := &browser{
+		root:     root,
+		marked:   map[ctree.Node]bool{},
+		readOnly: readOnly,
+		usage:    map[*ctree.DNode]ctree.Usage{},
+	}
+	b.enter(root)
+
+	return b
+}
+
+// size is the bytes used by n, including everything beneath a directory
+func (b *browser) size(n ctree.Node) int64 {
+	dn, ok := n.(*ctree.DNode)
+	if !ok {
+		return (*n.Info()).Size()
+	}
+
+	u, ok := b.usage[dn]
+	if !ok {
+		u = dn.Usage()
+		b.usage[dn] = u
+	}
+	return u.Bytes
+}
+
+func (b *browser) enter(dn *ctree.DNode) {
+	b.cwd = dn
+	b.cursor, b.top = 0, 0
+	b.list()
+}
+
+// list reads and sorts the entries of the current directory
+func (b *browser) list() {
+	b.entries = b.cwd.Entries()
+	if b.order == bySize {
+		sort.SliceStable(b.entries, func(i, j int) bool {
+			return b.size(b.entries[i]) > b.size(b.entries[j])
+		})
+	}
+	if b.cursor >= len(b.entries) {
+		b.cursor = len(b.entries) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+func (b *browser) selected() ctree.Node {
+	if len(b.entries) == 0 {
+		return nil
+	}
+	return b.entries[b.cursor]
+}
+
+func (b *browser) move(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.entries) {
+		b.cursor = len(b.entries) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+// open drills into the selected directory, or to the end of its chain
+func (b *browser) open() {
+	if dn, ok := b.selected().(*ctree.DNode); ok {
+		if b.chains {
+			dn, _ = dn.Chain()
+		}
+		b.enter(dn)
+	}
+}
+
+// back goes up to the parent directory, or above the chain it is the end
+// of, selecting where it came from
+func (b *browser) back() {
+	parent := b.cwd.Parent()
+	if parent == nil || b.cwd == b.root {
+		return
+	}
+
+	from := b.cwd
+	for b.chains && parent != b.root && parent.Parent() != nil {
+		if last, _ := parent.Chain(); last == parent {
+			break
+		}
+		from, parent = parent, parent.Parent()
+	}
+	b.enter(parent)
+	for i, n := range b.entries {
+		if n == ctree.Node(from) {
+			b.cursor = i
+		}
+	}
+}
+
+func (b *browser) toggleSort() {
+	sel := b.selected()
+	b.order = 1 - b.order
+	b.list()
+	for i, n := range b.entries {
+		if n == sel {
+			b.cursor = i
+		}
+	}
+}
+
+func (b *browser) toggleChains() {
+	b.chains = !b.chains
+	for b.chains && b.cwd != b.root {
+		// stay out of the middle of a chain
+		if last, _ := b.cwd.Chain(); last == b.cwd {
+			break
+		}
+		b.back()
+	}
+}
+
+func (b *browser) toggleMark() {
+	if b.readOnly {
+		b.status = "snapshots are read only"
+		return
+	}
+	if n := b.selected(); n != nil {
+		if b.marked[n] {
+			delete(b.marked, n)
+		} else {
+			b.marked[n] = true
+		}
+		b.move(1)
+	}
+}
+
+// deleteMarked removes everything marked, using the parallel delete API
+func (b *browser) deleteMarked(threads int) {
+	var failed int
+	for n := range b.marked {
+		if err := ctree.RemoveTree(n, &ctree.RemoveOptions{Threads: threads}); err != nil {
+			failed++
+		}
+	}
+
+	b.status = fmt.Sprintf("deleted %d entries", len(b.marked)-failed)
+	if failed > 0 {
+		b.status += fmt.Sprintf(", %d failed", failed)
+	}
+
+	b.marked = map[ctree.Node]bool{}
+	b.usage = map[*ctree.DNode]ctree.Usage{}
+	b.list()
+}
+
+// markedSize totals the marked entries
+func (b *browser) markedSize() int64 {
+	var total int64
+	for n := range b.marked {
+		total += b.size(n)
+	}
+	return total
+}
+
+// render draws the browser into a screen of the given size
+func (b *browser) render(w io.Writer, width, height int) {
+	rows := height - 3 // header, rule, and footer
+	if rows < 1 {
+		rows = 1
+	}
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+rows {
+		b.top = b.cursor - rows + 1
+	}
+
+	total := b.size(b.cwd)
+	fmt.Fprintf(w, "%s\r\n", fit(fmt.Sprintf(" %s  %s  (sorted by %s)",
+		b.cwd.Path(), humanize(total), b.order), width))
+	fmt.Fprintf(w, "%s\r\n", strings.Repeat("-", width))
+
+	for i := b.top; i < b.top+rows; i++ {
+		if i >= len(b.entries) {
+			fmt.Fprint(w, "\r\n")
+			continue
+		}
+		n := b.entries[i]
+
+		line := b.line(n, total)
+		if i == b.cursor {
+			line = "\x1b[7m" + fit(line, width) + "\x1b[0m"
+		} else {
+			line = fit(line, width)
+		}
+		fmt.Fprintf(w, "%s\r\n", line)
+	}
+
+	footer := b.status
+	if footer == "" {
+		footer = " ↑↓ move  → open  ← back  s sort  c chains  space mark  d delete  q quit"
+		if len(b.marked) > 0 {
+			footer = fmt.Sprintf(" %d marked (%s)  d delete  q quit",
+				len(b.marked), humanize(b.markedSize()))
+		}
+	}
+	fmt.Fprint(w, fit(footer, width))
+}
+
+func (b *browser) line(n ctree.Node, total int64) string {
+	mark := " "
+	if b.marked[n] {
+		mark = "*"
+	}
+
+	size := b.size(n)
+	const barWidth = 10
+	filled := 0
+	if total > 0 {
+		filled = int(size * barWidth / total)
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat(" ", barWidth-filled)
+
+	name := (*n.Info()).Name()
+	if dn, ok := n.(*ctree.DNode); ok {
+		if b.chains {
+			dn, name = dn.Chain()
+		}
+		name += "/"
+		if dn.Error() != nil {
+			name += "  (" + dn.Error().Error() + ")"
+		}
+	}
+
+	return fmt.Sprintf("%s %10s [%s] %s", mark, humanize(size), bar, name)
+}
+
+// fit pads or cuts s to width runes
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// humanize formats a size in bytes with binary units
+func humanize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/samf/ctree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scan builds a small tree and scans it
+func scan(t *testing.T) *ctree.DNode {
+	where := t.TempDir()
+	files := map[string]int{
+		"big/a":   300,
+		"big/b":   200,
+		"small/c": 10,
+		"d":       50,
+	}
+	for name, size := range files {
+		p := path.Join(where, name)
+		require.NoError(t, os.MkdirAll(path.Dir(p), 0777))
+		require.NoError(t, os.WriteFile(p, make([]byte, size), 0666))
+	}
+
+	dn, err := ctree.NewRoot(where).Run()
+	require.NoError(t, err)
+	return dn
+}
+
+func names(b *browser) []string {
+	var ns []string
+	for _, n := range b.entries {
+		ns = append(ns, (*n.Info()).Name())
+	}
+	return ns
+}
+
+func TestBrowser(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newBrowser(scan(t), false)
+	assert.Equal([]string{"big", "d", "small"}, names(b))
+
+	b.toggleSort()
+	assert.Equal([]string{"big", "d", "small"}, names(b))
+	b.move(2)
+	b.toggleSort()
+	assert.Equal([]string{"big", "d", "small"}, names(b))
+	assert.Equal("small", (*b.selected().Info()).Name())
+
+	b.toggleSort()
+	b.move(-5)
+	b.open()
+	assert.Equal([]string{"a", "b"}, names(b))
+	b.back()
+	assert.Equal("big", (*b.selected().Info()).Name())
+	b.back()
+	assert.Equal(b.root, b.cwd)
+
+	var sb strings.Builder
+	b.render(&sb, 60, 10)
+	assert.Contains(sb.String(), "560 B")
+	assert.Contains(sb.String(), "500 B [########  ] big/")
+}
+
+func TestBrowserChains(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dn := scan(t)
+	deep := path.Join(dn.Path(), "deep/a/b")
+	require.NoError(os.MkdirAll(deep, 0777))
+	require.NoError(os.WriteFile(path.Join(deep, "f"), make([]byte, 5), 0666))
+	dn, err := ctree.NewRoot(dn.Path()).Run()
+	require.NoError(err)
+
+	b := newBrowser(dn, false)
+	b.order = byName
+	b.list()
+	b.chains = true
+	assert.Equal([]string{"big", "d", "deep", "small"}, names(b))
+	b.move(2)
+	assert.Contains(b.line(b.selected(), 0), " deep/a/b/")
+
+	b.open()
+	assert.Equal([]string{"f"}, names(b))
+	assert.Equal(deep, b.cwd.Path())
+	b.back()
+	assert.Equal(b.root, b.cwd)
+	assert.Equal("deep", (*b.selected().Info()).Name())
+
+	b.toggleChains()
+	b.open()
+	assert.Equal([]string{"a"}, names(b))
+	assert.Contains(b.line(b.selected(), 0), " a/")
+	b.toggleChains()
+	assert.Equal(b.root, b.cwd, "left the middle of the chain")
+	assert.Equal("deep", (*b.selected().Info()).Name())
+}
+
+func TestBrowserDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	dn := scan(t)
+	b := newBrowser(dn, false)
+	b.toggleMark()
+	assert.Equal(int64(500), b.markedSize())
+	assert.Equal(1, b.cursor)
+
+	b.deleteMarked(2)
+	assert.NoDirExists(path.Join(dn.Path(), "big"))
+	assert.Equal([]string{"d", "small"}, names(b))
+	assert.Equal(int64(60), b.size(b.cwd))
+	assert.Equal("deleted 1 entries", b.status)
+
+	ro := newBrowser(dn, true)
+	ro.toggleMark()
+	assert.Empty(ro.marked)
+}
+
+func TestHumanize(t *testing.T) {
+	assert.Equal(t, "0 B", humanize(0))
+	assert.Equal(t, "1023 B", humanize(1023))
+	assert.Equal(t, "1.0 KiB", humanize(1024))
+	assert.Equal(t, "1.5 MiB", humanize(3<<19))
+	assert.Equal(t, "2.0 GiB", humanize(2<<30))
+}
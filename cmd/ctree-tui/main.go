@@ -0,0 +1,189 @@
+// Command ctree-tui browses a directory tree by size, in the manner of
+// ncdu. It scans a directory, or loads a snapshot written by
+// ctree.WriteSnapshot, then lets you drill into directories, sort by size
+// or name, and mark entries to delete with ctree.RemoveTree.
+//
+// Usage:
+//
+//	ctree-tui [-threads n] [-chains] [dir]
+//	ctree-tui [-chains] -snapshot file
+//
+// With -chains, or after pressing c, chains of directories which hold only
+// one other directory are shown as one entry, like "a/b/c".
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/samf/ctree"
+	"golang.org/x/term"
+)
+
+func main() {
+	snapshot := flag.String("snapshot", "", "browse this snapshot instead of scanning")
+	threads := flag.Int("threads", 0, "threads to scan and delete with (default depends on the filesystem)")
+	chains := flag.Bool("chains", false, "show chains of directories holding one directory as one entry")
+	flag.Parse()
+
+	root, readOnly, err := load(*snapshot, flag.Arg(0), *threads)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ctree-tui:", err)
+		os.Exit(1)
+	}
+
+	b := newBrowser(root, readOnly)
+	b.chains = *chains
+	if err := browse(b, *threads); err != nil {
+		fmt.Fprintln(os.Stderr, "ctree-tui:", err)
+		os.Exit(1)
+	}
+}
+
+// load scans dir, or reads snapshot if it is set
+func load(snapshot, dir string, threads int) (*ctree.DNode, bool, error) {
+	if snapshot != "" {
+		f, err := os.Open(snapshot)
+		if err != nil {
+			return nil, false, err
+		}
+		defer f.Close()
+
+		dn, err := ctree.ReadSnapshot(f)
+		return dn, true, err
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	r := ctree.NewRoot(dir)
+	if threads > 0 {
+		r.Threads = threads
+	}
+
+	fmt.Fprintf(os.Stderr, "scanning %s...\n", dir)
+	dn, err := r.Run()
+	return dn, false, err
+}
+
+// browse runs the interface on the terminal until the user quits
+func browse(b *browser, threads int) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("standard input is not a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // alternate screen, no cursor
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	in := bufio.NewReader(os.Stdin)
+	confirming := false
+
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, "\x1b[H\x1b[2J")
+		b.render(out, width, height)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		k, err := readKey(in)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if confirming {
+			confirming = false
+			b.status = ""
+			if k == "y" {
+				b.deleteMarked(threads)
+			}
+			continue
+		}
+		b.status = ""
+
+		switch k {
+		case "q", "\x03":
+			return nil
+		case "up", "k":
+			b.move(-1)
+		case "down", "j":
+			b.move(1)
+		case "pgup":
+			b.move(-(height - 3))
+		case "pgdn":
+			b.move(height - 3)
+		case "right", "l", "\r":
+			b.open()
+		case "left", "h", "\x7f":
+			b.back()
+		case "s":
+			b.toggleSort()
+		case "c":
+			b.toggleChains()
+		case " ":
+			b.toggleMark()
+		case "d":
+			if len(b.marked) > 0 {
+				confirming = true
+				b.status = fmt.Sprintf(" delete %d entries (%s)? y/n",
+					len(b.marked), humanize(b.markedSize()))
+			}
+		}
+	}
+}
+
+// readKey reads a keystroke, naming the arrow and paging keys
+func readKey(in *bufio.Reader) (string, error) {
+	c, err := in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if c != 0x1b || in.Buffered() == 0 {
+		return string(c), nil
+	}
+
+	seq := []byte{}
+	for in.Buffered() > 0 {
+		c, _ := in.ReadByte()
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e && len(seq) > 1 {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "[A", "OA":
+		return "up", nil
+	case "[B", "OB":
+		return "down", nil
+	case "[C", "OC":
+		return "right", nil
+	case "[D", "OD":
+		return "left", nil
+	case "[5~":
+		return "pgup", nil
+	case "[6~":
+		return "pgdn", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const bashCompletion = `# bash completion for ctree
+_ctree() {
+	local cur prev
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+	if [ "$COMP_CWORD" -eq 1 ] && [[ "completion" == "$cur"* ]] && [ -n "$cur" ]; then
+		COMPREPLY=(completion)
+		return
+	fi
+	if [ "${COMP_WORDS[1]}" = completion ]; then
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+		return
+	fi
+
+	case "$prev" in
+	-output|--output)
+		COMPREPLY=($(compgen -W "{{formats}}" -- "$cur"))
+		return
+		;;
+	-sort|--sort)
+		COMPREPLY=($(compgen -W "{{orders}}" -- "$cur"))
+		return
+		;;
+	-snapshot|--snapshot)
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+		;;
+	-threads|--threads|-where|--where)
+		return
+		;;
+	esac
+
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "{{flags}}" -- "$cur"))
+		return
+	fi
+	COMPREPLY=($(compgen -d -- "$cur"))
+}
+complete -o filenames -F _ctree ctree
+`
+
+const zshCompletion = `#compdef ctree
+# zsh completion for ctree
+_ctree() {
+	if (( CURRENT == 3 )) && [[ $words[2] == completion ]]; then
+		_values shell bash zsh fish
+		return
+	fi
+	_arguments \
+		'-output[output format]:format:({{formats}})' \
+		'-threads[threads to scan with]:threads:' \
+		'-snapshot[read a snapshot instead of scanning]:file:_files' \
+		'-chains[draw chains of lone directories as one entry]' \
+		'-sort[order of the entries of each directory]:order:({{orders}})' \
+		'-where[write only what matches a query]:query:' \
+		'1:directory:_directories'
+}
+compdef _ctree ctree
+`
+
+const fishCompletion = `# fish completion for ctree
+complete -c ctree -f
+complete -c ctree -n '__fish_use_subcommand' -a completion -d 'print a shell completion script'
+complete -c ctree -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c ctree -o output -x -a '{{formats}}' -d 'output format'
+complete -c ctree -o threads -x -d 'threads to scan with'
+complete -c ctree -o snapshot -r -F -d 'read a snapshot instead of scanning'
+complete -c ctree -o chains -d 'draw chains of lone directories as one entry'
+complete -c ctree -o sort -x -a '{{orders}}' -d 'order of the entries of each directory'
+complete -c ctree -o where -x -d 'write only what matches a query'
+complete -c ctree -n 'not __fish_seen_subcommand_from completion' -a '(__fish_complete_directories)'
+`
+
+var completions = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// writeCompletion writes the completion script for shell
+func writeCompletion(w io.Writer, shell string) error {
+	script, ok := completions[shell]
+	if !ok {
+		return fmt.Errorf("%q: no completion for this shell; want bash, zsh, or fish", shell)
+	}
+
+	script = strings.NewReplacer(
+		"{{formats}}", formatNames,
+		"{{orders}}", orderNames,
+		"{{flags}}", "-output -threads -snapshot -chains -sort -where --output --threads --snapshot --chains --sort --where",
+	).Replace(script)
+
+	_, err := io.WriteString(w, script)
+	return err
+}
@@ -0,0 +1,186 @@
+// Command ctree scans a directory tree concurrently and writes it out, in
+// a form chosen for people or for other programs.
+//
+// Usage:
+//
+//	ctree [-output format] [-threads n] [-chains] [-sort order] [-where query] [-snapshot file] [dir]
+//	ctree completion bash|zsh|fish
+//
+// The formats are:
+//
+//	tree    an indented tree, colored by LS_COLORS on a terminal (default)
+//	json    a snapshot, as read by ctree.ReadSnapshot
+//	ndjson  a JSON object per line for each file and directory
+//	csv     a row for each file and directory, with a header
+//	du      the bytes beneath each directory, as "du -b" prints them
+//	fixture Go source for an fstest.MapFS with the same files, for tests
+//
+// With -chains, the tree format draws each chain of directories holding
+// only one other directory as one entry, like "a/b/c".
+//
+// The tree format sorts the entries of each directory by -sort order:
+//
+//	byte      by the bytes of their names, as the C locale does (default)
+//	natural   as byte, but with numbers in order, so "f2" comes before "f10"
+//	locale    as ls does in the locale of LC_ALL, LC_COLLATE, or LANG
+//	natlocale as locale, but with numbers in order
+//
+// With -where, only the files and directories matching the query are
+// written, with the directories leading to them, as in
+//
+//	ctree -where 'size > 100MB && ext == ".log" && age > 30d' /var/log
+//
+// See ctree.ParseFilter for the fields and operators of queries.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/samf/ctree"
+	"golang.org/x/term"
+)
+
+// style is how the output should look, for the formats that care
+type style struct {
+	color  bool // stdout wants color
+	chains bool // compress chains of lone directories
+	order  ctree.NameOrder
+}
+
+// formats are the writers for each -output format
+var formats = map[string]func(w io.Writer, dn *ctree.DNode, st style) error{
+	"tree": func(w io.Writer, dn *ctree.DNode, st style) error {
+		opts := &ctree.TreeOptions{CompressChains: st.chains, Order: st.order}
+		if st.color {
+			opts.Colors = ctree.ColorsFromEnv()
+		}
+		return ctree.WriteTree(w, dn, opts)
+	},
+	"json": func(w io.Writer, dn *ctree.DNode, _ style) error {
+		return ctree.WriteSnapshot(w, dn)
+	},
+	"ndjson": func(w io.Writer, dn *ctree.DNode, _ style) error {
+		return ctree.WriteNDJSON(w, dn)
+	},
+	"csv": func(w io.Writer, dn *ctree.DNode, _ style) error {
+		return ctree.WriteCSV(w, dn)
+	},
+	"du": func(w io.Writer, dn *ctree.DNode, _ style) error {
+		return ctree.WriteDu(w, dn)
+	},
+	"fixture": func(w io.Writer, dn *ctree.DNode, _ style) error {
+		return ctree.WriteFixture(w, dn, &ctree.FixtureOptions{Stubs: true})
+	},
+}
+
+// formatNames lists the formats, for help and completion
+const formatNames = "tree json ndjson csv du fixture"
+
+// orders are the orders of each -sort name
+var orders = map[string]func() ctree.NameOrder{
+	"byte":      func() ctree.NameOrder { return ctree.ByteOrder },
+	"natural":   func() ctree.NameOrder { return ctree.NaturalOrder },
+	"locale":    func() ctree.NameOrder { return ctree.CollationFromEnv(false) },
+	"natlocale": func() ctree.NameOrder { return ctree.CollationFromEnv(true) },
+}
+
+// orderNames lists the orders, for help and completion
+const orderNames = "byte natural locale natlocale"
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr, isTerminal(os.Stdout))
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ctree:", err)
+		os.Exit(1)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor && term.IsTerminal(int(f.Fd()))
+}
+
+// run is the whole of the command; color says whether stdout wants color
+func run(args []string, stdout, stderr io.Writer, color bool) error {
+	if len(args) > 0 && args[0] == "completion" {
+		if len(args) != 2 {
+			return errors.New("usage: ctree completion bash|zsh|fish")
+		}
+		return writeCompletion(stdout, args[1])
+	}
+
+	flags := flag.NewFlagSet("ctree", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("output", "tree", "output `format`: "+strings.ReplaceAll(formatNames, " ", ", "))
+	threads := flags.Int("threads", 0, "threads to scan with (default depends on the filesystem)")
+	snapshot := flags.String("snapshot", "", "read this snapshot `file`, which may be compressed with gzip or zstd, instead of scanning")
+	chains := flags.Bool("chains", false, "draw chains of directories holding one directory as one entry")
+	where := flags.String("where", "", "write only what matches this `query`, such as 'size > 100MB && age > 30d'")
+	sortBy := flags.String("sort", "byte", "`order` of the entries of each directory: "+strings.ReplaceAll(orderNames, " ", ", "))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errors.New("too many arguments")
+	}
+
+	write, ok := formats[*output]
+	if !ok {
+		return fmt.Errorf("%q: unknown output format; want one of %s", *output, formatNames)
+	}
+	order, ok := orders[*sortBy]
+	if !ok {
+		return fmt.Errorf("%q: unknown sort order; want one of %s", *sortBy, orderNames)
+	}
+	var filter ctree.Filter
+	if *where != "" {
+		var err error
+		if filter, err = ctree.ParseFilter(*where); err != nil {
+			return err
+		}
+	}
+
+	dn, err := load(*snapshot, flags.Arg(0), *threads)
+	if err != nil {
+		return err
+	}
+	for _, err := range dn.Errors() {
+		fmt.Fprintln(stderr, "ctree:", err)
+	}
+	if filter != nil {
+		dn = ctree.Select(dn, filter)
+	}
+
+	return write(stdout, dn, style{color: color, chains: *chains, order: order()})
+}
+
+// load scans dir, or reads snapshot if it is set
+func load(snapshot, dir string, threads int) (*ctree.DNode, error) {
+	if snapshot != "" {
+		f, err := os.Open(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return ctree.ReadSnapshot(f)
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	r := ctree.NewRoot(dir)
+	if threads > 0 {
+		r.Threads = threads
+	}
+
+	return r.Run()
+}
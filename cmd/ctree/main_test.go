@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/samf/ctree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tree(t *testing.T) string {
+	where := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(where, "a/b"), 0777))
+	require.NoError(t, os.WriteFile(path.Join(where, "a/b/f"), make([]byte, 7), 0666))
+	require.NoError(t, os.WriteFile(path.Join(where, "g"), make([]byte, 3), 0666))
+	return where
+}
+
+func output(t *testing.T, args ...string) string {
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run(args, &stdout, &stderr, false))
+	assert.Empty(t, stderr.String())
+	return stdout.String()
+}
+
+func TestOutputs(t *testing.T) {
+	where := tree(t)
+
+	t.Run("tree", func(t *testing.T) {
+		out := output(t, where)
+		assert.Equal(t, where+"\n├── a\n│   └── b\n│       └── f\n└── g\n", out)
+	})
+
+	t.Run("tree with chains", func(t *testing.T) {
+		out := output(t, "-chains", where)
+		assert.Equal(t, where+"\n├── a/b\n│   └── f\n└── g\n", out)
+	})
+
+	t.Run("tree in natural order", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"f10", "f2", "F1"} {
+			require.NoError(t, os.WriteFile(path.Join(dir, name), nil, 0666))
+		}
+		assert.Equal(t, dir+"\n├── F1\n├── f10\n└── f2\n", output(t, dir))
+		assert.Equal(t, dir+"\n├── F1\n├── f2\n└── f10\n", output(t, "-sort", "natural", dir))
+	})
+
+	t.Run("tree where", func(t *testing.T) {
+		assert.Equal(t, where+"\n└── a\n    └── b\n        └── f\n", output(t, "-where", "size > 5", where))
+		assert.Equal(t, where+"\n└── g\n", output(t, "-where", "type == file && depth == 1", where))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := output(t, "-output", "json", where)
+		require.NoError(t, ctree.ValidateSnapshot(strings.NewReader(out)))
+		dn, err := ctree.ReadSnapshot(strings.NewReader(out))
+		require.NoError(t, err)
+		assert.Equal(t, 5, dn.TotalLength())
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(output(t, "--output=ndjson", where)), "\n")
+		require.Len(t, lines, 5)
+
+		var rec ctree.Record
+		require.NoError(t, json.Unmarshal([]byte(lines[3]), &rec))
+		assert.Equal(t, path.Join(where, "a/b/f"), rec.Path)
+		assert.Equal(t, "file", rec.Type)
+		assert.Equal(t, int64(7), rec.Size)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rows, err := csv.NewReader(strings.NewReader(output(t, "-output", "csv", where))).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 6)
+		assert.Equal(t, []string{"path", "type", "mode", "size", "mtime", "uid", "gid", "error"}, rows[0])
+		assert.Equal(t, []string{where, "dir"}, rows[1][:2])
+		assert.Equal(t, []string{path.Join(where, "g"), "file", "0644", "3"}, rows[5][:4])
+	})
+
+	t.Run("du", func(t *testing.T) {
+		assert.Equal(t,
+			"7\t"+path.Join(where, "a/b")+"\n7\t"+path.Join(where, "a")+"\n10\t"+where+"\n",
+			output(t, "-output", "du", where))
+	})
+
+	t.Run("fixture", func(t *testing.T) {
+		out := output(t, "-output", "fixture", where)
+		assert.Contains(t, out, `"a/b/f": {Data: make([]byte, 7), Mode: 0o644},`)
+		assert.Contains(t, out, `"g":     {Data: make([]byte, 3), Mode: 0o644},`)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		snap := path.Join(t.TempDir(), "snap.json")
+		require.NoError(t, os.WriteFile(snap, []byte(output(t, "-output", "json", where)), 0666))
+		assert.Equal(t, output(t, "-output", "du", where), output(t, "-output", "du", "-snapshot", snap))
+	})
+
+	t.Run("bad format", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := run([]string{"-output", "xml", where}, &stdout, &stderr, false)
+		assert.ErrorContains(t, err, "unknown output format")
+	})
+
+	t.Run("bad sort", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := run([]string{"-sort", "random", where}, &stdout, &stderr, false)
+		assert.ErrorContains(t, err, "unknown sort order")
+	})
+
+	t.Run("bad where", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := run([]string{"-where", "size >", where}, &stdout, &stderr, false)
+		assert.ErrorContains(t, err, "expected a value")
+		assert.Empty(t, stdout.String())
+	})
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out := output(t, "completion", shell)
+		assert.Contains(t, out, "ndjson", shell)
+		assert.Contains(t, out, "natlocale", shell)
+		assert.NotContains(t, out, "{{", shell)
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Error(t, run([]string{"completion", "tcsh"}, &stdout, &stderr, false))
+}
@@ -0,0 +1,246 @@
+package ctree
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// CodeAnnotation names the annotation a CodeCounter gives source files
+const CodeAnnotation = "code"
+
+// CodeStats counts the lines of source code in a file, or in many
+type CodeStats struct {
+	Language string `json:"language,omitempty"`
+	Files    int    `json:"files"`
+	Lines    int    `json:"lines"`
+	Blank    int    `json:"blank"`
+	// Comment counts lines holding only a line comment
+	Comment int `json:"comment"`
+	Code    int `json:"code"`
+}
+
+func (cs *CodeStats) add(other CodeStats) {
+	cs.Files += other.Files
+	cs.Lines += other.Lines
+	cs.Blank += other.Blank
+	cs.Comment += other.Comment
+	cs.Code += other.Code
+}
+
+// language is a programming language, and how its line comments start
+type language struct {
+	name     string
+	comments []string
+}
+
+var (
+	langGo     = &language{"Go", []string{"//"}}
+	langC      = &language{"C", []string{"//"}}
+	langCPP    = &language{"C++", []string{"//"}}
+	langCSharp = &language{"C#", []string{"//"}}
+	langJava   = &language{"Java", []string{"//"}}
+	langKotlin = &language{"Kotlin", []string{"//"}}
+	langScala  = &language{"Scala", []string{"//"}}
+	langSwift  = &language{"Swift", []string{"//"}}
+	langRust   = &language{"Rust", []string{"//"}}
+	langJS     = &language{"JavaScript", []string{"//"}}
+	langTS     = &language{"TypeScript", []string{"//"}}
+	langPHP    = &language{"PHP", []string{"//", "#"}}
+	langPython = &language{"Python", []string{"#"}}
+	langRuby   = &language{"Ruby", []string{"#"}}
+	langPerl   = &language{"Perl", []string{"#"}}
+	langShell  = &language{"Shell", []string{"#"}}
+	langR      = &language{"R", []string{"#"}}
+	langElixir = &language{"Elixir", []string{"#"}}
+	langMake   = &language{"Makefile", []string{"#"}}
+	langDocker = &language{"Dockerfile", []string{"#"}}
+	langYAML   = &language{"YAML", []string{"#"}}
+	langTOML   = &language{"TOML", []string{"#"}}
+	langLua    = &language{"Lua", []string{"--"}}
+	langSQL    = &language{"SQL", []string{"--"}}
+	langHs     = &language{"Haskell", []string{"--"}}
+	langErlang = &language{"Erlang", []string{"%"}}
+	langHTML   = &language{"HTML", nil}
+	langCSS    = &language{"CSS", nil}
+	langMD     = &language{"Markdown", nil}
+	langJSON   = &language{"JSON", nil}
+)
+
+// langByExt finds languages by the extensions of their files
+var langByExt = map[string]*language{
+	".go": langGo,
+	".c":  langC, ".h": langC,
+	".cc": langCPP, ".cpp": langCPP, ".cxx": langCPP, ".hh": langCPP, ".hpp": langCPP,
+	".cs":   langCSharp,
+	".java": langJava,
+	".kt":   langKotlin, ".kts": langKotlin,
+	".scala": langScala,
+	".swift": langSwift,
+	".rs":    langRust,
+	".js":    langJS, ".mjs": langJS, ".cjs": langJS, ".jsx": langJS,
+	".ts": langTS, ".tsx": langTS,
+	".php": langPHP,
+	".py":  langPython,
+	".rb":  langRuby,
+	".pl":  langPerl, ".pm": langPerl,
+	".sh": langShell, ".bash": langShell, ".zsh": langShell,
+	".r":  langR,
+	".ex": langElixir, ".exs": langElixir,
+	".mk":  langMake,
+	".yml": langYAML, ".yaml": langYAML,
+	".toml": langTOML,
+	".lua":  langLua,
+	".sql":  langSQL,
+	".hs":   langHs,
+	".erl":  langErlang,
+	".html": langHTML, ".htm": langHTML,
+	".css":  langCSS,
+	".md":   langMD,
+	".json": langJSON,
+}
+
+// langByName finds languages by the whole names of their files
+var langByName = map[string]*language{
+	"Makefile":    langMake,
+	"makefile":    langMake,
+	"GNUmakefile": langMake,
+	"Dockerfile":  langDocker,
+}
+
+// langByInterpreter finds languages by the interpreters in shebang lines
+var langByInterpreter = map[string]*language{
+	"sh": langShell, "bash": langShell, "zsh": langShell, "dash": langShell, "ksh": langShell,
+	"python":  langPython,
+	"ruby":    langRuby,
+	"perl":    langPerl,
+	"node":    langJS,
+	"lua":     langLua,
+	"php":     langPHP,
+	"Rscript": langR,
+}
+
+// shebangLanguage finds the language of a script from its first line,
+// such as "#!/usr/bin/env python3"
+func shebangLanguage(line string) *language {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if !strings.HasPrefix(line, "#!") || len(fields) == 0 {
+		return nil
+	}
+
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		interp = path.Base(fields[0])
+	}
+
+	return langByInterpreter[strings.TrimRight(interp, "0123456789.")]
+}
+
+// CodeCounter is a Processor which finds the language of source files, by
+// their names or their shebang lines, and counts their lines, annotating
+// them with their CodeStats; see CodeOf and CodeByLanguage.
+type CodeCounter struct{}
+
+var _ Processor = CodeCounter{}
+
+// Wants reports whether l is named as source code is, or might be a
+// script
+func (CodeCounter) Wants(l *Leaf) bool {
+	if langByName[l.name] != nil || langByExt[strings.ToLower(path.Ext(l.name))] != nil {
+		return true
+	}
+	return path.Ext(l.name) == "" && (*l.info).Mode()&0o111 != 0
+}
+
+// Process counts the lines of l
+func (CodeCounter) Process(l *Leaf, contents io.Reader) error {
+	br := bufio.NewReader(contents)
+	if head, _ := br.Peek(512); bytes.IndexByte(head, 0) >= 0 {
+		return nil // binary
+	}
+
+	lang := langByName[l.name]
+	if lang == nil {
+		lang = langByExt[strings.ToLower(path.Ext(l.name))]
+	}
+
+	cs := CodeStats{Files: 1}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if cs.Lines == 0 && lang == nil {
+			if lang = shebangLanguage(line); lang == nil {
+				return nil // not source code
+			}
+		}
+		cs.Lines++
+
+		switch {
+		case line == "":
+			cs.Blank++
+		case lang.isComment(line):
+			cs.Comment++
+		default:
+			cs.Code++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil // not source code either
+		}
+		return err
+	}
+	if lang == nil {
+		return nil // an empty file with no telling name
+	}
+
+	cs.Language = lang.name
+	l.Annotate(CodeAnnotation, cs)
+	return nil
+}
+
+func (lang *language) isComment(line string) bool {
+	for _, c := range lang.comments {
+		if strings.HasPrefix(line, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// CodeOf returns what a CodeCounter found in l
+func CodeOf(l *Leaf) (CodeStats, bool) {
+	var cs CodeStats
+	ok, err := l.Annotation(CodeAnnotation, &cs)
+	return cs, ok && err == nil
+}
+
+// CodeByLanguage totals what a CodeCounter found beneath dn, by language
+func CodeByLanguage(dn *DNode) map[string]CodeStats {
+	totals := map[string]CodeStats{}
+
+	dn.walk(func(_ string, n Node) bool {
+		if l, ok := n.(*Leaf); ok {
+			if cs, ok := CodeOf(l); ok {
+				total := totals[cs.Language]
+				total.Language = cs.Language
+				total.add(cs)
+				totals[cs.Language] = total
+			}
+		}
+		return true
+	})
+
+	return totals
+}
@@ -0,0 +1,84 @@
+package ctree
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShebangLanguage(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/sh":                        "Shell",
+		"#!/usr/bin/env python3":           "Python",
+		"#! /usr/bin/python3.11 -u":        "Python",
+		"#!/usr/bin/env -S node --harmony": "JavaScript",
+		"#!/usr/bin/perl -w":               "Perl",
+		"#!/usr/bin/env":                   "",
+		"#!/usr/bin/frobnicate":            "",
+		"# just a comment":                 "",
+	}
+
+	for line, want := range tests {
+		lang := shebangLanguage(line)
+		if want == "" {
+			assert.Nil(t, lang, line)
+		} else if assert.NotNil(t, lang, line) {
+			assert.Equal(t, want, lang.name, line)
+		}
+	}
+}
+
+func TestCodeCounter(t *testing.T) {
+	mfs := fstest.MapFS{
+		"main.go":        {Data: []byte("package main\n\n// main does nothing\nfunc main() {\n}\n")},
+		"lib/util.go":    {Data: []byte("package lib\n\n\n  // indented\nvar x = 1 // trailing\n")},
+		"lib/build.sh":   {Data: []byte("#!/bin/sh\n# build it\ngo build\n")},
+		"bin/run":        {Data: []byte("#!/usr/bin/env python3\nprint('hi')\n"), Mode: 0o755},
+		"bin/tool":       {Data: []byte("\x7fELF\x00\x00"), Mode: 0o755},
+		"bin/notes":      {Data: []byte("not a script\n"), Mode: 0o755},
+		"Makefile":       {Data: []byte("# make\nall:\n\tgo build\n")},
+		"README":         {Data: []byte("read me\n")},
+		"data.bin":       {Data: []byte("\x00\x01\x02")},
+		"lib/binary.go":  {Data: []byte("\x00\x01\x02")},
+		"lib/no-newline": {Data: []byte("#!/bin/bash\necho hi"), Mode: 0o700},
+	}
+
+	r := NewRoot(".")
+	r.FS = mfs
+	r.Processors = []Processor{CodeCounter{}}
+	dn, err := r.Run()
+	require.NoError(t, err)
+	assert.Empty(t, dn.Errors())
+
+	want := map[string]CodeStats{
+		"main.go":        {Language: "Go", Files: 1, Lines: 5, Blank: 1, Comment: 1, Code: 3},
+		"lib/util.go":    {Language: "Go", Files: 1, Lines: 5, Blank: 2, Comment: 1, Code: 2},
+		"lib/build.sh":   {Language: "Shell", Files: 1, Lines: 3, Comment: 2, Code: 1},
+		"bin/run":        {Language: "Python", Files: 1, Lines: 2, Comment: 1, Code: 1},
+		"Makefile":       {Language: "Makefile", Files: 1, Lines: 3, Comment: 1, Code: 2},
+		"lib/no-newline": {Language: "Shell", Files: 1, Lines: 2, Comment: 1, Code: 1},
+	}
+	for name := range mfs {
+		cs, ok := CodeOf(dn.Lookup(name).(*Leaf))
+		if w, found := want[name]; found {
+			assert.True(t, ok, name)
+			assert.Equal(t, w, cs, name)
+		} else {
+			assert.False(t, ok, name)
+		}
+	}
+
+	assert.Equal(t, map[string]CodeStats{
+		"Go":       {Language: "Go", Files: 2, Lines: 10, Blank: 3, Comment: 2, Code: 5},
+		"Shell":    {Language: "Shell", Files: 2, Lines: 5, Comment: 3, Code: 2},
+		"Python":   {Language: "Python", Files: 1, Lines: 2, Comment: 1, Code: 1},
+		"Makefile": {Language: "Makefile", Files: 1, Lines: 3, Comment: 1, Code: 2},
+	}, CodeByLanguage(dn))
+
+	assert.Equal(t, map[string]CodeStats{
+		"Go":    {Language: "Go", Files: 1, Lines: 5, Blank: 2, Comment: 1, Code: 2},
+		"Shell": {Language: "Shell", Files: 2, Lines: 5, Comment: 3, Code: 2},
+	}, CodeByLanguage(dn.Lookup("lib").(*DNode)))
+}
@@ -0,0 +1,115 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// CompactOptions controls Compact
+type CompactOptions struct {
+	// DropInfo replaces the FileInfo of every node, which retains
+	// everything the platform said about it, with a smaller one holding
+	// just what ctree reads: the node's size, mode, modification time,
+	// owner, link count, blocks, device, inode, and change and birth
+	// times
+	DropInfo bool
+
+	// Sort sorts the children and leaves of every directory by name, in
+	// Order, or ByteOrder if it is nil
+	Sort  bool
+	Order NameOrder
+}
+
+// Compact trims the memory held by the tree at dn, so that services
+// keeping trees cached for a long time keep no more than they need: the
+// slices of every directory are cut to their length, and what was only
+// needed during the walk is let go. opts may be nil. Compact changes the
+// tree in place, so it must not be used at the same time as anything
+// else using the tree; the tree is otherwise as it was.
+func (dn *DNode) Compact(opts *CompactOptions) {
+	if opts == nil {
+		opts = &CompactOptions{}
+	}
+
+	dn.children = clip(dn.children)
+	dn.leaves = clip(dn.leaves)
+	dn.tags = clip(dn.tags)
+	dn.budget = nil
+
+	if opts.Sort {
+		order := opts.Order
+		if order == nil {
+			order = ByteOrder
+		}
+		sort.Slice(dn.children, func(i, j int) bool {
+			return order(dn.children[i].name, dn.children[j].name)
+		})
+		sort.Slice(dn.leaves, func(i, j int) bool {
+			return order(dn.leaves[i].name, dn.leaves[j].name)
+		})
+	}
+	if opts.DropInfo {
+		dn.info = compactInfoOf(dn.name, dn.info)
+	}
+
+	for _, l := range dn.leaves {
+		l.tags = clip(l.tags)
+		if opts.DropInfo {
+			l.info = compactInfoOf(l.name, l.info)
+		}
+	}
+	for _, child := range dn.children {
+		child.Compact(opts)
+	}
+}
+
+// clip returns s with no more capacity than its length, copying it if
+// it had more
+func clip[T any](s []T) []T {
+	if cap(s) == len(s) {
+		return s
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+// compactInfo is a FileInfo holding only what ctree reads of another
+type compactInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	stat    statInfo
+}
+
+var _ fs.FileInfo = &compactInfo{}
+
+func (ci *compactInfo) Name() string       { return ci.name }
+func (ci *compactInfo) Size() int64        { return ci.size }
+func (ci *compactInfo) Mode() fs.FileMode  { return ci.mode }
+func (ci *compactInfo) ModTime() time.Time { return ci.modTime }
+func (ci *compactInfo) IsDir() bool        { return ci.mode.IsDir() }
+func (ci *compactInfo) Sys() any           { return &ci.stat }
+
+// compactInfoOf returns a compactInfo of fi, whose name is name, so that
+// the node's own name is shared. FileInfos which ctree made itself are
+// already small, and may mean more than their statInfo, so they are kept.
+func compactInfoOf(name string, fi *os.FileInfo) *os.FileInfo {
+	switch (*fi).(type) {
+	case *compactInfo, *nodeInfo:
+		return fi
+	}
+
+	var ci os.FileInfo = &compactInfo{
+		name:    name,
+		size:    (*fi).Size(),
+		mode:    (*fi).Mode(),
+		modTime: (*fi).ModTime(),
+		stat:    statOf(*fi),
+	}
+	return &ci
+}
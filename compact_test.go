@@ -0,0 +1,90 @@
+package ctree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	// describe records everything ctree can tell of each node of dn
+	describe := func(dn *DNode) map[string]string {
+		m := map[string]string{}
+		dn.walk(func(rel string, n Node) bool {
+			fi := *n.Info()
+			uid, gid, _ := Owner(n)
+			ctime, _ := ChangeTime(n)
+			btime, _ := BirthTime(n)
+			m[rel] = fmt.Sprint(fi.Name(), fi.Size(), fi.Mode(), fi.ModTime(),
+				uid, gid, ctime, btime, n.Tags())
+			return true
+		})
+		return m
+	}
+
+	t.Run("the tree is as it was", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		dn.Lookup("ceswift/.cshrc").AddTag("rc")
+		before := describe(dn)
+
+		dn.Compact(&CompactOptions{DropInfo: true, Sort: true})
+		assert.Equal(before, describe(dn))
+
+		dn.walk(func(rel string, n Node) bool {
+			assert.IsType(&compactInfo{}, *n.Info(), rel)
+			if dn, ok := n.(*DNode); ok {
+				assert.Equal(len(dn.children), cap(dn.children), rel)
+				assert.Equal(len(dn.leaves), cap(dn.leaves), rel)
+				assert.True(sortedNodes(dn), rel)
+			}
+			return true
+		})
+
+		dn.Compact(nil)
+		assert.Equal(before, describe(dn), "compacting again changes nothing")
+	})
+
+	t.Run("nothing is lost", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		short := dn.Truncate(1, 0)
+		short.Compact(&CompactOptions{DropInfo: true})
+
+		n, ok := Elided(short.Lookup("…and 1 more"))
+		assert.True(ok)
+		assert.Equal(1, n)
+	})
+
+	t.Run("by the Root", func(t *testing.T) {
+		require := require.New(t)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		r.Compact = &CompactOptions{DropInfo: true}
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.IsType(t, &compactInfo{}, *dn.Info())
+		assert.Equal(t, "home", (*dn.Info()).Name())
+	})
+}
+
+// sortedNodes reports whether the children and leaves of dn are in order
+// of name
+func sortedNodes(dn *DNode) bool {
+	for i := 1; i < len(dn.children); i++ {
+		if dn.children[i-1].name > dn.children[i].name {
+			return false
+		}
+	}
+	for i := 1; i < len(dn.leaves); i++ {
+		if dn.leaves[i-1].name > dn.leaves[i].name {
+			return false
+		}
+	}
+	return true
+}
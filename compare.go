@@ -0,0 +1,139 @@
+package ctree
+
+import "time"
+
+// Comparer reports whether two nodes found at the same path should be
+// considered the same
+type Comparer func(a, b Node) bool
+
+// DefaultComparer is the Comparer used by Diff and Diff3. Nodes are the
+// same if they have the same mode and, unless they are directories, the
+// same size and modification time.
+func DefaultComparer(a, b Node) bool {
+	return SameMode(a, b) && SameSize(a, b) && SameModTime(a, b)
+}
+
+// AllOf returns a Comparer which considers nodes the same only if every
+// one of cmps does
+func AllOf(cmps ...Comparer) Comparer {
+	return func(a, b Node) bool {
+		for _, cmp := range cmps {
+			if !cmp(a, b) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SameType compares the type bits of the nodes' modes, ignoring
+// permissions
+func SameType(a, b Node) bool {
+	return (*a.Info()).Mode().Type() == (*b.Info()).Mode().Type()
+}
+
+// SameMode compares the nodes' modes, including permissions
+func SameMode(a, b Node) bool {
+	return (*a.Info()).Mode() == (*b.Info()).Mode()
+}
+
+// SameSize compares the sizes of the nodes. Directories are always the
+// same size, since their size only reflects their contents.
+func SameSize(a, b Node) bool {
+	ai, bi := *a.Info(), *b.Info()
+	if ai.IsDir() && bi.IsDir() {
+		return true
+	}
+	return ai.Size() == bi.Size()
+}
+
+// SameModTime compares the modification times of the nodes. Directories
+// always have the same modification time, since it changes whenever their
+// contents do.
+func SameModTime(a, b Node) bool {
+	ai, bi := *a.Info(), *b.Info()
+	if ai.IsDir() && bi.IsDir() {
+		return true
+	}
+	return ai.ModTime().Equal(bi.ModTime())
+}
+
+// Resolutions of the modification times kept by filesystems coarser than
+// the ones trees are usually scanned from, for use with SameModTimeWithin
+const (
+	// FATTimeResolution is that of FAT, which keeps only even seconds
+	FATTimeResolution = 2 * time.Second
+	// ExFATTimeResolution is that of exFAT
+	ExFATTimeResolution = 10 * time.Millisecond
+)
+
+// SameModTimeWithin returns a Comparer like SameModTime, except that
+// modification times no more than tolerance apart are the same. Use it to
+// compare a tree with a copy kept on a filesystem which keeps coarser
+// times, such as FAT, where tolerance would be FATTimeResolution, or to
+// ignore sub-second differences, with a tolerance of a second.
+func SameModTimeWithin(tolerance time.Duration) Comparer {
+	return func(a, b Node) bool {
+		ai, bi := *a.Info(), *b.Info()
+		if ai.IsDir() && bi.IsDir() {
+			return true
+		}
+
+		d := ai.ModTime().Sub(bi.ModTime())
+		return d <= tolerance && d >= -tolerance
+	}
+}
+
+// DefaultComparerWithin returns a Comparer like DefaultComparer, except
+// that it compares modification times with SameModTimeWithin
+func DefaultComparerWithin(tolerance time.Duration) Comparer {
+	return AllOf(SameMode, SameSize, SameModTimeWithin(tolerance))
+}
+
+// SameChangeTime compares the change times of the nodes, which catch
+// changes to metadata, such as permissions and ownership, that leave the
+// modification time alone. Directories, and nodes whose change times are
+// not known, are always the same. Copies of a tree never have the same
+// change times as the original, so only compare scans of one tree.
+func SameChangeTime(a, b Node) bool {
+	return sameTime(a, b, ChangeTime)
+}
+
+// SameBirthTime compares the birth times of the nodes, which tell a file
+// from one that replaced it. Directories, and nodes whose birth times are
+// not known, are always the same.
+func SameBirthTime(a, b Node) bool {
+	return sameTime(a, b, BirthTime)
+}
+
+// SameTags compares the tags of the nodes
+func SameTags(a, b Node) bool {
+	at, bt := a.Tags(), b.Tags()
+	if len(at) != len(bt) {
+		return false
+	}
+	for i := range at {
+		if at[i] != bt[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameTime(a, b Node, when func(Node) (time.Time, bool)) bool {
+	if (*a.Info()).IsDir() && (*b.Info()).IsDir() {
+		return true
+	}
+	at, aok := when(a)
+	bt, bok := when(b)
+	return !aok || !bok || at.Equal(bt)
+}
+
+// orMissing is like calling cmp, except that either node may be nil;
+// two missing nodes are the same
+func (cmp Comparer) orMissing(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return cmp(a, b)
+}
@@ -0,0 +1,46 @@
+package ctree
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewZstdWriter returns a writer which compresses what is written to it
+// with zstd, and writes it to w. It must be closed to finish the stream.
+// ReadSnapshot, ReadJournal, and the rest of the readers decompress such
+// streams, and gzip ones, without being told.
+func NewZstdWriter(w io.Writer) io.WriteCloser {
+	// which fails only for bad options
+	zw, _ := zstd.NewWriter(w)
+	return zw
+}
+
+// decompress returns a reader of what r holds, decompressing it if it
+// starts with the magic number of gzip or zstd
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		// with no concurrency, it decodes as it is read, and starts no
+		// goroutines needing to be closed
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
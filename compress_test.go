@@ -0,0 +1,54 @@
+package ctree
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressed(t *testing.T) {
+	compressors := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"zstd": NewZstdWriter,
+	}
+
+	for name, compress := range compressors {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			assert := assert.New(t)
+
+			dn := scanCopy(t, nil)
+
+			var plain, buf bytes.Buffer
+			require.NoError(WriteSnapshot(&plain, dn))
+			zw := compress(&buf)
+			require.NoError(WriteSnapshot(zw, dn))
+			require.NoError(zw.Close())
+			assert.NotEqual(plain.Bytes(), buf.Bytes())
+
+			read, err := ReadSnapshot(&buf)
+			require.NoError(err)
+			assert.Empty(Diff(dn, read))
+
+			r := NewRoot(dn.Path())
+			zw = compress(&buf)
+			r.Journal = zw
+			dn, err = r.Run()
+			require.NoError(err)
+			require.NoError(zw.Close())
+
+			read, err = ReadJournal(&buf)
+			require.NoError(err)
+			assert.Empty(Diff(dn, read))
+		})
+	}
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, err := ReadSnapshot(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0xff}))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,183 @@
+package ctree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a recurring scan runs next
+type Schedule interface {
+	// Next returns the first time after after that the scan should
+	// run, or the zero time if it never should
+	Next(after time.Time) time.Time
+}
+
+// Every returns a Schedule that recurs every d, aligned to multiples of d
+// since the zero time, so that Every(time.Hour) runs on the hour
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+	d := time.Duration(e)
+	if d <= 0 {
+		return time.Time{}
+	}
+	return after.Truncate(d).Add(d)
+}
+
+// Cron is a Schedule parsed from a crontab-style expression
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// a field given as "*" matches anything; when day of month and day
+	// of week are both restricted, either may match, as with cron
+	domAny, dowAny bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a five field crontab expression: minute, hour, day of
+// month, month, and day of week. Fields may be "*", numbers, ranges
+// ("1-5"), lists ("1,15"), and steps ("*/15", "0-30/10"). Sunday is day 0
+// or 7. The macros @hourly, @daily, @weekly, @monthly, and @yearly are
+// also accepted.
+func ParseCron(spec string) (*Cron, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q: cron expressions have 5 fields", spec)
+	}
+
+	c := &Cron{
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+
+	var err error
+	parsers := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, p := range parsers {
+		*p.bits, err = parseCronField(fields[i], p.min, p.max)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", spec, err)
+		}
+	}
+
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			l, h, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(l); err != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+			if hi, err = strconv.Atoi(h); err != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			lo, hi = n, n
+			if hasStep {
+				hi = max
+			}
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step %q", part)
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+// cronHorizon is how far ahead Next looks before giving up
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// Next returns the first minute after after which matches the expression
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(cronHorizon)
+
+	for t.Before(end) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,33 @@
+package ctree
+
+import "fmt"
+
+// fileID identifies a file by its device and inode
+type fileID struct {
+	dev, ino uint64
+}
+
+// revisit records dn as walked, and returns an error if a directory with
+// the same device and inode has already been walked in this Run, as
+// happens with bind mount loops and corrupt filesystems
+func (r *Root) revisit(dn *DNode) error {
+	si := statOf(*dn.info)
+	if !si.hasDev {
+		return nil
+	}
+	id := fileID{si.dev, si.ino}
+
+	r.visitedMu.Lock()
+	defer r.visitedMu.Unlock()
+
+	if first, ok := r.visited[id]; ok {
+		return &NodeError{
+			Path: dn.path,
+			Kind: ErrCycle,
+			Err:  fmt.Errorf("same directory as %s", first),
+		}
+	}
+	r.visited[id] = dn.path
+
+	return nil
+}
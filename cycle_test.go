@@ -0,0 +1,99 @@
+package ctree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopFS is a directory holding a file and "loop", which is a directory
+// on the same device, with the same inode, as the one holding it, and so
+// on forever
+type loopFS struct{}
+
+func (loopFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (loopFS) Stat(name string) (fs.FileInfo, error) {
+	return loopInfo(path.Base(name), fs.ModeDir), nil
+}
+
+func (loopFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return []fs.DirEntry{
+		fs.FileInfoToDirEntry(loopInfo("f", 0)),
+		fs.FileInfoToDirEntry(loopInfo("loop", fs.ModeDir)),
+	}, nil
+}
+
+func loopInfo(name string, mode fs.FileMode) fs.FileInfo {
+	ino := uint64(1)
+	if !mode.IsDir() {
+		ino = 2
+	}
+	return &nodeInfo{
+		name: name,
+		mode: mode,
+		sys:  &statInfo{dev: 1, ino: ino, hasDev: true},
+	}
+}
+
+func TestCycles(t *testing.T) {
+	t.Run("loops are walked once", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		r := NewRoot(".")
+		r.FS = loopFS{}
+		r.MaxDepth = 100 // in case they aren't
+
+		done := make(chan struct{})
+		var dn *DNode
+		var err error
+		go func() {
+			dn, err = r.Run()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("walk did not finish")
+		}
+		require.NoError(err)
+
+		assert.Equal(3, dn.TotalLength())
+		errs := dn.Errors()
+		require.Len(errs, 1)
+		assert.ErrorIs(errs[0], ErrCycle)
+		assert.Equal("loop: same directory as .", errs[0].Error())
+		assert.Equal("loop", dn.Lookup("loop").Path())
+		assert.Empty(dn.Lookup("loop").(*DNode).Entries())
+	})
+
+	t.Run("directories without identities are all walked", func(t *testing.T) {
+		mfs := fstest.MapFS{}
+		for _, p := range []string{"a/b/f", "c/b/f", "a/c/f"} {
+			mfs[p] = &fstest.MapFile{}
+		}
+
+		r := NewRoot(".")
+		r.FS = mfs
+		dn, err := r.Run()
+		require.NoError(t, err)
+		assert.Empty(t, dn.Errors())
+		assert.Equal(t, 1+5+3, dn.TotalLength())
+	})
+
+	t.Run("a real tree has no cycles", func(t *testing.T) {
+		dn := scanCopy(t, nil)
+		for _, err := range dn.Errors() {
+			assert.NotErrorIs(t, err, ErrCycle)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package ctree
+
+import (
+	"sort"
+)
+
+// ChangeKind describes how a path differs between two trees
+type ChangeKind int
+
+const (
+	// Added means the path only exists in the newer tree
+	Added ChangeKind = iota + 1
+	// Removed means the path only exists in the older tree
+	Removed
+	// Modified means the path exists in both trees, but differs
+	Modified
+	// Renamed means the node at OldPath in the older tree is at Path in
+	// the newer one; see DiffRenames
+	Renamed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case Renamed:
+		return "renamed"
+	}
+	return "unknown"
+}
+
+// Change describes one path that differs between two trees. Path is
+// relative to the roots of the trees being compared, so trees scanned at
+// different locations can be compared. OldPath is the path in the older
+// tree, if it is not Path, as when the node was renamed.
+type Change struct {
+	Path    string
+	OldPath string
+	Kind    ChangeKind
+	Old     Node
+	New     Node
+}
+
+// Diff compares two trees, returning the changes needed to get from the
+// old tree to the new one, sorted by path. Nodes are compared with
+// DefaultComparer.
+func Diff(old, cur *DNode) []Change {
+	return DiffFunc(old, cur, DefaultComparer)
+}
+
+// DiffFunc is like Diff, but nodes found at the same path are compared
+// with same
+func DiffFunc(old, cur *DNode, same Comparer) []Change {
+	changes := []Change{}
+
+	ZipWalk(old, cur, func(rel string, o, n Node) error {
+		switch {
+		case o == nil:
+			changes = append(changes, Change{Path: rel, Kind: Added, New: n})
+		case n == nil:
+			changes = append(changes, Change{Path: rel, Kind: Removed, Old: o})
+		case !same(o, n):
+			changes = append(changes,
+				Change{Path: rel, Kind: Modified, Old: o, New: n})
+		}
+		return nil
+	})
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// Diff3Kind classifies a path in a three-way comparison
+type Diff3Kind int
+
+const (
+	// Unchanged means neither side changed the path
+	Unchanged Diff3Kind = iota
+	// ChangedInA means only the A side changed the path
+	ChangedInA
+	// ChangedInB means only the B side changed the path
+	ChangedInB
+	// ChangedInBoth means both sides made the same change to the path
+	ChangedInBoth
+	// Conflict means both sides changed the path, in different ways
+	Conflict
+)
+
+func (k Diff3Kind) String() string {
+	switch k {
+	case Unchanged:
+		return "unchanged"
+	case ChangedInA:
+		return "changed in a"
+	case ChangedInB:
+		return "changed in b"
+	case ChangedInBoth:
+		return "changed in both"
+	case Conflict:
+		return "conflict"
+	}
+	return "unknown"
+}
+
+// Diff3Entry describes one path in a three-way comparison. Any of the
+// nodes may be nil if the path does not exist in that tree.
+type Diff3Entry struct {
+	Path string
+	Kind Diff3Kind
+	Base Node
+	A    Node
+	B    Node
+}
+
+// Diff3 compares two trees, a and b, which were both derived from base.
+// Every path in any of the three trees is classified, and the entries are
+// returned sorted by path. Nodes are compared with DefaultComparer.
+func Diff3(base, a, b *DNode) []Diff3Entry {
+	return Diff3Func(base, a, b, DefaultComparer)
+}
+
+// Diff3Func is like Diff3, but nodes found at the same path are compared
+// with same
+func Diff3Func(base, a, b *DNode, same Comparer) []Diff3Entry {
+	inBase := index(base)
+	inA := index(a)
+	inB := index(b)
+
+	paths := map[string]struct{}{}
+	for _, m := range []map[string]Node{inBase, inA, inB} {
+		for rel := range m {
+			paths[rel] = struct{}{}
+		}
+	}
+
+	entries := make([]Diff3Entry, 0, len(paths))
+	for rel := range paths {
+		e := Diff3Entry{Path: rel}
+		e.Base, e.A, e.B = inBase[rel], inA[rel], inB[rel]
+
+		changedA := !same.orMissing(e.Base, e.A)
+		changedB := !same.orMissing(e.Base, e.B)
+		switch {
+		case changedA && changedB:
+			e.Kind = Conflict
+			if same.orMissing(e.A, e.B) {
+				e.Kind = ChangedInBoth
+			}
+		case changedA:
+			e.Kind = ChangedInA
+		case changedB:
+			e.Kind = ChangedInB
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries
+}
+
+// index maps the path of every node beneath dn, relative to dn, to its node
+func index(dn *DNode) map[string]Node {
+	m := map[string]Node{}
+
+	dn.walk(func(rel string, n Node) bool {
+		m[rel] = n
+		return true
+	})
+
+	return m
+}
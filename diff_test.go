@@ -0,0 +1,173 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+// scanCopy builds a fresh copy of ttree, with every mtime set to epoch so
+// that separate copies compare equal, then lets edit change it before
+// scanning
+func scanCopy(t *testing.T, edit func(where string)) *DNode {
+	require := require.New(t)
+
+	where := t.TempDir()
+	ttree.build(t, where)
+	err := filepath.WalkDir(where, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, epoch, epoch)
+	})
+	require.NoError(err)
+
+	if edit != nil {
+		edit(path.Join(where, "home"))
+	}
+
+	dn, err := NewRoot(path.Join(where, "home")).Run()
+	require.NoError(err)
+	require.NotNil(dn)
+
+	return dn
+}
+
+func writeFile(t *testing.T, where, contents string) {
+	err := os.WriteFile(where, []byte(contents), 0666)
+	require.NoError(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	t.Run("identical trees", func(t *testing.T) {
+		assert := assert.New(t)
+
+		a := scanCopy(t, nil)
+		b := scanCopy(t, nil)
+		assert.Empty(Diff(a, b))
+	})
+
+	t.Run("changes are found", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		a := scanCopy(t, nil)
+		b := scanCopy(t, func(where string) {
+			writeFile(t, path.Join(where, "ceswift", ".cshrc"), "echo bye")
+			writeFile(t, path.Join(where, "ceswift", "new"), "hi")
+			require.NoError(os.RemoveAll(path.Join(where, "wsfitzpa", "bin")))
+		})
+
+		changes := Diff(a, b)
+		require.Len(changes, 4)
+
+		assert.Equal("ceswift/.cshrc", changes[0].Path)
+		assert.Equal(Modified, changes[0].Kind)
+		assert.Equal("ceswift/new", changes[1].Path)
+		assert.Equal(Added, changes[1].Kind)
+		assert.Nil(changes[1].Old)
+		assert.Equal("wsfitzpa/bin", changes[2].Path)
+		assert.Equal(Removed, changes[2].Kind)
+		assert.Nil(changes[2].New)
+		assert.Equal("wsfitzpa/bin/zrun", changes[3].Path)
+		assert.Equal(Removed, changes[3].Kind)
+	})
+}
+
+func TestDiffFunc(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	now := time.Now()
+	a := scanCopy(t, nil)
+	b := scanCopy(t, func(where string) {
+		require.NoError(os.Chtimes(path.Join(where, "ceswift", ".cshrc"), now, now))
+		require.NoError(os.Chmod(path.Join(where, "wsfitzpa", ".cshrc"), 0600))
+	})
+
+	changes := Diff(a, b)
+	require.Len(changes, 2)
+	assert.Equal("ceswift/.cshrc", changes[0].Path)
+	assert.Equal("wsfitzpa/.cshrc", changes[1].Path)
+
+	noMTime := DiffFunc(a, b, AllOf(SameMode, SameSize))
+	require.Len(noMTime, 1)
+	assert.Equal("wsfitzpa/.cshrc", noMTime[0].Path)
+
+	noMode := DiffFunc(a, b, AllOf(SameType, SameSize, SameModTime))
+	require.Len(noMode, 1)
+	assert.Equal("ceswift/.cshrc", noMode[0].Path)
+
+	everything := func(Node, Node) bool { return true }
+	assert.Empty(DiffFunc(a, b, everything))
+	for _, e := range Diff3Func(a, b, b, everything) {
+		assert.Equal(Unchanged, e.Kind)
+	}
+}
+
+func TestSameModTimeWithin(t *testing.T) {
+	assert := assert.New(t)
+
+	at := func(when time.Time) func(string) {
+		return func(where string) {
+			p := path.Join(where, "ceswift", ".cshrc")
+			require.NoError(t, os.Chtimes(p, when, when))
+		}
+	}
+	a := scanCopy(t, at(epoch.Add(123456789)))
+	b := scanCopy(t, at(epoch.Add(999999999)))
+	c := scanCopy(t, at(epoch.Add(2*time.Second)))
+
+	assert.Len(Diff(a, b), 1)
+	assert.Empty(DiffFunc(a, b, DefaultComparerWithin(time.Second)))
+	assert.Len(DiffFunc(a, c, DefaultComparerWithin(time.Second)), 1)
+	assert.Empty(DiffFunc(a, c, DefaultComparerWithin(FATTimeResolution)))
+	assert.Empty(DiffFunc(c, a, DefaultComparerWithin(FATTimeResolution)))
+	assert.Len(DiffFunc(a, b, SameModTimeWithin(ExFATTimeResolution)), 1)
+}
+
+func TestDiff3(t *testing.T) {
+	assert := assert.New(t)
+
+	// both sides make the same edit to wsfitzpa/.cshrc, but conflicting
+	// edits to wsfitzpa/bin/zrun
+	both := func(where, zrun string) {
+		for p, contents := range map[string]string{
+			"wsfitzpa/.cshrc":   "echo same",
+			"wsfitzpa/bin/zrun": zrun,
+		} {
+			writeFile(t, path.Join(where, p), contents)
+			require.NoError(t, os.Chtimes(path.Join(where, p), epoch, epoch))
+		}
+	}
+
+	base := scanCopy(t, nil)
+	a := scanCopy(t, func(where string) {
+		writeFile(t, path.Join(where, "ceswift", ".cshrc"), "echo a")
+		both(where, "a")
+	})
+	b := scanCopy(t, func(where string) {
+		writeFile(t, path.Join(where, "ceswift", "bin", "worms"), "b")
+		both(where, "bb")
+	})
+
+	kinds := map[string]Diff3Kind{}
+	for _, e := range Diff3(base, a, b) {
+		kinds[e.Path] = e.Kind
+	}
+
+	assert.Equal(Unchanged, kinds["ceswift"])
+	assert.Equal(Unchanged, kinds["ceswift/bin"])
+	assert.Equal(ChangedInA, kinds["ceswift/.cshrc"])
+	assert.Equal(ChangedInB, kinds["ceswift/bin/worms"])
+	assert.Equal(ChangedInBoth, kinds["wsfitzpa/.cshrc"])
+	assert.Equal(Conflict, kinds["wsfitzpa/bin/zrun"])
+}
@@ -0,0 +1,118 @@
+package ctree
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// The kinds of error found at nodes, for use with errors.Is
+var (
+	// ErrPermission means a node could not be read for lack of
+	// permission
+	ErrPermission = errors.New("permission denied")
+	// ErrNotDir means a path which should be a directory is not
+	ErrNotDir = errors.New("not a directory")
+	// ErrVanished means a node was removed while the tree was walked
+	ErrVanished = errors.New("vanished during the walk")
+	// ErrTooDeep means a directory was not read, because it is deeper
+	// than Root.MaxDepth
+	ErrTooDeep = errors.New("deeper than the maximum depth")
+	// ErrLimitReached means a directory was not read, or not read to
+	// the end, because the walk reached Root.MaxNodes or Root.MaxBytes
+	ErrLimitReached = errors.New("walk reached its node or byte limit")
+	// ErrCycle means a directory was not read, because it is one that
+	// was already walked, reached again through a bind mount or a
+	// corrupt filesystem
+	ErrCycle = errors.New("directory already walked")
+	// ErrIncomplete means a directory read from a journal was not
+	// finished before the walk writing it stopped
+	ErrIncomplete = errors.New("walk did not finish")
+	// ErrAccessTime means a node was not read, because Root.ReadOnly is
+	// set, and it could not be read without changing its access time
+	ErrAccessTime = errors.New("would change the access time")
+)
+
+// NodeError is an error at a node of the tree. It matches its Kind, one
+// of the Err variables of this package, if it has one, and the error
+// that caused it, with errors.Is and errors.As.
+type NodeError struct {
+	Path string
+	Kind error
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	if e.Err == nil {
+		return e.Path + ": " + e.Kind.Error()
+	}
+
+	// the underlying errors of the os package usually name the path
+	msg := e.Err.Error()
+	if strings.Contains(msg, e.Path) {
+		return msg
+	}
+	return e.Path + ": " + msg
+}
+
+func (e *NodeError) Unwrap() []error {
+	errs := []error{}
+	for _, err := range []error{e.Kind, e.Err} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// nodeError wraps err, which happened at p, with its kind
+func nodeError(p string, err error) error {
+	var ne *NodeError
+	if errors.As(err, &ne) {
+		return err
+	}
+
+	return &NodeError{Path: p, Kind: errorKind(err), Err: err}
+}
+
+// errorKind classifies err as one of the kinds of node error, or nil
+func errorKind(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, syscall.ENOTDIR):
+		return ErrNotDir
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrVanished
+	}
+	return nil
+}
+
+// errorKinds names the kinds of node error in snapshots
+var errorKinds = map[string]error{
+	"permission":  ErrPermission,
+	"not_dir":     ErrNotDir,
+	"vanished":    ErrVanished,
+	"too_deep":    ErrTooDeep,
+	"limit":       ErrLimitReached,
+	"cycle":       ErrCycle,
+	"incomplete":  ErrIncomplete,
+	"access_time": ErrAccessTime,
+}
+
+// kindName returns the name of the kind of err, or "". Of errors joined
+// together, the first is named.
+func kindName(err error) string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		if errs := joined.Unwrap(); len(errs) > 0 {
+			return kindName(errs[0])
+		}
+	}
+	for name, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return name
+		}
+	}
+	return ""
+}
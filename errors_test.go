@@ -0,0 +1,110 @@
+package ctree
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeErrors(t *testing.T) {
+	t.Run("kinds", func(t *testing.T) {
+		cases := []struct {
+			err  error
+			kind error
+		}{
+			{fs.ErrPermission, ErrPermission},
+			{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, ErrVanished},
+			{errors.New("broken"), nil},
+		}
+
+		for _, tc := range cases {
+			err := nodeError("x", tc.err)
+			assert.ErrorIs(t, err, tc.err)
+			if tc.kind != nil {
+				assert.ErrorIs(t, err, tc.kind)
+			}
+			for _, kind := range []error{ErrPermission, ErrNotDir, ErrVanished, ErrTooDeep} {
+				if kind != tc.kind {
+					assert.NotErrorIs(t, err, kind)
+				}
+			}
+		}
+	})
+
+	t.Run("messages", func(t *testing.T) {
+		assert.Equal(t, "x: broken", nodeError("x", errors.New("broken")).Error())
+		assert.Equal(t, "open x: permission denied",
+			nodeError("x", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}).Error())
+		assert.Equal(t, "x: deeper than the maximum depth",
+			(&NodeError{Path: "x", Kind: ErrTooDeep}).Error())
+	})
+
+	t.Run("from a walk", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = &FaultFS{
+			FS:     tfs,
+			Faults: []Fault{{Pattern: "home/ceswift/bin", Ops: []string{OpReadDir}, Err: fs.ErrPermission}},
+		}
+		dn, err := r.Run()
+		require.NoError(err)
+
+		errs := dn.Errors()
+		require.Len(errs, 1)
+		assert.ErrorIs(errs[0], ErrPermission)
+		assert.ErrorIs(errs[0], fs.ErrPermission)
+
+		var ne *NodeError
+		require.ErrorAs(errs[0], &ne)
+		assert.Equal("home/ceswift/bin", ne.Path)
+
+		var pe *fs.PathError
+		require.ErrorAs(errs[0], &pe)
+		assert.Equal(OpReadDir, pe.Op)
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+		lerrs := loaded.Errors()
+		require.Len(lerrs, 1)
+		assert.ErrorIs(lerrs[0], ErrPermission)
+		assert.Equal(errs[0].Error(), lerrs[0].Error())
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	r := NewRoot("home")
+	r.FS = tfs
+	r.MaxDepth = 1
+	dn, err := r.Run()
+	require.NoError(err)
+
+	assert.Equal(3, dn.TotalLength())
+	errs := dn.Errors()
+	require.Len(errs, 2)
+	for _, err := range errs {
+		assert.ErrorIs(err, ErrTooDeep)
+	}
+
+	r.MaxDepth = 2
+	dn, err = r.Run()
+	require.NoError(err)
+	assert.Equal(7, dn.TotalLength())
+	assert.Len(dn.Errors(), 2)
+
+	r.MaxDepth = 0
+	dn, err = r.Run()
+	require.NoError(err)
+	assert.Equal(9, dn.TotalLength())
+	assert.Empty(dn.Errors())
+}
@@ -0,0 +1,8 @@
+//go:build !plan9
+
+package ctree
+
+import "syscall"
+
+// errStale is the error of an operation on a stale file handle
+var errStale error = syscall.ESTALE
@@ -0,0 +1,6 @@
+package ctree
+
+import "errors"
+
+// errStale stands in for ESTALE, which Plan 9 does not have
+var errStale = errors.New("stale file handle")
@@ -0,0 +1,187 @@
+package ctree
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+// Record is a flat description of one node, as written by WriteNDJSON and
+// WriteCSV
+type Record struct {
+	Path  string      `json:"path"`
+	Type  string      `json:"type"`
+	Mode  fs.FileMode `json:"mode"`
+	Size  int64       `json:"size"`
+	MTime time.Time   `json:"mtime"`
+	UID   *uint32     `json:"uid,omitempty"`
+	GID   *uint32     `json:"gid,omitempty"`
+	Error string      `json:"error,omitempty"`
+	Tags  []string    `json:"tags,omitempty"`
+}
+
+// RecordOf describes n
+func RecordOf(n Node) Record {
+	fi := *n.Info()
+	rec := Record{
+		Path:  n.Path(),
+		Type:  TypeName(fi.Mode()),
+		Mode:  fi.Mode(),
+		Size:  fi.Size(),
+		MTime: fi.ModTime(),
+	}
+
+	if uid, gid, ok := Owner(n); ok {
+		rec.UID, rec.GID = &uid, &gid
+	}
+	if dn, ok := n.(*DNode); ok && dn.err != nil {
+		rec.Error = dn.err.Error()
+	}
+	rec.Tags = n.Tags()
+
+	return rec
+}
+
+// TypeName names the type of file a mode describes: "dir", "file",
+// "symlink", "pipe", "socket", "device", "chardevice", or "other"
+func TypeName(m fs.FileMode) string {
+	switch {
+	case m.IsDir():
+		return "dir"
+	case m.IsRegular():
+		return "file"
+	case m&fs.ModeSymlink != 0:
+		return "symlink"
+	case m&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case m&fs.ModeSocket != 0:
+		return "socket"
+	case m&fs.ModeCharDevice != 0:
+		return "chardevice"
+	case m&fs.ModeDevice != 0:
+		return "device"
+	}
+	return "other"
+}
+
+// eachSorted calls fn for dn and everything beneath it, parents before
+// their entries, with entries in name order
+func eachSorted(dn *DNode, fn func(n Node)) {
+	fn(dn)
+	for _, n := range sortedEntries(dn) {
+		if child, ok := n.(*DNode); ok {
+			eachSorted(child, fn)
+		} else {
+			fn(n)
+		}
+	}
+}
+
+// WriteNDJSON writes a Record for each node of dn to w, one JSON object
+// per line, parents first and entries in name order
+func WriteNDJSON(w io.Writer, dn *DNode) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	var err error
+	eachSorted(dn, func(n Node) {
+		if err == nil {
+			err = enc.Encode(RecordOf(n))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// csvHeader names the columns written by WriteCSV
+var csvHeader = []string{"path", "type", "mode", "size", "mtime", "uid", "gid", "error"}
+
+// WriteCSV writes a Record for each node of dn to w as CSV, with a header
+// line, in the same order as WriteNDJSON. Modes are written in octal, and
+// times in RFC 3339 format.
+func WriteCSV(w io.Writer, dn *DNode) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	var err error
+	eachSorted(dn, func(n Node) {
+		if err != nil {
+			return
+		}
+
+		rec := RecordOf(n)
+		var uid, gid string
+		if rec.UID != nil {
+			uid = strconv.FormatUint(uint64(*rec.UID), 10)
+			gid = strconv.FormatUint(uint64(*rec.GID), 10)
+		}
+
+		err = cw.Write([]string{
+			rec.Path,
+			rec.Type,
+			fmt.Sprintf("%04o", unixPerm(rec.Mode)),
+			strconv.FormatInt(rec.Size, 10),
+			rec.MTime.Format(time.RFC3339Nano),
+			uid,
+			gid,
+			rec.Error,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// unixPerm returns the permission bits of m, with the setuid, setgid, and
+// sticky bits where Unix puts them
+func unixPerm(m fs.FileMode) uint32 {
+	perm := uint32(m.Perm())
+	if m&fs.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if m&fs.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if m&fs.ModeSticky != 0 {
+		perm |= 01000
+	}
+	return perm
+}
+
+// WriteDu writes the total bytes of the leaves beneath each directory of
+// dn to w, in the style of "du -b": a size, a tab, and a path, with
+// directories after their contents
+func WriteDu(w io.Writer, dn *DNode) error {
+	bw := bufio.NewWriter(w)
+	du(bw, dn)
+	return bw.Flush()
+}
+
+func du(w *bufio.Writer, dn *DNode) int64 {
+	var total int64
+	for _, leaf := range dn.leaves {
+		total += (*leaf.info).Size()
+	}
+	for _, n := range sortedEntries(dn) {
+		if child, ok := n.(*DNode); ok {
+			total += du(w, child)
+		}
+	}
+
+	fmt.Fprintf(w, "%d\t%s\n", total, dn.path)
+
+	return total
+}
@@ -0,0 +1,84 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeName(t *testing.T) {
+	assert.Equal(t, "dir", TypeName(fs.ModeDir|0755))
+	assert.Equal(t, "file", TypeName(0644))
+	assert.Equal(t, "symlink", TypeName(fs.ModeSymlink|0777))
+	assert.Equal(t, "chardevice", TypeName(fs.ModeDevice|fs.ModeCharDevice))
+	assert.Equal(t, "device", TypeName(fs.ModeDevice))
+	assert.Equal(t, "other", TypeName(fs.ModeIrregular))
+}
+
+func TestUnixPerm(t *testing.T) {
+	assert.Equal(t, uint32(0755), unixPerm(fs.ModeDir|0755))
+	assert.Equal(t, uint32(04755), unixPerm(fs.ModeSetuid|0755))
+	assert.Equal(t, uint32(03777), unixPerm(fs.ModeSetgid|fs.ModeSticky|0777))
+}
+
+func TestWriteDu(t *testing.T) {
+	dn := scanCopy(t, nil)
+
+	var sb strings.Builder
+	require.NoError(t, WriteDu(&sb, dn))
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+
+	require.Len(t, lines, 5)
+	assert.Equal(t, "10\t"+dn.Path()+"/ceswift/bin", lines[0])
+	assert.Equal(t, "24\t"+dn.Path()+"/ceswift", lines[1])
+	assert.Equal(t, "62\t"+dn.Path(), lines[4])
+}
+
+func TestExportNanoseconds(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	when := epoch.Add(123456789)
+	dn := scanCopy(t, func(where string) {
+		require.NoError(os.Chtimes(path.Join(where, "ceswift/.cshrc"), when, when))
+	})
+	cshrc := dn.Lookup("ceswift/.cshrc").Path()
+
+	var buf bytes.Buffer
+	require.NoError(WriteNDJSON(&buf, dn))
+	dec := json.NewDecoder(&buf)
+	found := false
+	for dec.More() {
+		var rec Record
+		require.NoError(dec.Decode(&rec))
+		if rec.Path == cshrc {
+			found = true
+			assert.True(when.Equal(rec.MTime), rec.MTime)
+		}
+	}
+	assert.True(found)
+
+	buf.Reset()
+	require.NoError(WriteCSV(&buf, dn))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(err)
+	found = false
+	for _, row := range rows[1:] {
+		if row[0] == cshrc {
+			found = true
+			mtime, err := time.Parse(time.RFC3339Nano, row[4])
+			require.NoError(err)
+			assert.True(when.Equal(mtime), mtime)
+		}
+	}
+	assert.True(found)
+}
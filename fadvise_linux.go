@@ -0,0 +1,13 @@
+package ctree
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// adviseSequential tells the kernel that f will be read from start to
+// end, so that it reads ahead further
+func adviseSequential(f *os.File) {
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ctree
+
+import "os"
+
+// adviseSequential does nothing where there is no posix_fadvise
+func adviseSequential(f *os.File) {}
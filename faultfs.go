@@ -0,0 +1,145 @@
+package ctree
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// Operations that a Fault can apply to
+const (
+	OpOpen    = "open"
+	OpReadDir = "readdir"
+	OpStat    = "stat"
+)
+
+// Fault describes a delay or an error for a FaultFS to inject
+type Fault struct {
+	// Pattern is matched against names with path.Match; an empty
+	// Pattern matches every name
+	Pattern string
+	// Ops lists the operations affected; if empty, all are
+	Ops []string
+	// Delay is how long to sleep before the operation
+	Delay time.Duration
+	// Err, if set, is returned instead of performing the operation
+	Err error
+	// Times, if positive, limits how many operations the fault
+	// applies to
+	Times int
+}
+
+// FaultFS wraps an fs.FS, injecting delays and errors into its
+// operations, for testing how code copes with slow and failing
+// filesystems. It is safe for concurrent use.
+type FaultFS struct {
+	FS     fs.FS
+	Faults []Fault
+
+	mu   sync.Mutex
+	hits map[int]int
+}
+
+var (
+	_ fs.ReadDirFS = &FaultFS{}
+	_ fs.StatFS    = &FaultFS{}
+)
+
+// Open opens the named file
+func (ffs *FaultFS) Open(name string) (fs.File, error) {
+	if err := ffs.inject(OpOpen, name); err != nil {
+		return nil, err
+	}
+	return ffs.FS.Open(name)
+}
+
+// ReadDir reads the named directory. Stat faults apply to the Info
+// method of the entries, as to Stat.
+func (ffs *FaultFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := ffs.inject(OpReadDir, name); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(ffs.FS, name)
+	for i, entry := range entries {
+		entries[i] = &faultEntry{
+			DirEntry: entry,
+			ffs:      ffs,
+			name:     path.Join(name, entry.Name()),
+		}
+	}
+
+	return entries, err
+}
+
+// faultEntry is a directory entry whose Info is subject to stat faults
+type faultEntry struct {
+	fs.DirEntry
+	ffs  *FaultFS
+	name string
+}
+
+func (fe *faultEntry) Info() (fs.FileInfo, error) {
+	if err := fe.ffs.inject(OpStat, fe.name); err != nil {
+		return nil, err
+	}
+	return fe.DirEntry.Info()
+}
+
+// Stat returns a FileInfo describing the named file
+func (ffs *FaultFS) Stat(name string) (fs.FileInfo, error) {
+	if err := ffs.inject(OpStat, name); err != nil {
+		return nil, err
+	}
+	return fs.Stat(ffs.FS, name)
+}
+
+// inject applies every matching fault, returning the first error
+func (ffs *FaultFS) inject(op, name string) error {
+	var delay time.Duration
+	var err error
+
+	ffs.mu.Lock()
+	if ffs.hits == nil {
+		ffs.hits = map[int]int{}
+	}
+	for i, f := range ffs.Faults {
+		if !f.matches(op, name) {
+			continue
+		}
+		if f.Times > 0 {
+			if ffs.hits[i] >= f.Times {
+				continue
+			}
+			ffs.hits[i]++
+		}
+		delay += f.Delay
+		if err == nil && f.Err != nil {
+			err = &fs.PathError{Op: op, Path: name, Err: f.Err}
+		}
+	}
+	ffs.mu.Unlock()
+
+	time.Sleep(delay)
+
+	return err
+}
+
+func (f *Fault) matches(op, name string) bool {
+	if f.Pattern != "" {
+		if ok, _ := path.Match(f.Pattern, name); !ok {
+			return false
+		}
+	}
+
+	if len(f.Ops) == 0 {
+		return true
+	}
+	for _, o := range f.Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
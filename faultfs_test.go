@@ -0,0 +1,100 @@
+package ctree
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultFS(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	t.Run("no faults", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = &FaultFS{FS: tfs}
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.Empty(dn.Errors())
+		assert.Equal(9, dn.TotalLength())
+	})
+
+	t.Run("errors are injected", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = &FaultFS{
+			FS: tfs,
+			Faults: []Fault{{
+				Pattern: "home/*/bin",
+				Ops:     []string{OpReadDir},
+				Err:     errBroken,
+			}},
+		}
+		dn, err := r.Run()
+		require.NoError(err)
+
+		errs := dn.Errors()
+		require.Len(errs, 2)
+		for _, err := range errs {
+			assert.ErrorIs(err, errBroken)
+			var pe *fs.PathError
+			require.ErrorAs(err, &pe)
+			assert.Equal(OpReadDir, pe.Op)
+		}
+		assert.Equal(7, dn.TotalLength())
+	})
+
+	t.Run("faults can be limited", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ffs := &FaultFS{
+			FS:     tfs,
+			Faults: []Fault{{Err: errBroken, Times: 2}},
+		}
+		_, err := ffs.Stat("home")
+		assert.ErrorIs(err, errBroken)
+		_, err = ffs.ReadDir("home")
+		assert.ErrorIs(err, errBroken)
+		_, err = ffs.Open("home/ceswift/.cshrc")
+		assert.NoError(err)
+	})
+
+	t.Run("ops are filtered", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ffs := &FaultFS{
+			FS:     tfs,
+			Faults: []Fault{{Ops: []string{OpOpen}, Err: errBroken}},
+		}
+		_, err := ffs.Stat("home")
+		assert.NoError(err)
+		_, err = ffs.Open("home")
+		assert.ErrorIs(err, errBroken)
+	})
+
+	t.Run("delays are injected", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ffs := &FaultFS{
+			FS:     tfs,
+			Faults: []Fault{{Pattern: "home", Delay: 20 * time.Millisecond}},
+		}
+		start := time.Now()
+		_, err := ffs.Stat("home/ceswift")
+		assert.NoError(err)
+		assert.Less(time.Since(start), 20*time.Millisecond)
+
+		start = time.Now()
+		_, err = ffs.Stat("home")
+		assert.NoError(err)
+		assert.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+	})
+}
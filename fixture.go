@@ -0,0 +1,98 @@
+package ctree
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// FixtureOptions controls WriteFixture
+type FixtureOptions struct {
+	// Name, if set, declares the fixture as a variable of that name;
+	// otherwise just the expression is written
+	Name string
+	// Stubs gives each regular file contents of zeros, as long as the
+	// file was, so that sizes are reproduced; otherwise files are empty
+	Stubs bool
+	// ModTimes keeps the modification time of each file and directory
+	ModTimes bool
+}
+
+// WriteFixture writes Go source for an fstest.MapFS reproducing the
+// structure of dn, with paths relative to it, so that a tree from a bug
+// report can become a test. Errors found at directories are written as
+// comments. The source uses the packages io/fs and testing/fstest, and
+// time if ModTimes is set.
+func WriteFixture(w io.Writer, dn *DNode, opts *FixtureOptions) error {
+	if opts == nil {
+		opts = &FixtureOptions{}
+	}
+
+	var buf bytes.Buffer
+	if opts.Name != "" {
+		fmt.Fprintf(&buf, "var %s = ", opts.Name)
+	}
+	buf.WriteString("fstest.MapFS{\n")
+
+	prefix := strings.TrimSuffix(dn.path, "/") + "/"
+	eachSorted(dn, func(n Node) {
+		if n == Node(dn) {
+			return
+		}
+		if dn, ok := n.(*DNode); ok && dn.err != nil {
+			fmt.Fprintf(&buf, "// %s\n", strings.ReplaceAll(dn.err.Error(), "\n", " "))
+		}
+
+		fi := *n.Info()
+		rel := strings.TrimPrefix(n.Path(), prefix)
+		fields := []string{"Mode: " + modeExpr(fi.Mode())}
+		if opts.Stubs && fi.Mode().IsRegular() && fi.Size() > 0 {
+			fields = append([]string{fmt.Sprintf("Data: make([]byte, %d)", fi.Size())}, fields...)
+		}
+		if opts.ModTimes {
+			t := fi.ModTime()
+			fields = append(fields, fmt.Sprintf("ModTime: time.Unix(%d, %d)", t.Unix(), t.Nanosecond()))
+		}
+		fmt.Fprintf(&buf, "%s: {%s},\n", strconv.Quote(rel), strings.Join(fields, ", "))
+	})
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// modeBits names the bits of a FileMode other than its permissions
+var modeBits = []struct {
+	bit  fs.FileMode
+	name string
+}{
+	{fs.ModeDir, "fs.ModeDir"},
+	{fs.ModeSymlink, "fs.ModeSymlink"},
+	{fs.ModeNamedPipe, "fs.ModeNamedPipe"},
+	{fs.ModeSocket, "fs.ModeSocket"},
+	{fs.ModeDevice, "fs.ModeDevice"},
+	{fs.ModeCharDevice, "fs.ModeCharDevice"},
+	{fs.ModeIrregular, "fs.ModeIrregular"},
+	{fs.ModeSetuid, "fs.ModeSetuid"},
+	{fs.ModeSetgid, "fs.ModeSetgid"},
+	{fs.ModeSticky, "fs.ModeSticky"},
+}
+
+// modeExpr writes m as a Go expression
+func modeExpr(m fs.FileMode) string {
+	var parts []string
+	for _, mb := range modeBits {
+		if m&mb.bit != 0 {
+			parts = append(parts, mb.name)
+		}
+	}
+	return strings.Join(append(parts, fmt.Sprintf("0o%o", m.Perm())), " | ")
+}
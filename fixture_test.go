@@ -0,0 +1,67 @@
+package ctree
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFixture(t *testing.T) {
+	when := time.Unix(1700000000, 5)
+	mfs := fstest.MapFS{
+		"src/a/b.txt": {Data: []byte("hello"), Mode: 0o644, ModTime: when},
+		"src/a/run":   {Data: []byte("#!"), Mode: 0o755 | fs.ModeSetuid, ModTime: when},
+		"src/empty":   {Mode: fs.ModeDir | 0o700, ModTime: when},
+		"src/link":    {Mode: fs.ModeSymlink | 0o777, ModTime: when},
+	}
+	r := NewRoot("src")
+	r.FS = mfs
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	t.Run("structure", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteFixture(&buf, dn, nil))
+		assert.Equal(t, `fstest.MapFS{
+	"a":       {Mode: fs.ModeDir | 0o555},
+	"a/b.txt": {Mode: 0o644},
+	"a/run":   {Mode: fs.ModeSetuid | 0o755},
+	"empty":   {Mode: fs.ModeDir | 0o700},
+	"link":    {Mode: fs.ModeSymlink | 0o777},
+}
+`, buf.String())
+
+		_, err := parser.ParseExpr(buf.String())
+		assert.NoError(t, err)
+	})
+
+	t.Run("stubs and times", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteFixture(&buf, dn, &FixtureOptions{Name: "tree", Stubs: true, ModTimes: true}))
+		assert.Contains(t, buf.String(), "var tree = fstest.MapFS{\n")
+		assert.Contains(t, buf.String(),
+			`"a/b.txt": {Data: make([]byte, 5), Mode: 0o644, ModTime: time.Unix(1700000000, 5)},`)
+
+		_, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+buf.String(), 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("errors are comments", func(t *testing.T) {
+		r := NewRoot("src")
+		r.FS = mfs
+		r.MaxDepth = 1
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteFixture(&buf, dn, nil))
+		assert.Contains(t, buf.String(), "\t// src/a: deeper than the maximum depth\n\t\"a\":")
+	})
+}
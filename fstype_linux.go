@@ -0,0 +1,46 @@
+//go:build linux
+
+package ctree
+
+import "syscall"
+
+// Filesystem magic numbers, from statfs(2)
+const (
+	nfsMagic    = 0x6969
+	smbMagic    = 0x517b
+	smb2Magic   = 0xfe534d42
+	cifsMagic   = 0xff534d42
+	cephMagic   = 0x00c36400
+	afsMagic    = 0x5346414f
+	v9fsMagic   = 0x01021997
+	fuseMagic   = 0x65735546
+	lustreMagic = 0x0bd00bd0
+)
+
+var networkFS = map[uint32]bool{
+	nfsMagic:    true,
+	smbMagic:    true,
+	smb2Magic:   true,
+	cifsMagic:   true,
+	cephMagic:   true,
+	afsMagic:    true,
+	v9fsMagic:   true,
+	fuseMagic:   true,
+	lustreMagic: true,
+}
+
+// fsType returns the magic number of the filesystem holding p
+func fsType(p string) (uint32, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(p, &st); err != nil {
+		return 0, false
+	}
+	return uint32(st.Type), true
+}
+
+// isNetworkFS reports whether p looks like it is on a network filesystem.
+// FUSE filesystems are counted, since many of them are.
+func isNetworkFS(p string) bool {
+	t, ok := fsType(p)
+	return ok && networkFS[t]
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ctree
+
+// isNetworkFS cannot tell on this platform
+func isNetworkFS(p string) bool {
+	return false
+}
@@ -0,0 +1,60 @@
+package ctree
+
+import (
+	"io/fs"
+	"path"
+	"sync/atomic"
+)
+
+// audit tells the Root's Audit, if it has one, that p is being read
+func (r *Root) audit(op, p string) {
+	if r.Audit != nil {
+		r.Audit(op, p)
+	}
+}
+
+// stat returns the FileInfo for p, following symbolic links
+func (r *Root) stat(p string) (fs.FileInfo, error) {
+	r.audit(OpStat, p)
+	atomic.AddInt64(&r.counters.stats, 1)
+	return r.fsys.Stat(p)
+}
+
+// entryInfo returns the FileInfo of entry, from the directory dir
+func (r *Root) entryInfo(dir string, entry fs.DirEntry) (fs.FileInfo, error) {
+	r.audit(OpStat, path.Join(dir, entry.Name()))
+	atomic.AddInt64(&r.counters.stats, 1)
+	return entry.Info()
+}
+
+// open opens the file at p for reading, taking a file descriptor from
+// the Root's Pool until it is closed with close
+func (r *Root) open(p string) (fs.File, error) {
+	r.audit(OpOpen, p)
+	atomic.AddInt64(&r.counters.opens, 1)
+	r.Pool.acquireFD()
+
+	f, err := r.fsys.Open(p)
+	if err != nil {
+		r.Pool.releaseFD()
+		return nil, err
+	}
+	return f, nil
+}
+
+// close closes f, which open returned
+func (r *Root) close(f fs.File) error {
+	defer r.Pool.releaseFD()
+	return f.Close()
+}
+
+// readDir returns the entries of the directory at p, in no particular
+// order
+func (r *Root) readDir(p string) ([]fs.DirEntry, error) {
+	r.audit(OpReadDir, p)
+	atomic.AddInt64(&r.counters.readDirs, 1)
+	r.Pool.acquireFD()
+	defer r.Pool.releaseFD()
+
+	return r.fsys.ReadDir(p)
+}
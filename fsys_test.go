@@ -0,0 +1,55 @@
+package ctree
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tfs = fstest.MapFS{
+	"home/ceswift/.cshrc":    {Data: []byte("echo hello COS")},
+	"home/ceswift/bin/worms": {Data: []byte("========8>")},
+	"home/wsfitzpa/.cshrc":   {Data: []byte("echo hello, william.")},
+	"home/wsfitzpa/bin/zrun": {Data: []byte("uncompress $1 ; $1")},
+}
+
+func TestFS(t *testing.T) {
+	t.Run("walks an fs.FS", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.Empty(dn.Errors())
+		assert.Equal(9, dn.TotalLength())
+		assert.NotNil(dn.Lookup("wsfitzpa/bin/zrun"))
+		assert.Equal("home/wsfitzpa/bin/zrun", dn.Lookup("wsfitzpa/bin/zrun").Path())
+	})
+
+	t.Run("matches the real thing", func(t *testing.T) {
+		require := require.New(t)
+
+		real := scanCopy(t, nil)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(err)
+
+		for _, c := range DiffFunc(real, dn, AllOf(SameType, SameSize)) {
+			t.Errorf("%v %s", c.Kind, c.Path)
+		}
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		r := NewRoot("nowhere")
+		r.FS = tfs
+		dn, err := r.Run()
+		assert.Nil(t, dn)
+		assert.Error(t, err)
+	})
+}
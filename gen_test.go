@@ -0,0 +1,193 @@
+package ctree
+
+import (
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeGen creates random directory trees
+type treeGen struct {
+	rng      *rand.Rand
+	maxDepth int
+	maxWidth int
+	charset  []rune
+	symlinks bool // make symbolic links, including loops
+	denials  bool // make directories that cannot be read
+}
+
+var charsets = [][]rune{
+	[]rune("abcdefghijklmnopqrstuvwxyz"),
+	[]rune("aA0 ._-~#"),
+	[]rune("äöüßéñ日本語😀"),
+}
+
+func newTreeGen(seed int64) *treeGen {
+	rng := rand.New(rand.NewSource(seed))
+
+	return &treeGen{
+		rng:      rng,
+		maxDepth: 1 + rng.Intn(6),
+		maxWidth: 1 + rng.Intn(8),
+		charset:  charsets[rng.Intn(len(charsets))],
+		symlinks: rng.Intn(2) == 0,
+		denials:  rng.Intn(3) == 0,
+	}
+}
+
+// build fills where with a random tree
+func (g *treeGen) build(t *testing.T, where string) {
+	g.fill(t, where, 0)
+}
+
+func (g *treeGen) fill(t *testing.T, where string, depth int) {
+	require := require.New(t)
+
+	width := g.rng.Intn(g.maxWidth + 1)
+	for i := 0; i < width; i++ {
+		name := g.name()
+		p := path.Join(where, name)
+		if _, err := os.Lstat(p); err == nil {
+			continue
+		}
+
+		switch n := g.rng.Intn(10); {
+		case n < 4 && depth < g.maxDepth:
+			require.NoError(os.Mkdir(p, 0777))
+			g.fill(t, p, depth+1)
+			if g.denials && g.rng.Intn(5) == 0 {
+				require.NoError(os.Chmod(p, 0))
+				t.Cleanup(func() {
+					os.Chmod(p, 0777)
+				})
+			}
+		case n < 6 && g.symlinks:
+			targets := []string{".", "..", name, "nowhere"}
+			target := targets[g.rng.Intn(len(targets))]
+			require.NoError(os.Symlink(target, p))
+		default:
+			contents := make([]byte, g.rng.Intn(64))
+			g.rng.Read(contents)
+			require.NoError(os.WriteFile(p, contents, 0666))
+		}
+	}
+}
+
+func (g *treeGen) name() string {
+	for {
+		runes := make([]rune, 1+g.rng.Intn(12))
+		for i := range runes {
+			runes[i] = g.charset[g.rng.Intn(len(g.charset))]
+		}
+		name := string(runes)
+		if name != "." && name != ".." {
+			return name
+		}
+	}
+}
+
+// checkWalk runs ctree over where and checks that it agrees with
+// filepath.WalkDir
+func checkWalk(t *testing.T, r *Root) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	type result struct {
+		dn  *DNode
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		dn, err := r.Run()
+		done <- result{dn, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(30 * time.Second):
+		require.FailNow("walk did not terminate")
+	}
+	require.NoError(res.err)
+
+	flat := res.dn.Flatten()
+	assert.Equal(res.dn.TotalLength(), len(flat))
+
+	got := map[string]bool{}
+	for _, n := range flat {
+		assert.False(got[n.Path()], "counted twice: %q", n.Path())
+		got[n.Path()] = true
+	}
+	gotErrs := map[string]bool{}
+	for _, n := range flat {
+		if dn, ok := n.(*DNode); ok && dn.Error() != nil {
+			gotErrs[dn.Path()] = true
+		}
+	}
+
+	want := map[string]bool{}
+	wantErrs := map[string]bool{}
+	err := filepath.WalkDir(r.Path, func(p string, _ fs.DirEntry, err error) error {
+		want[p] = true
+		if err != nil {
+			wantErrs[p] = true
+		}
+		return nil
+	})
+	require.NoError(err)
+
+	assert.Equal(want, got)
+	assert.Equal(wantErrs, gotErrs)
+}
+
+func TestGeneratedTrees(t *testing.T) {
+	shapes := []struct {
+		name    string
+		threads int
+		queue   int
+	}{
+		{"sequential", 1, 0},
+		{"single queued", 1, DefaultWorkListSize},
+		{"unqueued", DefaultThreads, 0},
+		{"small queue", 16, 2},
+		{"default", DefaultThreads, DefaultWorkListSize},
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		where := t.TempDir()
+		newTreeGen(seed).build(t, where)
+
+		for _, shape := range shapes {
+			r := NewRoot(where)
+			r.Threads = shape.threads
+			r.WorkListSize = shape.queue
+			t.Run(shape.name, func(t *testing.T) {
+				checkWalk(t, r)
+			})
+		}
+	}
+}
+
+func FuzzRun(f *testing.F) {
+	for seed := int64(100); seed < 105; seed++ {
+		f.Add(seed, uint8(DefaultThreads), uint16(DefaultWorkListSize))
+	}
+	f.Add(int64(0), uint8(1), uint16(0))
+
+	f.Fuzz(func(t *testing.T, seed int64, threads uint8, queue uint16) {
+		where := t.TempDir()
+		newTreeGen(seed).build(t, where)
+
+		r := NewRoot(where)
+		r.Threads = 1 + int(threads%32)
+		r.WorkListSize = int(queue % 4096)
+		checkWalk(t, r)
+	})
+}
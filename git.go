@@ -0,0 +1,381 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GitAnnotation names the annotation Root.Git gives the files of git work
+// trees
+const GitAnnotation = "git"
+
+// GitStatus is whether git knows of a file
+type GitStatus string
+
+const (
+	// GitTracked files are in the repository's index
+	GitTracked GitStatus = "tracked"
+	// GitUntracked files are neither in the index nor ignored
+	GitUntracked GitStatus = "untracked"
+	// GitIgnored files are not in the index, and are matched by a
+	// .gitignore file or .git/info/exclude
+	GitIgnored GitStatus = "ignored"
+)
+
+// GitStatusOf returns the GitStatus Root.Git found for l, or false if l is
+// not in a git work tree
+func GitStatusOf(l *Leaf) (GitStatus, bool) {
+	var gs GitStatus
+	ok, err := l.Annotation(GitAnnotation, &gs)
+	return gs, ok && err == nil
+}
+
+// GitLeaves returns the leaves beneath dn with the given GitStatus,
+// largest first, as for finding large untracked files
+func GitLeaves(dn *DNode, status GitStatus) []*Leaf {
+	var leaves []*Leaf
+	dn.walk(func(_ string, n Node) bool {
+		if l, ok := n.(*Leaf); ok {
+			if gs, ok := GitStatusOf(l); ok && gs == status {
+				leaves = append(leaves, l)
+			}
+		}
+		return true
+	})
+
+	sort.Slice(leaves, func(i, j int) bool {
+		si, sj := (*leaves[i].info).Size(), (*leaves[j].info).Size()
+		if si != sj {
+			return si > sj
+		}
+		return leaves[i].path < leaves[j].path
+	})
+
+	return leaves
+}
+
+// annotateGit gives a GitStatus to every leaf of every git work tree
+// beneath dn, which is any directory holding a .git directory. The
+// repositories' indexes and ignore files are read from the filesystem.
+// The errors of repositories which can't be read are returned, joined,
+// and their leaves are left alone.
+func (r *Root) annotateGit(dn *DNode) error {
+	var errs []error
+
+	var find func(dn *DNode)
+	find = func(dn *DNode) {
+		if isWorkTree(dn) {
+			if err := r.gitWorkTree(dn); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, child := range dn.children {
+			if child.name != ".git" {
+				find(child)
+			}
+		}
+	}
+	find(dn)
+
+	return errors.Join(errs...)
+}
+
+// isWorkTree reports whether dn is the top of a git work tree
+func isWorkTree(dn *DNode) bool {
+	for _, child := range dn.children {
+		if child.name == ".git" {
+			return true
+		}
+	}
+	return false
+}
+
+// gitWorkTree annotates the leaves of the work tree at top, leaving any
+// repositories nested in it to annotateGit
+func (r *Root) gitWorkTree(top *DNode) error {
+	index, err := r.readGitFile(path.Join(top.path, ".git/index"))
+	if errors.Is(err, fs.ErrNotExist) {
+		index = nil // nothing has been added yet
+	} else if err != nil {
+		return err
+	}
+	tracked, err := parseGitIndex(index)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Join(top.path, ".git/index"), err)
+	}
+
+	exclude, err := r.readGitFile(path.Join(top.path, ".git/info/exclude"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	rules := parseIgnore("", exclude)
+
+	var errs []error
+	var visit func(dn *DNode, rel string, rules []ignoreRule, ignored bool)
+	visit = func(dn *DNode, rel string, rules []ignoreRule, ignored bool) {
+		if !ignored {
+			for _, l := range dn.leaves {
+				if l.name != ".gitignore" {
+					continue
+				}
+				data, err := r.readGitFile(l.path)
+				if err != nil {
+					errs = append(errs, err)
+					break
+				}
+				rules = append(rules[:len(rules):len(rules)], parseIgnore(rel, data)...)
+			}
+		}
+
+		for _, l := range dn.leaves {
+			lrel := path.Join(rel, l.name)
+			status := GitUntracked
+			switch {
+			case tracked.has(lrel):
+				status = GitTracked
+			case ignored || isIgnored(rules, lrel, false):
+				status = GitIgnored
+			}
+			l.Annotate(GitAnnotation, status)
+		}
+
+		for _, child := range dn.children {
+			if child.name == ".git" || isWorkTree(child) {
+				continue
+			}
+			crel := path.Join(rel, child.name)
+			visit(child, crel, rules, ignored || isIgnored(rules, crel, true))
+		}
+	}
+	visit(top, "", rules, false)
+
+	return errors.Join(errs...)
+}
+
+// readGitFile reads the whole of the file at p
+func (r *Root) readGitFile(p string) ([]byte, error) {
+	f, err := r.open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close(f)
+
+	return io.ReadAll(r.counted(f))
+}
+
+// gitIndex is the paths in a git index, and the directories of a sparse
+// index, which stand for everything beneath them
+type gitIndex struct {
+	files map[string]bool
+	dirs  []string
+}
+
+func (gi gitIndex) has(rel string) bool {
+	if gi.files[rel] {
+		return true
+	}
+	for _, dir := range gi.dirs {
+		if strings.HasPrefix(rel, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// errGitIndex is returned for indexes which can't be parsed
+var errGitIndex = errors.New("malformed git index")
+
+// parseGitIndex finds the paths in a git index file, of version 2, 3, or
+// 4. An empty file is an empty index.
+func parseGitIndex(data []byte) (gitIndex, error) {
+	gi := gitIndex{files: map[string]bool{}}
+	if len(data) == 0 {
+		return gi, nil
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return gi, errGitIndex
+	}
+
+	be := binary.BigEndian
+	version, count := be.Uint32(data[4:]), be.Uint32(data[8:])
+	if version < 2 || version > 4 {
+		return gi, fmt.Errorf("git index version %d is not supported", version)
+	}
+
+	// each entry is stat data, a mode, an object ID, and flags, then
+	// the name; version 4 shortens names by the previous one
+	const fixed = 62
+	const modeAt, flagsAt = 24, 60
+	const extended, dirMode = 0x4000, 0o040000
+
+	var name []byte
+	off := 12
+	for i := uint32(0); i < count; i++ {
+		if off+fixed > len(data) {
+			return gi, errGitIndex
+		}
+		e := data[off:]
+		mode, flags := be.Uint32(e[modeAt:]), be.Uint16(e[flagsAt:])
+		start := off + fixed
+		if version >= 3 && flags&extended != 0 {
+			start += 2
+		}
+
+		rest := data[min(start, len(data)):]
+		if version == 4 {
+			strip, n := gitVarint(rest)
+			if n == 0 || strip > uint64(len(name)) {
+				return gi, errGitIndex
+			}
+			rest = rest[n:]
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return gi, errGitIndex
+			}
+			name = append(name[:len(name)-int(strip)], rest[:end]...)
+			off = start + n + end + 1
+		} else {
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return gi, errGitIndex
+			}
+			name = append(name[:0], rest[:end]...)
+			// entries are padded with NULs to a multiple of 8
+			off += (start - off + end + 8) &^ 7
+		}
+
+		if mode == dirMode {
+			gi.dirs = append(gi.dirs, string(name))
+		} else {
+			gi.files[string(name)] = true
+		}
+	}
+
+	return gi, nil
+}
+
+// gitVarint decodes the variable length integers of git's index, returning
+// how many bytes it took, or zero if there are too few
+func gitVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	v := uint64(b[0] & 0x7f)
+	n := 1
+	for b[n-1]&0x80 != 0 {
+		if n == len(b) {
+			return 0, 0
+		}
+		v = (v+1)<<7 | uint64(b[n]&0x7f)
+		n++
+	}
+	return v, n
+}
+
+// ignoreRule is a line of a .gitignore file
+type ignoreRule struct {
+	base     string // the directory of the file, relative to the work tree
+	pattern  string
+	negate   bool // the line started with "!"
+	dirOnly  bool // the line ended with "/"
+	anchored bool // the pattern is matched against the whole path from base
+}
+
+// parseIgnore parses the lines of a .gitignore file in the directory
+// base
+func parseIgnore(base string, data []byte) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// git's "[!...]" is Go's "[^...]"
+		rule.pattern = strings.ReplaceAll(line, "[!", "[^")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isIgnored reports whether the last of rules to match rel, a path within
+// the work tree, ignores it
+func isIgnored(rules []ignoreRule, rel string, dir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.matches(rel, dir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(rel string, dir bool) bool {
+	if rule.dirOnly && !dir {
+		return false
+	}
+	if rule.base != "" {
+		if !strings.HasPrefix(rel, rule.base+"/") {
+			return false
+		}
+		rel = rel[len(rule.base)+1:]
+	}
+
+	if !rule.anchored {
+		ok, _ := path.Match(rule.pattern, path.Base(rel))
+		return ok
+	}
+	return globMatch(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// globMatch matches the elements of a path against those of a pattern,
+// where "**" matches any number of elements
+func globMatch(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if globMatch(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
@@ -0,0 +1,277 @@
+package ctree
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitIndexFile is a git index of the given version holding names, which
+// must be sorted, as regular files
+func gitIndexFile(version uint32, names ...string) []byte {
+	be := binary.BigEndian
+	data := be.AppendUint32([]byte("DIRC"), version)
+	data = be.AppendUint32(data, uint32(len(names)))
+
+	prev := ""
+	for _, name := range names {
+		e := make([]byte, 62)
+		be.PutUint32(e[24:], 0o100644)
+		be.PutUint16(e[60:], uint16(len(name)))
+		if version == 4 {
+			common := 0
+			for common < len(prev) && common < len(name) && prev[common] == name[common] {
+				common++
+			}
+			e = append(e, byte(len(prev)-common)) // small enough for one byte
+			e = append(e, name[common:]...)
+			e = append(e, 0)
+		} else {
+			e = append(e, name...)
+			e = append(e, make([]byte, 8-len(e)%8)...)
+		}
+		data = append(data, e...)
+		prev = name
+	}
+
+	// the checksum, which isn't checked
+	return append(data, make([]byte, 20)...)
+}
+
+func TestGitVarint(t *testing.T) {
+	for _, test := range []struct {
+		in   []byte
+		want uint64
+		n    int
+	}{
+		{[]byte{0}, 0, 1},
+		{[]byte{0x7f}, 127, 1},
+		{[]byte{0x80, 0}, 128, 2},
+		{[]byte{0x80, 0x7f, 0xff}, 255, 2},
+		{[]byte{0x81, 0}, 256, 2},
+		{[]byte{0x80}, 0, 0},
+		{nil, 0, 0},
+	} {
+		v, n := gitVarint(test.in)
+		assert.Equal(t, test.want, v, "%x", test.in)
+		assert.Equal(t, test.n, n, "%x", test.in)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	rules := parseIgnore("", []byte(strings.Join([]string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"/build/",
+		"docs/**/*.pdf",
+		"tmp/",
+		`\#hash`,
+		"trailing   ",
+		"[!a]x",
+	}, "\n")))
+	rules = append(rules, parseIgnore("sub", []byte("/only-here\n*.o\n"))...)
+
+	tests := []struct {
+		rel     string
+		dir     bool
+		ignored bool
+	}{
+		{"a.log", false, true},
+		{"deep/down/a.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, false},
+		{"docs/a.pdf", false, true},
+		{"docs/x/y/a.pdf", false, true},
+		{"a.pdf", false, false},
+		{"tmp", true, true},
+		{"src/tmp", true, true},
+		{"#hash", false, true},
+		{"trailing", false, true},
+		{"bx", false, true},
+		{"ax", false, false},
+		{"only-here", false, false},
+		{"sub/only-here", false, true},
+		{"sub/x/only-here", false, false},
+		{"sub/x/a.o", false, true},
+		{"a.o", false, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ignored, isIgnored(rules, test.rel, test.dir), test.rel)
+	}
+}
+
+func TestParseGitIndex(t *testing.T) {
+	names := []string{"README", "cmd/main.go", "cmd/main_test.go", "go.mod"}
+	for _, version := range []uint32{2, 3, 4} {
+		gi, err := parseGitIndex(gitIndexFile(version, names...))
+		require.NoError(t, err, version)
+		assert.Len(t, gi.files, len(names), version)
+		for _, name := range names {
+			assert.True(t, gi.has(name), "%d %s", version, name)
+		}
+		assert.False(t, gi.has("cmd"), version)
+	}
+
+	_, err := parseGitIndex([]byte("DIRC\x00\x00\x00\x02\x00\x00\x00\x05"))
+	assert.ErrorIs(t, err, errGitIndex)
+	_, err = parseGitIndex([]byte("not an index"))
+	assert.ErrorIs(t, err, errGitIndex)
+	_, err = parseGitIndex([]byte("DIRC\x00\x00\x00\x09\x00\x00\x00\x00"))
+	assert.ErrorContains(t, err, "version 9")
+}
+
+func TestGit(t *testing.T) {
+	mfs := fstest.MapFS{
+		"home/notes.txt":                  {},
+		"home/repo/.git/HEAD":             {Data: []byte("ref: refs/heads/main\n")},
+		"home/repo/.git/index":            {Data: gitIndexFile(2, ".gitignore", "lib/lib.go", "main.go", "out/keep.bin")},
+		"home/repo/.git/info/exclude":     {Data: []byte("*.swp\n")},
+		"home/repo/.gitignore":            {Data: []byte("out/\n*.tmp\n")},
+		"home/repo/main.go":               {},
+		"home/repo/.main.go.swp":          {},
+		"home/repo/big.iso":               {Data: make([]byte, 1000)},
+		"home/repo/small.txt":             {Data: make([]byte, 10)},
+		"home/repo/lib/lib.go":            {},
+		"home/repo/lib/.gitignore":        {Data: []byte("!*.tmp\ngen/\n")},
+		"home/repo/lib/scratch.tmp":       {},
+		"home/repo/lib/gen/code.go":       {},
+		"home/repo/out/keep.bin":          {},
+		"home/repo/out/build.bin":         {},
+		"home/repo/out/.gitignore":        {Data: []byte("!*.bin\n")},
+		"home/repo/vendor/dep/.git/index": {Data: gitIndexFile(4, "dep.go")},
+		"home/repo/vendor/dep/dep.go":     {},
+		"home/repo/vendor/dep/x.tmp":      {},
+		"home/fresh/.git/HEAD":            {},
+		"home/fresh/new.go":               {},
+	}
+
+	r := NewRoot("home")
+	r.FS = mfs
+	r.Git = true
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	want := map[string]GitStatus{
+		"repo/.gitignore":        GitTracked,
+		"repo/main.go":           GitTracked,
+		"repo/.main.go.swp":      GitIgnored,
+		"repo/big.iso":           GitUntracked,
+		"repo/small.txt":         GitUntracked,
+		"repo/lib/lib.go":        GitTracked,
+		"repo/lib/.gitignore":    GitUntracked,
+		"repo/lib/scratch.tmp":   GitUntracked,
+		"repo/lib/gen/code.go":   GitIgnored,
+		"repo/out/keep.bin":      GitTracked,
+		"repo/out/build.bin":     GitIgnored,
+		"repo/out/.gitignore":    GitIgnored,
+		"repo/vendor/dep/dep.go": GitTracked,
+		"repo/vendor/dep/x.tmp":  GitUntracked,
+		"fresh/new.go":           GitUntracked,
+	}
+	dn.walk(func(rel string, n Node) bool {
+		l, ok := n.(*Leaf)
+		if !ok {
+			return true
+		}
+		gs, found := GitStatusOf(l)
+		if w, ok := want[rel]; ok {
+			assert.True(t, found, rel)
+			assert.Equal(t, w, gs, rel)
+		} else {
+			assert.False(t, found, rel)
+		}
+		return true
+	})
+
+	var untracked []string
+	for _, l := range GitLeaves(dn, GitUntracked) {
+		untracked = append(untracked, l.Path())
+	}
+	assert.Equal(t, []string{
+		"home/repo/big.iso",
+		"home/repo/lib/.gitignore",
+		"home/repo/small.txt",
+		"home/fresh/new.go",
+		"home/repo/lib/scratch.tmp",
+		"home/repo/vendor/dep/x.tmp",
+	}, untracked)
+
+	t.Run("bad index", func(t *testing.T) {
+		mfs := fstest.MapFS{
+			"home/repo/.git/index": {Data: []byte("garbage")},
+			"home/repo/a.go":       {},
+		}
+		r := NewRoot("home")
+		r.FS = mfs
+		r.Git = true
+		dn, err := r.Run()
+		assert.ErrorIs(t, err, errGitIndex)
+		assert.ErrorContains(t, err, "home/repo/.git/index")
+		require.NotNil(t, dn)
+		_, ok := GitStatusOf(dn.Lookup("repo/a.go").(*Leaf))
+		assert.False(t, ok)
+	})
+}
+
+// TestGitCommand checks that indexes written by git itself are understood
+func TestGitCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run(version, func(t *testing.T) {
+			dir := t.TempDir()
+			git := func(args ...string) {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = dir
+				cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+				out, err := cmd.CombinedOutput()
+				require.NoError(t, err, string(out))
+			}
+			write := func(name, data string) {
+				p := filepath.Join(dir, filepath.FromSlash(name))
+				require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+				require.NoError(t, os.WriteFile(p, []byte(data), 0o644))
+			}
+
+			git("init", "-q")
+			write(".gitignore", "*.log\n")
+			write("src/a/long-name-one.go", "")
+			write("src/a/long-name-two.go", "")
+			write("src/b.go", "")
+			write("debug.log", "")
+			write("new.go", "")
+			git("add", ".gitignore", "src")
+			git("update-index", "--index-version", version)
+
+			r := NewRoot(dir)
+			r.Git = true
+			dn, err := r.Run()
+			require.NoError(t, err)
+
+			for rel, want := range map[string]GitStatus{
+				".gitignore":             GitTracked,
+				"src/a/long-name-one.go": GitTracked,
+				"src/a/long-name-two.go": GitTracked,
+				"src/b.go":               GitTracked,
+				"debug.log":              GitIgnored,
+				"new.go":                 GitUntracked,
+			} {
+				gs, ok := GitStatusOf(dn.Lookup(rel).(*Leaf))
+				assert.True(t, ok, rel)
+				assert.Equal(t, want, gs, rel)
+			}
+		})
+	}
+}
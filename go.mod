@@ -2,10 +2,19 @@ module github.com/samf/ctree
 
 go 1.21
 
-require github.com/stretchr/testify v1.7.2
+require (
+	github.com/klauspost/compress v1.17.4
+	github.com/stretchr/testify v1.7.2
+	github.com/zeebo/blake3 v0.2.3
+	github.com/zeebo/xxh3 v1.0.2
+	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
+	golang.org/x/text v0.14.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,39 @@
+package ctree
+
+// Group is a set of nodes which share a key
+type Group struct {
+	Key   string
+	Nodes []Node
+	// Count is the number of nodes in the group
+	Count int64
+	// Bytes is the total size of the leaves in the group
+	Bytes int64
+}
+
+// GroupBy groups every node beneath dn by key, such as its owner, its
+// project directory, or the year it was modified. Nodes for which key
+// returns "" are left out.
+func GroupBy(dn *DNode, key func(Node) string) map[string]*Group {
+	groups := map[string]*Group{}
+
+	dn.walk(func(_ string, n Node) bool {
+		k := key(n)
+		if k == "" {
+			return true
+		}
+
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{Key: k}
+			groups[k] = g
+		}
+		g.Nodes = append(g.Nodes, n)
+		g.Count++
+		if l, ok := n.(*Leaf); ok {
+			g.Bytes += (*l.info).Size()
+		}
+		return true
+	})
+
+	return groups
+}
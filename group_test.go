@@ -0,0 +1,49 @@
+package ctree
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBy(t *testing.T) {
+	dn := scanCopy(t, nil)
+
+	t.Run("by top directory", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		rel := func(n Node) string {
+			return strings.TrimPrefix(n.Path(), dn.Path()+"/")
+		}
+		groups := GroupBy(dn, func(n Node) string {
+			return strings.SplitN(rel(n), "/", 2)[0]
+		})
+
+		require.Len(groups, 2)
+		ceswift := groups["ceswift"]
+		require.NotNil(ceswift)
+		assert.Equal("ceswift", ceswift.Key)
+		assert.Equal(int64(4), ceswift.Count)
+		assert.Len(ceswift.Nodes, 4)
+		assert.Equal(int64(24), ceswift.Bytes)
+		assert.Equal(int64(38), groups["wsfitzpa"].Bytes)
+	})
+
+	t.Run("empty keys are left out", func(t *testing.T) {
+		assert := assert.New(t)
+
+		groups := GroupBy(dn, func(n Node) string {
+			if _, ok := n.(*DNode); ok {
+				return ""
+			}
+			return path.Ext(n.Path())
+		})
+		assert.Len(groups, 1)
+		assert.Equal(int64(2), groups[".cshrc"].Count)
+		assert.NotContains(groups, "")
+	})
+}
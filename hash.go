@@ -0,0 +1,148 @@
+package ctree
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+	"runtime/pprof"
+
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+)
+
+// Hasher is a hash algorithm that a Root can compute for every regular
+// file it finds
+type Hasher struct {
+	// Name identifies the digest, in Leaf.Hash and in snapshots
+	Name string
+	New  func() hash.Hash
+}
+
+// The hashers from the standard library
+var (
+	MD5    = Hasher{Name: "md5", New: md5.New}
+	SHA1   = Hasher{Name: "sha1", New: sha1.New}
+	SHA256 = Hasher{Name: "sha256", New: sha256.New}
+	SHA512 = Hasher{Name: "sha512", New: sha512.New}
+)
+
+// Fast hashers, for when SHA-256 is the bottleneck
+var (
+	// BLAKE3 is the BLAKE3 cryptographic hash, with a 32 byte digest
+	BLAKE3 = Hasher{Name: "blake3", New: func() hash.Hash { return blake3.New() }}
+	// XXH3 is the 64 bit XXH3 hash, which is not cryptographic, but is
+	// far faster than any hash which is
+	XXH3 = Hasher{Name: "xxh3", New: func() hash.Hash { return xxh3.New() }}
+)
+
+// hashBufferSize is the size of each worker's read buffer for hashing
+const hashBufferSize = 256 << 10
+
+// LargeFileSize is the size from which files are handed to the Root's
+// hashing goroutines, rather than hashed by the worker which found them
+const LargeFileSize = 4 << 20
+
+// Hash returns the digest of the leaf's contents computed by the Hasher
+// of the given name, or nil if it was not computed
+func (l *Leaf) Hash(name string) []byte {
+	return l.hashes[name]
+}
+
+// hashLarge hashes the large files handed over by the workers, until the
+// walk is done
+func (w *worker) hashLarge() {
+	defer w.r.hashWG.Done()
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	w.lowerPriority()
+	w.enter(phaseHash)
+	for leaf := range w.r.hashWork {
+		w.r.Pool.acquireWorker(w.r.Priority)
+		leaf.hashOrFail(w)
+		leaf.parent.finish(w.r)
+		w.r.Pool.releaseWorker()
+	}
+}
+
+// hashOrFail hashes the leaf, recording any error on its directory
+func (l *Leaf) hashOrFail(w *worker) {
+	if err := l.hash(w); err != nil {
+		l.fail(w, err)
+	}
+}
+
+// fail records an error in hashing the leaf on its directory, with any
+// others it has
+func (l *Leaf) fail(w *worker, err error) {
+	w.r.errMu.Lock()
+	l.parent.addError(nodeError(l.path, err))
+	w.r.errMu.Unlock()
+}
+
+func (l *Leaf) setHash(name string, sum []byte) {
+	if l.hashes == nil {
+		l.hashes = map[string][]byte{}
+	}
+	l.hashes[name] = sum
+}
+
+// hash reads the leaf once, feeding every one of the Root's hashers
+func (l *Leaf) hash(w *worker) error {
+	r := w.r
+
+	f, err := r.open(l.path)
+	if err != nil {
+		return err
+	}
+	defer r.close(f)
+
+	hs := make([]hash.Hash, len(r.Hashes))
+	ws := make([]io.Writer, len(r.Hashes))
+	for i, h := range r.Hashes {
+		hs[i] = h.New()
+		ws[i] = hs[i]
+	}
+
+	if w.buf == nil {
+		w.buf = make([]byte, hashBufferSize)
+	}
+	if err := r.readAll(f, (*l.info).Size(), io.MultiWriter(ws...), w.buf); err != nil {
+		return err
+	}
+
+	for i, h := range r.Hashes {
+		l.setHash(h.Name, hs[i].Sum(nil))
+	}
+
+	return nil
+}
+
+// onlyReader hides any WriterTo of a file, so that io.CopyBuffer uses the
+// buffer it is given
+type onlyReader struct {
+	io.Reader
+}
+
+// SameHash returns a Comparer which compares the digests of leaves made by
+// the named Hasher. Nodes lacking the digest, including directories, are
+// always the same; combine it with other comparers using AllOf.
+func SameHash(name string) Comparer {
+	return func(a, b Node) bool {
+		al, aok := a.(*Leaf)
+		bl, bok := b.(*Leaf)
+		if !aok || !bok {
+			return true
+		}
+
+		ah, bh := al.Hash(name), bl.Hash(name)
+		if ah == nil || bh == nil {
+			return true
+		}
+		return bytes.Equal(ah, bh)
+	}
+}
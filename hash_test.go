@@ -0,0 +1,174 @@
+package ctree
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashes(t *testing.T) {
+	t.Run("several at once", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where)
+		r.Hashes = []Hasher{SHA256, MD5}
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.Empty(dn.Errors())
+
+		p := path.Join(where, "home/ceswift/bin/worms")
+		contents, err := os.ReadFile(p)
+		require.NoError(err)
+		sha := sha256.Sum256(contents)
+		md := md5.Sum(contents)
+
+		leaf := dn.Lookup("home/ceswift/bin/worms").(*Leaf)
+		assert.Equal(sha[:], leaf.Hash("sha256"))
+		assert.Equal(md[:], leaf.Hash("md5"))
+		assert.Nil(leaf.Hash("sha1"))
+		assert.Equal([]string{"sha256", "md5"}, dn.ScanInfo().Options.Hashes)
+	})
+
+	t.Run("from an fs.FS", func(t *testing.T) {
+		r := NewRoot(".")
+		r.FS = fstest.MapFS{"big": {Data: bytes.Repeat([]byte("x"), 3*hashBufferSize+1)}}
+		r.Hashes = []Hasher{SHA1}
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		h := SHA1.New()
+		h.Write(bytes.Repeat([]byte("x"), 3*hashBufferSize+1))
+		assert.Equal(t, h.Sum(nil), dn.Lookup("big").(*Leaf).Hash("sha1"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		r := NewRoot(".")
+		r.FS = &FaultFS{
+			FS:     tfs,
+			Faults: []Fault{{Pattern: "home/ceswift/.cshrc", Ops: []string{OpOpen}, Err: os.ErrPermission}},
+		}
+		r.Hashes = []Hasher{SHA256}
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		assert.Len(t, dn.Errors(), 1)
+		assert.Nil(t, dn.Lookup("home/ceswift/.cshrc").(*Leaf).Hash("sha256"))
+		assert.NotNil(t, dn.Lookup("home/wsfitzpa/.cshrc").(*Leaf).Hash("sha256"))
+	})
+
+	t.Run("every error is kept", func(t *testing.T) {
+		r := NewRoot(".")
+		r.FS = &FaultFS{
+			FS:     fstest.MapFS{"d/a": {Data: []byte("a")}, "d/b": {Data: []byte("b")}},
+			Faults: []Fault{{Pattern: "d/*", Ops: []string{OpOpen}, Err: os.ErrPermission}},
+		}
+		r.Hashes = []Hasher{SHA256}
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		err = dn.Lookup("d").(*DNode).Error()
+		assert.ErrorIs(t, err, ErrPermission)
+		assert.Contains(t, err.Error(), "d/a")
+		assert.Contains(t, err.Error(), "d/b")
+	})
+
+	t.Run("snapshots keep hashes", func(t *testing.T) {
+		require := require.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where)
+		r.Hashes = []Hasher{SHA256, SHA512}
+		dn, err := r.Run()
+		require.NoError(err)
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		require.NoError(ValidateSnapshot(bytes.NewReader(buf.Bytes())))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+
+		for _, name := range []string{"sha256", "sha512"} {
+			want := dn.Lookup("home/wsfitzpa/bin/zrun").(*Leaf).Hash(name)
+			assert.NotNil(t, want)
+			assert.Equal(t, want, loaded.Lookup("home/wsfitzpa/bin/zrun").(*Leaf).Hash(name))
+		}
+	})
+}
+
+func TestSameHash(t *testing.T) {
+	scan := func(contents string) *DNode {
+		r := NewRoot(".")
+		r.FS = fstest.MapFS{"f": {Data: []byte(contents)}}
+		r.Hashes = []Hasher{SHA256}
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	a, b := scan("same size 1"), scan("same size 2")
+	assert.Empty(t, Diff(a, b))
+
+	changes := DiffFunc(a, b, AllOf(DefaultComparer, SameHash("sha256")))
+	require.Len(t, changes, 1)
+	assert.Equal(t, "f", changes[0].Path)
+
+	assert.Empty(t, DiffFunc(a, scan("same size 1"), SameHash("sha256")))
+	assert.Empty(t, DiffFunc(a, b, SameHash("md5")), "missing digests are the same")
+}
+
+func TestFastHashes(t *testing.T) {
+	r := NewRoot(".")
+	r.FS = fstest.MapFS{"empty": {}}
+	r.Hashes = []Hasher{BLAKE3, XXH3}
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	leaf := dn.Lookup("empty").(*Leaf)
+	assert.Equal(t, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+		hex.EncodeToString(leaf.Hash("blake3")))
+	assert.Equal(t, "2d06800538d394c2", hex.EncodeToString(leaf.Hash("xxh3")))
+}
+
+func TestLargeFileHashing(t *testing.T) {
+	big := func(b byte) []byte { return bytes.Repeat([]byte{b}, LargeFileSize+1) }
+
+	fsys := fstest.MapFS{"small": {Data: []byte("small")}}
+	for i := 0; i < 6; i++ {
+		fsys[fmt.Sprintf("d/big%d", i)] = &fstest.MapFile{Data: big(byte(i))}
+	}
+
+	r := NewRoot(".")
+	r.FS = &FaultFS{
+		FS:     fsys,
+		Faults: []Fault{{Pattern: "d/big5", Ops: []string{OpOpen}, Err: os.ErrPermission}},
+	}
+	r.Hashes = []Hasher{BLAKE3, SHA256}
+	r.HashThreads = 3
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		sum := sha256.Sum256(big(byte(i)))
+		leaf := dn.Lookup(fmt.Sprintf("d/big%d", i)).(*Leaf)
+		assert.Equal(t, sum[:], leaf.Hash("sha256"), i)
+		assert.Len(t, leaf.Hash("blake3"), 32)
+	}
+	assert.NotNil(t, dn.Lookup("small").(*Leaf).Hash("sha256"))
+
+	errs := dn.Errors()
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], os.ErrPermission)
+}
@@ -0,0 +1,123 @@
+package ctree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity is what tells one file from another across scans, as an
+// incremental backup tool needs to: the device and inode it lives at, the
+// inode's generation where the platform has one, and the size and times
+// which change when it is written. A file whose Identity is unchanged
+// since the last scan may be taken as unchanged itself.
+//
+// Times are in UTC, so that Identities may be compared with ==, and used
+// as map keys.
+type Identity struct {
+	Dev, Ino uint64
+	// Gen is the inode's generation number, which tells a reused inode
+	// from its last owner, if HasGen says it is known
+	Gen    uint64
+	HasGen bool
+	Size   int64
+	// ModTime is when the contents last changed, and ChangeTime when the
+	// inode did; ChangeTime is zero if it is not known
+	ModTime, ChangeTime time.Time
+}
+
+// Identity returns the leaf's Identity, if the platform, or the snapshot
+// it was read from, gives its device and inode
+func (l *Leaf) Identity() (Identity, bool) {
+	fi := *l.info
+	si := statOf(fi)
+	if !si.hasDev {
+		return Identity{}, false
+	}
+
+	id := Identity{
+		Dev:     si.dev,
+		Ino:     si.ino,
+		Gen:     si.gen,
+		HasGen:  si.hasGen,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UTC(),
+	}
+	if !si.ctime.IsZero() {
+		id.ChangeTime = si.ctime.UTC()
+	}
+
+	return id, true
+}
+
+// String returns the Identity in the form read by ParseIdentity:
+// dev:ino:gen:size:mtime:ctime, all in decimal, with times in nanoseconds
+// since the Unix epoch, and an unknown gen or ctime written as "-"
+func (id Identity) String() string {
+	gen, ctime := "-", "-"
+	if id.HasGen {
+		gen = strconv.FormatUint(id.Gen, 10)
+	}
+	if !id.ChangeTime.IsZero() {
+		ctime = strconv.FormatInt(id.ChangeTime.UnixNano(), 10)
+	}
+
+	return fmt.Sprintf("%d:%d:%s:%d:%d:%s", id.Dev, id.Ino, gen, id.Size, id.ModTime.UnixNano(), ctime)
+}
+
+// MarshalText returns the Identity as String does
+func (id Identity) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText reads an Identity as ParseIdentity does
+func (id *Identity) UnmarshalText(text []byte) error {
+	parsed, err := ParseIdentity(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// ParseIdentity reads an Identity written by Identity.String
+func ParseIdentity(s string) (Identity, error) {
+	fields := strings.Split(s, ":")
+	if len(fields) != 6 {
+		return Identity{}, fmt.Errorf("%q: identity wants 6 fields, has %d", s, len(fields))
+	}
+
+	var id Identity
+	var err, e error
+	bad := func(what string, e error) {
+		if err == nil && e != nil {
+			err = fmt.Errorf("%q: bad %s in identity: %w", s, what, e)
+		}
+	}
+
+	id.Dev, e = strconv.ParseUint(fields[0], 10, 64)
+	bad("dev", e)
+	id.Ino, e = strconv.ParseUint(fields[1], 10, 64)
+	bad("ino", e)
+	if fields[2] != "-" {
+		id.Gen, e = strconv.ParseUint(fields[2], 10, 64)
+		id.HasGen = true
+		bad("gen", e)
+	}
+	id.Size, e = strconv.ParseInt(fields[3], 10, 64)
+	bad("size", e)
+	mtime, e := strconv.ParseInt(fields[4], 10, 64)
+	bad("mtime", e)
+	id.ModTime = time.Unix(0, mtime).UTC()
+	if fields[5] != "-" {
+		ctime, e := strconv.ParseInt(fields[5], 10, 64)
+		bad("ctime", e)
+		id.ChangeTime = time.Unix(0, ctime).UTC()
+	}
+
+	if err != nil {
+		return Identity{}, err
+	}
+	return id, nil
+}
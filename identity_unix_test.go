@@ -0,0 +1,106 @@
+//go:build unix
+
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	leafID := func(t *testing.T, dn *DNode, name string) Identity {
+		l, ok := dn.Lookup(name).(*Leaf)
+		require.True(t, ok)
+		id, ok := l.Identity()
+		require.True(t, ok)
+		return id
+	}
+
+	t.Run("describes the file", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		fi, err := os.Stat(path.Join(dn.Path(), "ceswift/.cshrc"))
+		require.NoError(t, err)
+
+		id := leafID(t, dn, "ceswift/.cshrc")
+		assert.Equal(statOf(fi).ino, id.Ino)
+		assert.Equal(fi.Size(), id.Size)
+		assert.True(epoch.Equal(id.ModTime))
+		assert.False(id.ChangeTime.IsZero())
+		assert.NotEqual(id, leafID(t, dn, "wsfitzpa/.cshrc"))
+	})
+
+	t.Run("hard links share it", func(t *testing.T) {
+		dn := scanCopy(t, func(where string) {
+			require.NoError(t, os.Link(path.Join(where, "ceswift/.cshrc"), path.Join(where, "link")))
+		})
+		assert.Equal(t, leafID(t, dn, "ceswift/.cshrc"), leafID(t, dn, "link"))
+	})
+
+	t.Run("changes with the file", func(t *testing.T) {
+		before := scanCopy(t, nil)
+		after := scanCopy(t, func(where string) {
+			writeFile(t, path.Join(where, "ceswift/.cshrc"), "set path=(/bin)\n")
+		})
+		a, b := leafID(t, before, "ceswift/.cshrc"), leafID(t, after, "ceswift/.cshrc")
+		assert.NotEqual(t, a.ModTime, b.ModTime)
+	})
+
+	t.Run("round trips", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		id := leafID(t, dn, "ceswift/.cshrc")
+
+		parsed, err := ParseIdentity(id.String())
+		require.NoError(err)
+		assert.Equal(id, parsed)
+
+		b, err := json.Marshal(map[string]Identity{"x": id})
+		require.NoError(err)
+		var m map[string]Identity
+		require.NoError(json.Unmarshal(b, &m))
+		assert.Equal(id, m["x"])
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+		assert.Equal(id, leafID(t, loaded, "ceswift/.cshrc"))
+	})
+
+	t.Run("not from an FS", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(t, err)
+		l, ok := dn.Lookup("ceswift/.cshrc").(*Leaf)
+		require.True(t, ok)
+		_, ok = l.Identity()
+		assert.False(t, ok)
+	})
+
+	t.Run("unknown parts", func(t *testing.T) {
+		id := Identity{Dev: 1, Ino: 2, Size: 3, ModTime: time.Unix(4, 5).UTC()}
+		assert.Equal(t, "1:2:-:3:4000000005:-", id.String())
+		parsed, err := ParseIdentity(id.String())
+		require.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	})
+
+	t.Run("bad text", func(t *testing.T) {
+		for _, s := range []string{"", "1:2:3", "1:2:3:4:5:6:7", "x:2:3:4:5:6", "1:2:3:4:5:y"} {
+			_, err := ParseIdentity(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
@@ -0,0 +1,594 @@
+package ctree
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The names tar layers use for deletions: a whiteout deletes the file it
+// names from the layers beneath, and an opaque whiteout deletes
+// everything in its directory from the layers beneath
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// LayerFS stacks tar layers, each optionally compressed with gzip or
+// zstd, as a container runtime does, the first at the bottom, applying the
+// whiteouts of each to those beneath. The result is held in memory, and
+// may be walked by setting Root.FS, with a Root.Path of ".", so that
+// images can be compared with each other and with live filesystems by
+// Diff. The owners of files are kept; see Owner.
+func LayerFS(layers ...io.Reader) (fs.FS, error) {
+	lfs := newLayerFS()
+	for i, layer := range layers {
+		if err := lfs.apply(layer); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+
+	return lfs, nil
+}
+
+// layerNode is a file of a layerFS
+type layerNode struct {
+	hdr      *tar.Header
+	data     []byte
+	children map[string]*layerNode // of directories
+}
+
+func (ln *layerNode) isDir() bool {
+	return ln.hdr.Typeflag == tar.TypeDir
+}
+
+// layerFS is the merged layers of an image
+type layerFS struct {
+	root *layerNode
+}
+
+func newLayerFS() *layerFS {
+	return &layerFS{root: &layerNode{
+		hdr:      &tar.Header{Name: ".", Typeflag: tar.TypeDir, Mode: 0o755},
+		children: map[string]*layerNode{},
+	}}
+}
+
+var (
+	_ fs.ReadDirFS = &layerFS{}
+	_ fs.StatFS    = &layerFS{}
+)
+
+// layerEntry is a file of a layer, to be added once the layer's
+// whiteouts have been applied
+type layerEntry struct {
+	name string
+	hdr  *tar.Header
+	data []byte
+}
+
+// apply reads a layer and lays it over the tree
+func (lfs *layerFS) apply(layer io.Reader) error {
+	r, err := decompress(layer)
+	if err != nil {
+		return err
+	}
+
+	var entries []layerEntry
+	var whiteouts, opaque []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(path.Clean("/"+hdr.Name), "/"))
+		dir, base := path.Split(name)
+		switch {
+		case name == ".":
+			continue
+		case base == opaqueWhiteout:
+			opaque = append(opaque, path.Clean(dir))
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			whiteouts = append(whiteouts, path.Join(dir, base[len(whiteoutPrefix):]))
+			continue
+		}
+
+		e := layerEntry{name: name, hdr: hdr}
+		if hdr.Typeflag == tar.TypeReg {
+			if e.data, err = io.ReadAll(tr); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	for _, dir := range opaque {
+		if dn := lfs.lookup(dir); dn != nil && dn.isDir() {
+			dn.children = map[string]*layerNode{}
+		}
+	}
+	for _, name := range whiteouts {
+		if parent := lfs.lookup(path.Dir(name)); parent != nil && parent.isDir() {
+			delete(parent.children, path.Base(name))
+		}
+	}
+	for _, e := range entries {
+		if err := lfs.add(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add places e in the tree, replacing whatever is there, unless both are
+// directories, when the contents of the old one are kept
+func (lfs *layerFS) add(e layerEntry) error {
+	parent := lfs.mkdirAll(path.Dir(e.name))
+	base := path.Base(e.name)
+
+	ln := &layerNode{hdr: e.hdr, data: e.data}
+	switch e.hdr.Typeflag {
+	case tar.TypeDir:
+		ln.children = map[string]*layerNode{}
+		if old := parent.children[base]; old != nil && old.isDir() {
+			ln.children = old.children
+		}
+	case tar.TypeLink:
+		target := lfs.lookup(path.Clean(strings.TrimPrefix(path.Clean("/"+e.hdr.Linkname), "/")))
+		if target == nil || target.isDir() {
+			return fmt.Errorf("%s: hard link to missing %s", e.name, e.hdr.Linkname)
+		}
+		hdr := *target.hdr
+		hdr.Name = e.hdr.Name
+		ln.hdr, ln.data = &hdr, target.data
+	}
+
+	parent.children[base] = ln
+	return nil
+}
+
+// mkdirAll returns the directory at name, creating it and its parents,
+// and replacing whatever else is in the way, as tar does
+func (lfs *layerFS) mkdirAll(name string) *layerNode {
+	dn := lfs.root
+	if name == "." {
+		return dn
+	}
+	for _, elem := range strings.Split(name, "/") {
+		next := dn.children[elem]
+		if next == nil || !next.isDir() {
+			next = &layerNode{
+				hdr:      &tar.Header{Name: elem, Typeflag: tar.TypeDir, Mode: 0o755},
+				children: map[string]*layerNode{},
+			}
+			dn.children[elem] = next
+		}
+		dn = next
+	}
+	return dn
+}
+
+// lookup finds the node at name, without following symbolic links
+func (lfs *layerFS) lookup(name string) *layerNode {
+	ln := lfs.root
+	if name == "." {
+		return ln
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if !ln.isDir() {
+			return nil
+		}
+		if ln = ln.children[elem]; ln == nil {
+			return nil
+		}
+	}
+	return ln
+}
+
+// maxLinks is how many symbolic links resolve follows
+const maxLinks = 40
+
+// resolve finds the node at name, following symbolic links, which are
+// relative to the root of the image however absolute they are
+func (lfs *layerFS) resolve(op, name string) (*layerNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	todo := strings.Split(name, "/")
+	if name == "." {
+		todo = nil
+	}
+	var stack []*layerNode
+	ln, links := lfs.root, 0
+	for len(todo) > 0 {
+		elem := todo[0]
+		todo = todo[1:]
+
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(stack) > 0 {
+				ln, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		if !ln.isDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		next := ln.children[elem]
+		if next == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		if next.hdr.Typeflag == tar.TypeSymlink {
+			if links++; links > maxLinks {
+				return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many links")}
+			}
+			target := next.hdr.Linkname
+			if strings.HasPrefix(target, "/") {
+				ln, stack = lfs.root, nil
+			}
+			todo = append(strings.Split(target, "/"), todo...)
+			continue
+		}
+
+		stack = append(stack, ln)
+		ln = next
+	}
+
+	return ln, nil
+}
+
+// Open opens the file at name, following symbolic links
+func (lfs *layerFS) Open(name string) (fs.File, error) {
+	ln, err := lfs.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := layerInfo{name: path.Base(name), ln: ln}
+	if ln.isDir() {
+		return &layerDir{info: info, entries: ln.entries()}, nil
+	}
+	return &layerFile{info: info, Reader: bytes.NewReader(ln.data)}, nil
+}
+
+// Stat returns the FileInfo of the file at name, following symbolic links
+func (lfs *layerFS) Stat(name string) (fs.FileInfo, error) {
+	ln, err := lfs.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return layerInfo{name: path.Base(name), ln: ln}, nil
+}
+
+// Lstat returns the FileInfo of the file at name, without following it
+// if it is a symbolic link
+func (lfs *layerFS) Lstat(name string) (fs.FileInfo, error) {
+	ln, err := lfs.resolveLink("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return layerInfo{name: path.Base(name), ln: ln}, nil
+}
+
+// ReadLink returns the target of the symbolic link at name
+func (lfs *layerFS) ReadLink(name string) (string, error) {
+	ln, err := lfs.resolveLink("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if ln.hdr.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return ln.hdr.Linkname, nil
+}
+
+// resolveLink is like resolve, but doesn't follow the last element of
+// name
+func (lfs *layerFS) resolveLink(op, name string) (*layerNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return lfs.root, nil
+	}
+
+	dir, err := lfs.resolve(op, path.Dir(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if ln := dir.children[path.Base(name)]; dir.isDir() && ln != nil {
+		return ln, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the directory at name, sorted by name
+func (lfs *layerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	ln, err := lfs.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !ln.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return ln.entries(), nil
+}
+
+// entries returns the contents of a directory, sorted by name
+func (ln *layerNode) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(ln.children))
+	for name, child := range ln.children {
+		entries = append(entries, fs.FileInfoToDirEntry(layerInfo{name: name, ln: child}))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// layerInfo is the FileInfo of a layerNode
+type layerInfo struct {
+	name string
+	ln   *layerNode
+}
+
+func (li layerInfo) Name() string       { return li.name }
+func (li layerInfo) Size() int64        { return int64(len(li.ln.data)) }
+func (li layerInfo) ModTime() time.Time { return li.ln.hdr.ModTime }
+func (li layerInfo) IsDir() bool        { return li.ln.isDir() }
+
+func (li layerInfo) Sys() any {
+	return &statInfo{
+		uid:      uint32(li.ln.hdr.Uid),
+		gid:      uint32(li.ln.hdr.Gid),
+		hasOwner: true,
+		ctime:    li.ln.hdr.ChangeTime,
+	}
+}
+
+func (li layerInfo) Mode() fs.FileMode {
+	// FileInfo gives the type bits, but its size is of the header
+	return li.ln.hdr.FileInfo().Mode()
+}
+
+// layerFile is an open regular file of a layerFS
+type layerFile struct {
+	info layerInfo
+	*bytes.Reader
+}
+
+func (f *layerFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *layerFile) Close() error               { return nil }
+
+// layerDir is an open directory of a layerFS
+type layerDir struct {
+	info    layerInfo
+	entries []fs.DirEntry
+}
+
+func (d *layerDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *layerDir) Close() error               { return nil }
+
+func (d *layerDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *layerDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// ImageOptions controls ImageFS
+type ImageOptions struct {
+	// Platform chooses among the images of a multi-platform image, as
+	// "os/architecture" or "os/architecture/variant", such as
+	// "linux/arm64/v8"; the first image if empty
+	Platform string
+}
+
+// ociDescriptor points to a blob of an OCI image layout
+type ociDescriptor struct {
+	Digest   string `json:"digest"`
+	Platform *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// ociIndex is an image index, or the manifest of an image, which is
+// enough like one to share its type
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ImageFS stacks the layers of the container image in layout, which is an
+// OCI image layout, such as "skopeo copy" writes, or the contents of a
+// "docker save" archive; see LayerFS. The digests of the blobs read are
+// checked.
+func ImageFS(layout fs.FS, opts *ImageOptions) (fs.FS, error) {
+	if opts == nil {
+		opts = &ImageOptions{}
+	}
+
+	var blobs []string
+	var err error
+	if _, statErr := fs.Stat(layout, "index.json"); statErr == nil {
+		blobs, err = ociLayers(layout, opts)
+	} else {
+		blobs, err = dockerLayers(layout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lfs := newLayerFS()
+	for _, blob := range blobs {
+		if err := lfs.applyBlob(layout, blob); err != nil {
+			return nil, err
+		}
+	}
+	return lfs, nil
+}
+
+// applyBlob lays the layer in blob over the tree, checking its digest if
+// it is named by one
+func (lfs *layerFS) applyBlob(layout fs.FS, blob string) error {
+	f, err := layout.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	r := io.TeeReader(f, h)
+	if err := lfs.apply(r); err != nil {
+		return fmt.Errorf("%s: %w", blob, err)
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+
+	if path.Base(path.Dir(blob)) == "sha256" {
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != path.Base(blob) {
+			return fmt.Errorf("%s: digest is sha256:%s", blob, sum)
+		}
+	}
+	return nil
+}
+
+// ociLayers finds the blobs holding the layers of the image in an OCI
+// image layout
+func ociLayers(layout fs.FS, opts *ImageOptions) ([]string, error) {
+	var idx ociIndex
+	if err := readJSON(layout, "index.json", &idx); err != nil {
+		return nil, err
+	}
+
+	for idx.Layers == nil {
+		desc, err := choosePlatform(idx.Manifests, opts.Platform)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := blobPath(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		idx = ociIndex{}
+		if err := readJSON(layout, blob, &idx); err != nil {
+			return nil, err
+		}
+		if idx.Layers == nil && idx.Manifests == nil {
+			return nil, fmt.Errorf("%s: neither an index nor a manifest", blob)
+		}
+	}
+
+	var blobs []string
+	for _, layer := range idx.Layers {
+		blob, err := blobPath(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, nil
+}
+
+// choosePlatform picks the manifest for platform from those of an index,
+// or failing that, the first which doesn't say, such as another index
+func choosePlatform(manifests []ociDescriptor, platform string) (ociDescriptor, error) {
+	if len(manifests) == 0 {
+		return ociDescriptor{}, errors.New("image index lists no manifests")
+	}
+	if platform == "" {
+		return manifests[0], nil
+	}
+
+	var unknown *ociDescriptor
+	for i, m := range manifests {
+		if m.Platform == nil {
+			if unknown == nil {
+				unknown = &manifests[i]
+			}
+			continue
+		}
+		p := m.Platform.OS + "/" + m.Platform.Architecture
+		if p == platform || m.Platform.Variant != "" && p+"/"+m.Platform.Variant == platform {
+			return m, nil
+		}
+	}
+	if unknown != nil {
+		return *unknown, nil
+	}
+	return ociDescriptor{}, fmt.Errorf("no image for platform %s", platform)
+}
+
+// blobPath returns where an OCI image layout keeps the blob with digest
+func blobPath(digest string) (string, error) {
+	alg, sum, ok := strings.Cut(digest, ":")
+	if !ok || alg == "" || sum == "" || strings.Contains(sum, "/") || !fs.ValidPath(alg) {
+		return "", fmt.Errorf("malformed digest %q", digest)
+	}
+	return path.Join("blobs", alg, sum), nil
+}
+
+// dockerLayers finds the layers of the first image in a "docker save"
+// archive
+func dockerLayers(layout fs.FS) ([]string, error) {
+	var manifest []struct {
+		Layers []string
+	}
+	if err := readJSON(layout, "manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest) == 0 {
+		return nil, errors.New("manifest.json lists no images")
+	}
+	return manifest[0].Layers, nil
+}
+
+// readJSON decodes the JSON file at name into v
+func readJSON(fsys fs.FS, name string, v any) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
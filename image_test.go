@@ -0,0 +1,268 @@
+package ctree
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layerTar is a tar layer of hdrs; the contents of regular files are
+// their Linkname, which is cleared
+func layerTar(t *testing.T, hdrs ...tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		var data string
+		if hdr.Typeflag == tar.TypeReg {
+			data, hdr.Linkname = hdr.Linkname, ""
+			hdr.Size = int64(len(data))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+		hdr.ModTime = time.Unix(1700000000, 0)
+		require.NoError(t, tw.WriteHeader(&hdr))
+		_, err := io.WriteString(tw, data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// testLayers are a base layer, and one changing it
+func testLayers(t *testing.T) (base, top []byte) {
+	dir := func(name string) tar.Header {
+		return tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0o755}
+	}
+	file := func(name, data string) tar.Header {
+		return tar.Header{Name: name, Typeflag: tar.TypeReg, Linkname: data}
+	}
+
+	sh := file("bin/sh", "#!shell")
+	sh.Mode, sh.Uid, sh.Gid = 0o755, 0, 0
+	passwd := file("etc/passwd", "root:x:0:0:changed")
+	passwd.Uid, passwd.Gid = 1000, 50
+
+	base = layerTar(t,
+		dir("./"),
+		dir("./bin/"),
+		sh,
+		tar.Header{Name: "./bin/bash", Typeflag: tar.TypeSymlink, Linkname: "sh"},
+		tar.Header{Name: "./bin/rsh", Typeflag: tar.TypeLink, Linkname: "bin/sh"},
+		dir("./etc/"),
+		file("etc/passwd", "root:x:0:0"),
+		file("./etc/hosts", "localhost"),
+		file("./usr/lib/a.so", "a"),
+		file("./usr/lib/b.so", "b"),
+		file("./opt/old/x", "x"),
+		file("./replaced", "was a file"),
+		dir("./var/log/"),
+	)
+	top = gzipped(t, layerTar(t,
+		tar.Header{Name: "etc/.wh.hosts", Typeflag: tar.TypeReg},
+		passwd,
+		tar.Header{Name: "usr/lib/.wh..wh..opq", Typeflag: tar.TypeReg},
+		file("usr/lib/c.so", "c"),
+		tar.Header{Name: "opt/.wh.old", Typeflag: tar.TypeReg},
+		file("replaced/now-a-dir", ""),
+		file("var/log/new.log", "log"),
+		tar.Header{Name: "etc/os", Typeflag: tar.TypeSymlink, Linkname: "/usr/lib/../../etc/passwd"},
+	))
+	return base, top
+}
+
+func TestLayerFS(t *testing.T) {
+	base, top := testLayers(t)
+	fsys, err := LayerFS(bytes.NewReader(base), bytes.NewReader(top))
+	require.NoError(t, err)
+
+	require.NoError(t, fstest.TestFS(fsys,
+		"bin/sh", "bin/bash", "bin/rsh", "etc/passwd", "etc/os", "usr/lib/c.so",
+		"replaced/now-a-dir", "var/log/new.log"))
+
+	r := NewRoot(".")
+	r.FS = fsys
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	var paths []string
+	for _, n := range dn.Flatten() {
+		paths = append(paths, n.Path())
+	}
+	assert.ElementsMatch(t, []string{
+		".", "bin", "bin/sh", "bin/bash", "bin/rsh",
+		"etc", "etc/passwd", "etc/os",
+		"usr", "usr/lib", "usr/lib/c.so",
+		"opt",
+		"replaced", "replaced/now-a-dir",
+		"var", "var/log", "var/log/new.log",
+	}, paths)
+
+	info := func(p string) fs.FileInfo { return *dn.Lookup(p).Info() }
+	assert.Equal(t, int64(len("root:x:0:0:changed")), info("etc/passwd").Size())
+	assert.Equal(t, fs.FileMode(0o755), info("bin/sh").Mode())
+	assert.Equal(t, fs.ModeSymlink, info("bin/bash").Mode().Type())
+	assert.Equal(t, int64(len("#!shell")), info("bin/rsh").Size())
+	assert.True(t, info("replaced").IsDir())
+	assert.Equal(t, time.Unix(1700000000, 0), info("etc/passwd").ModTime())
+
+	uid, gid, ok := Owner(dn.Lookup("etc/passwd"))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1000), uid)
+	assert.Equal(t, uint32(50), gid)
+
+	data, err := fs.ReadFile(fsys, "bin/bash")
+	require.NoError(t, err)
+	assert.Equal(t, "#!shell", string(data))
+	data, err = fs.ReadFile(fsys, "etc/os")
+	require.NoError(t, err)
+	assert.Equal(t, "root:x:0:0:changed", string(data))
+
+	t.Run("diffs", func(t *testing.T) {
+		lower, err := LayerFS(bytes.NewReader(base))
+		require.NoError(t, err)
+		r := NewRoot(".")
+		r.FS = lower
+		old, err := r.Run()
+		require.NoError(t, err)
+
+		changes := map[string]ChangeKind{}
+		for _, c := range Diff(old, dn) {
+			changes[c.Path] = c.Kind
+		}
+		assert.Equal(t, Removed, changes["etc/hosts"])
+		assert.Equal(t, Modified, changes["etc/passwd"])
+		assert.Equal(t, Removed, changes["usr/lib/a.so"])
+		assert.Equal(t, Added, changes["usr/lib/c.so"])
+		assert.Equal(t, Removed, changes["opt/old"])
+		assert.Equal(t, Modified, changes["replaced"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := LayerFS(bytes.NewReader(base), bytes.NewReader(bytes.Repeat([]byte("not a tar file "), 100)))
+		assert.ErrorContains(t, err, "layer 1")
+
+		bad := layerTar(t, tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "nowhere"})
+		_, err = LayerFS(bytes.NewReader(bad))
+		assert.ErrorContains(t, err, "hard link to missing nowhere")
+
+		loop := layerTar(t,
+			tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "b"},
+			tar.Header{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a"},
+		)
+		fsys, err := LayerFS(bytes.NewReader(loop))
+		require.NoError(t, err)
+		_, err = fsys.Open("a")
+		assert.ErrorContains(t, err, "too many links")
+	})
+}
+
+func TestImageFS(t *testing.T) {
+	base, top := testLayers(t)
+
+	layout := fstest.MapFS{}
+	blob := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		layout["blobs/sha256/"+digest] = &fstest.MapFile{Data: data}
+		return "sha256:" + digest
+	}
+	blobJSON := func(v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return blob(data)
+	}
+	setIndex := func(v any) {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		layout["index.json"] = &fstest.MapFile{Data: data}
+	}
+	layer := func(data []byte) map[string]string {
+		return map[string]string{"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": blob(data)}
+	}
+
+	arm := blobJSON(map[string]any{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"layers":    []any{layer(base), layer(top)},
+	})
+	amd := blobJSON(map[string]any{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"layers":    []any{layer(base)},
+	})
+	multi := blobJSON(map[string]any{
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": []any{
+			map[string]any{"digest": amd, "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+			map[string]any{"digest": arm, "platform": map[string]string{"os": "linux", "architecture": "arm64", "variant": "v8"}},
+		},
+	})
+	setIndex(map[string]any{"manifests": []any{map[string]any{"mediaType": "application/vnd.oci.image.index.v1+json", "digest": multi}}})
+
+	has := func(fsys fs.FS, name string) bool {
+		_, err := fs.Stat(fsys, name)
+		return err == nil
+	}
+
+	fsys, err := ImageFS(layout, nil)
+	require.NoError(t, err)
+	assert.True(t, has(fsys, "etc/hosts"), "the first image is amd64")
+
+	for _, platform := range []string{"linux/arm64", "linux/arm64/v8"} {
+		fsys, err = ImageFS(layout, &ImageOptions{Platform: platform})
+		require.NoError(t, err, platform)
+		assert.False(t, has(fsys, "etc/hosts"), platform)
+		assert.True(t, has(fsys, "usr/lib/c.so"), platform)
+	}
+
+	_, err = ImageFS(layout, &ImageOptions{Platform: "windows/amd64"})
+	assert.ErrorContains(t, err, "no image for platform windows/amd64")
+
+	t.Run("single manifest", func(t *testing.T) {
+		setIndex(map[string]any{"manifests": []any{map[string]any{"digest": arm}}})
+		fsys, err := ImageFS(layout, nil)
+		require.NoError(t, err)
+		assert.True(t, has(fsys, "usr/lib/c.so"))
+	})
+
+	t.Run("bad digest", func(t *testing.T) {
+		digest := blobJSON(map[string]any{"layers": []any{layer(base)}})
+		sum := sha256.Sum256(base)
+		layout["blobs/sha256/"+hex.EncodeToString(sum[:])].Data = append(base, 0)
+		setIndex(map[string]any{"manifests": []any{map[string]any{"digest": digest}}})
+
+		_, err := ImageFS(layout, nil)
+		assert.ErrorContains(t, err, "digest is sha256:")
+	})
+
+	t.Run("docker save", func(t *testing.T) {
+		saved := fstest.MapFS{
+			"abc/layer.tar": {Data: base},
+			"def/layer.tar": {Data: top},
+			"manifest.json": {Data: []byte(`[{"Config": "x.json", "RepoTags": ["x:latest"], "Layers": ["abc/layer.tar", "def/layer.tar"]}]`)},
+		}
+		fsys, err := ImageFS(saved, nil)
+		require.NoError(t, err)
+		assert.False(t, has(fsys, "etc/hosts"))
+		assert.True(t, has(fsys, "usr/lib/c.so"))
+	})
+}
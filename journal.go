@@ -0,0 +1,134 @@
+package ctree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+)
+
+// journalLine is one line of a journal: the header, first, then a
+// directory, with its leaves but not its children, for each subtree as
+// it is finished
+type journalLine struct {
+	Scan *ScanInfo `json:"scan,omitempty"`
+	Dir  *snapNode `json:"dir,omitempty"`
+}
+
+// startJournal writes the header of the Root's Journal
+func (r *Root) startJournal(scan *ScanInfo) {
+	r.journalErr = nil
+	r.writeJournal(journalLine{Scan: scan})
+}
+
+// journal writes dn, whose subtree is finished, to the Root's Journal
+func (r *Root) journal(dn *DNode) {
+	r.writeJournal(journalLine{Dir: snapEntry(dn)})
+}
+
+func (r *Root) writeJournal(line journalLine) {
+	if r.Journal == nil {
+		return
+	}
+
+	b, err := json.Marshal(&line)
+	if err == nil {
+		b = append(b, '\n')
+	}
+
+	r.journalMu.Lock()
+	defer r.journalMu.Unlock()
+
+	if r.journalErr != nil {
+		return
+	}
+	if err == nil {
+		_, err = r.Journal.Write(b)
+	}
+	if err != nil {
+		r.journalErr = fmt.Errorf("journal: %w", err)
+	}
+}
+
+// ReadJournal reads the tree written to a Root's Journal. If the walk
+// writing it was stopped, the tree has everything in the subtrees it
+// finished, and the directories above them, which have errors matching
+// ErrIncomplete, and whatever was written of the last line is ignored.
+// The journal may be compressed with gzip or zstd.
+func ReadJournal(r io.Reader) (*DNode, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(r)
+
+	var head journalLine
+	if err := dec.Decode(&head); err != nil || head.Scan == nil {
+		return nil, errors.New("journal has no header")
+	}
+
+	jr := &journalReader{dirs: map[string]*DNode{}}
+	jr.root = jr.dir(head.Scan.Root)
+	jr.root.scan = head.Scan
+
+	for {
+		var line journalLine
+		err := dec.Decode(&line)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line.Dir != nil {
+			jr.add(line.Dir)
+		}
+	}
+
+	return jr.root, nil
+}
+
+// journalReader assembles the directories of a journal into a tree
+type journalReader struct {
+	root *DNode
+	dirs map[string]*DNode // by clean path
+}
+
+// dir returns the directory at p, making it, and any missing parents, as
+// incomplete if it has not been read yet
+func (jr *journalReader) dir(p string) *DNode {
+	if dn, ok := jr.dirs[path.Clean(p)]; ok {
+		return dn
+	}
+
+	var fi os.FileInfo = &nodeInfo{name: path.Base(p), mode: fs.ModeDir}
+	dn := newNode(p, &fi).(*DNode)
+	dn.err = &NodeError{Path: p, Kind: ErrIncomplete}
+	jr.dirs[path.Clean(p)] = dn
+
+	if jr.root != nil && path.Dir(p) != p {
+		parent := jr.dir(path.Dir(p))
+		dn.parent = parent
+		parent.children = append(parent.children, dn)
+		sort.Slice(parent.children, func(i, j int) bool {
+			return parent.children[i].name < parent.children[j].name
+		})
+	}
+
+	return dn
+}
+
+// add fills in the directory described by sn
+func (jr *journalReader) add(sn *snapNode) {
+	read := fromSnap(sn, "").(*DNode)
+	dn := jr.dir(sn.Path)
+
+	dn.info, dn.err, dn.leaves = read.info, read.err, read.leaves
+	for _, leaf := range dn.leaves {
+		leaf.parent = dn
+	}
+}
@@ -0,0 +1,114 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestJournal(t *testing.T) {
+	fsys := fstest.MapFS{"home/big": {Data: make([]byte, LargeFileSize)}}
+	for name, f := range tfs {
+		fsys[name] = f
+	}
+
+	scan := func(t *testing.T) (*DNode, []byte) {
+		var buf bytes.Buffer
+		r := NewRoot("home")
+		r.FS = fsys
+		r.Hashes = []Hasher{XXH3}
+		r.Journal = &buf
+
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn, buf.Bytes()
+	}
+
+	t.Run("subtrees as they finish", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn, journal := scan(t)
+
+		var order []string
+		for _, line := range strings.Split(strings.TrimSpace(string(journal)), "\n")[1:] {
+			var jl journalLine
+			require.NoError(json.Unmarshal([]byte(line), &jl))
+			order = append(order, jl.Dir.Path)
+		}
+		assert.Len(order, 5)
+		assert.Less(indexOf(order, "home/ceswift/bin"), indexOf(order, "home/ceswift"))
+		assert.Equal("home", order[len(order)-1])
+
+		read, err := ReadJournal(bytes.NewReader(journal))
+		require.NoError(err)
+		assert.Empty(Diff(dn, read))
+		assert.Empty(read.Errors())
+		assert.Equal(dn.ScanInfo().Root, read.ScanInfo().Root)
+		assert.Equal(dn.Lookup("big").(*Leaf).Hash("xxh3"), read.Lookup("big").(*Leaf).Hash("xxh3"))
+		assert.NotNil(read.Lookup("big").(*Leaf).Hash("xxh3"))
+	})
+
+	t.Run("killed scans leave what they finished", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn, journal := scan(t)
+
+		// everything but the root, and half of the line before it
+		end := bytes.LastIndexByte(journal[:len(journal)-1], '\n') + 1
+		prev := bytes.LastIndexByte(journal[:end-1], '\n') + 1
+		cut := journal[:prev+(end-prev)/2]
+
+		read, err := ReadJournal(bytes.NewReader(cut))
+		require.NoError(err)
+		assert.ErrorIs(read.Error(), ErrIncomplete)
+		assert.Equal(dn.ScanInfo().Root, read.Path())
+
+		for _, n := range read.Flatten() {
+			if dir, ok := n.(*DNode); ok && dir.Error() != nil {
+				assert.ErrorIs(dir.Error(), ErrIncomplete)
+				continue
+			}
+			assert.NotNil(dn.Lookup(strings.TrimPrefix(n.Path(), "home/")), n.Path())
+		}
+		assert.Less(read.TotalLength(), dn.TotalLength())
+		assert.Greater(read.TotalLength(), 1)
+	})
+
+	t.Run("write errors", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = tfs
+		r.Journal = failWriter{}
+
+		dn, err := r.Run()
+		assert.NotNil(t, dn)
+		assert.ErrorContains(t, err, "disk full")
+	})
+
+	t.Run("no header", func(t *testing.T) {
+		_, err := ReadJournal(strings.NewReader(`{"dir":{"path":"x"}}`))
+		assert.Error(t, err)
+	})
+}
+
+func indexOf(ss []string, s string) int {
+	for i, x := range ss {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
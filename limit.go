@@ -0,0 +1,33 @@
+package ctree
+
+import "sync/atomic"
+
+// limits reports whether the Root has MaxNodes or MaxBytes set
+func (r *Root) limits() bool {
+	return r.MaxNodes > 0 || r.MaxBytes > 0
+}
+
+// admit counts a node of size bytes against MaxNodes and MaxBytes, and
+// reports whether it fits. Once one does not, nothing more is admitted.
+func (r *Root) admit(size int64) bool {
+	if !r.limits() {
+		return true
+	}
+	if r.limitReached() {
+		return false
+	}
+
+	nodes := atomic.AddInt64(&r.nodes, 1)
+	bytes := atomic.AddInt64(&r.bytes, size)
+	if (r.MaxNodes > 0 && nodes > int64(r.MaxNodes)) || (r.MaxBytes > 0 && bytes > r.MaxBytes) {
+		atomic.StoreInt32(&r.limited, 1)
+		return false
+	}
+	return true
+}
+
+// limitReached reports whether the walk has stopped at MaxNodes or
+// MaxBytes
+func (r *Root) limitReached() bool {
+	return atomic.LoadInt32(&r.limited) != 0
+}
@@ -0,0 +1,81 @@
+package ctree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	mfs := fstest.MapFS{}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			mfs[fmt.Sprintf("d%d/f%d", i, j)] = &fstest.MapFile{Data: make([]byte, 100)}
+		}
+	}
+	const total = 1 + 10 + 100
+
+	run := func(t *testing.T, set func(r *Root)) (*Root, *DNode) {
+		r := NewRoot(".")
+		r.FS = mfs
+		set(r)
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return r, dn
+	}
+
+	t.Run("under the limits", func(t *testing.T) {
+		_, dn := run(t, func(r *Root) {
+			r.MaxNodes = total
+			r.MaxBytes = 100 * 100
+		})
+		assert.Equal(t, total, dn.TotalLength())
+		assert.Empty(t, dn.Errors())
+		assert.False(t, dn.ScanInfo().LimitReached)
+	})
+
+	for name, set := range map[string]func(r *Root){
+		"nodes": func(r *Root) { r.MaxNodes = 30 },
+		"bytes": func(r *Root) { r.MaxBytes = 100 * 20 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			r, dn := run(t, set)
+			assert.True(dn.ScanInfo().LimitReached)
+			assert.Less(dn.TotalLength(), total)
+			if r.MaxNodes > 0 {
+				assert.LessOrEqual(dn.TotalLength(), r.MaxNodes)
+			}
+			if r.MaxBytes > 0 {
+				assert.LessOrEqual(dn.Usage().Bytes, r.MaxBytes)
+			}
+
+			errs := dn.Errors()
+			require.NotEmpty(errs)
+			for _, err := range errs {
+				assert.ErrorIs(err, ErrLimitReached)
+			}
+
+			var buf bytes.Buffer
+			require.NoError(WriteSnapshot(&buf, dn))
+			read, err := ReadSnapshot(&buf)
+			require.NoError(err)
+			assert.True(read.ScanInfo().LimitReached)
+			assert.ErrorIs(read.Errors()[0], ErrLimitReached)
+
+			// and the next Run starts afresh
+			set(r)
+			r.MaxNodes, r.MaxBytes = 0, 0
+			dn, err = r.Run()
+			require.NoError(err)
+			assert.Equal(total, dn.TotalLength())
+			assert.False(dn.ScanInfo().LimitReached)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package ctree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// sixMonths is how old a file can be for ls to show its time, rather
+// than its year
+const sixMonths = 182 * day
+
+// WriteLsLR writes dn to w in the format of "ls -lAR": for each directory,
+// a header, a total of blocks used, then a long listing of its entries
+// sorted by name, followed by the same for each subdirectory. Symbolic
+// link targets are not shown, since they are not recorded.
+func WriteLsLR(w io.Writer, dn *DNode) error {
+	return WriteLsLRFunc(w, dn, ByteOrder)
+}
+
+// WriteLsLRFunc is like WriteLsLR, but sorts the entries of each
+// directory by name in order, as ls does in the user's locale; see
+// CollationFromEnv
+func WriteLsLRFunc(w io.Writer, dn *DNode, order NameOrder) error {
+	bw := bufio.NewWriter(w)
+	lw := &lsWriter{
+		w:      bw,
+		now:    time.Now(),
+		users:  map[uint32]string{},
+		groups: map[uint32]string{},
+		order:  order,
+	}
+
+	lw.dir(dn, true)
+
+	return bw.Flush()
+}
+
+type lsWriter struct {
+	w      *bufio.Writer
+	now    time.Time
+	users  map[uint32]string
+	groups map[uint32]string
+	order  NameOrder
+}
+
+// lsLine is one entry of a long listing
+type lsLine struct {
+	mode, nlink, user, group, size, when, name string
+}
+
+func (lw *lsWriter) dir(dn *DNode, first bool) {
+	if !first {
+		lw.w.WriteString("\n")
+	}
+	fmt.Fprintf(lw.w, "%s:\n", dn.path)
+
+	entries := sortEntries(dn, lw.order)
+	lines := make([]lsLine, len(entries))
+	var widths [5]int
+	var blocks int64
+
+	for i, n := range entries {
+		fi := *n.Info()
+		si := statOf(fi)
+		blocks += blocksOf(fi, si)
+
+		nlink := si.nlink
+		if nlink == 0 {
+			nlink = 1
+		}
+		line := lsLine{
+			mode:  lsMode(fi.Mode()),
+			nlink: strconv.FormatUint(nlink, 10),
+			user:  "?",
+			group: "?",
+			size:  strconv.FormatInt(fi.Size(), 10),
+			when:  lw.when(fi.ModTime()),
+			name:  fi.Name(),
+		}
+		if si.hasOwner {
+			line.user = lw.user(si.uid)
+			line.group = lw.group(si.gid)
+		}
+		lines[i] = line
+
+		for j, field := range []string{line.mode, line.nlink, line.user, line.group, line.size} {
+			if len(field) > widths[j] {
+				widths[j] = len(field)
+			}
+		}
+	}
+
+	fmt.Fprintf(lw.w, "total %d\n", (blocks+1)/2)
+	for _, l := range lines {
+		fmt.Fprintf(lw.w, "%-*s %*s %-*s %-*s %*s %s %s\n",
+			widths[0], l.mode,
+			widths[1], l.nlink,
+			widths[2], l.user,
+			widths[3], l.group,
+			widths[4], l.size,
+			l.when, l.name)
+	}
+
+	for _, n := range entries {
+		if child, ok := n.(*DNode); ok {
+			lw.dir(child, false)
+		}
+	}
+}
+
+func (lw *lsWriter) when(t time.Time) string {
+	if age := lw.now.Sub(t); age < 0 || age > sixMonths {
+		return t.Format("Jan _2  2006")
+	}
+	return t.Format("Jan _2 15:04")
+}
+
+func (lw *lsWriter) user(uid uint32) string {
+	name, ok := lw.users[uid]
+	if !ok {
+		name = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		lw.users[uid] = name
+	}
+	return name
+}
+
+func (lw *lsWriter) group(gid uint32) string {
+	name, ok := lw.groups[gid]
+	if !ok {
+		name = strconv.FormatUint(uint64(gid), 10)
+		if g, err := user.LookupGroupId(name); err == nil {
+			name = g.Name
+		}
+		lw.groups[gid] = name
+	}
+	return name
+}
+
+// blocksOf returns the 512 byte blocks used by a file, estimating them
+// from its size when the platform doesn't say
+func blocksOf(fi fs.FileInfo, si statInfo) int64 {
+	if si.hasBlocks {
+		return si.blocks
+	}
+	return (fi.Size() + 511) / 512
+}
+
+// lsMode formats a mode the way ls does, which differs from
+// fs.FileMode.String in its type letters and special bits
+func lsMode(m fs.FileMode) string {
+	b := []byte("----------")
+
+	switch {
+	case m.IsDir():
+		b[0] = 'd'
+	case m&fs.ModeSymlink != 0:
+		b[0] = 'l'
+	case m&fs.ModeCharDevice != 0:
+		b[0] = 'c'
+	case m&fs.ModeDevice != 0:
+		b[0] = 'b'
+	case m&fs.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case m&fs.ModeSocket != 0:
+		b[0] = 's'
+	}
+
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if m&(1<<uint(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+
+	special := func(set bool, i int, lower, upper byte) {
+		if !set {
+			return
+		}
+		if b[i] == '-' {
+			b[i] = upper
+		} else {
+			b[i] = lower
+		}
+	}
+	special(m&fs.ModeSetuid != 0, 3, 's', 'S')
+	special(m&fs.ModeSetgid != 0, 6, 's', 'S')
+	special(m&fs.ModeSticky != 0, 9, 't', 'T')
+
+	return string(b)
+}
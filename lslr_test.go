@@ -0,0 +1,64 @@
+package ctree
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLsMode(t *testing.T) {
+	cases := map[fs.FileMode]string{
+		0644:                                     "-rw-r--r--",
+		fs.ModeDir | 0755:                        "drwxr-xr-x",
+		fs.ModeSymlink | 0777:                    "lrwxrwxrwx",
+		fs.ModeSetuid | 0755:                     "-rwsr-xr-x",
+		fs.ModeSetuid | 0644:                     "-rwSr--r--",
+		fs.ModeSetgid | 0750:                     "-rwxr-s---",
+		fs.ModeDir | fs.ModeSticky | 0777:        "drwxrwxrwt",
+		fs.ModeDir | fs.ModeSticky | 0770:        "drwxrwx--T",
+		fs.ModeNamedPipe | 0600:                  "prw-------",
+		fs.ModeDevice | fs.ModeCharDevice | 0666: "crw-rw-rw-",
+		fs.ModeDevice | 0660:                     "brw-rw----",
+		fs.ModeSocket | 0755:                     "srwxr-xr-x",
+	}
+
+	for mode, want := range cases {
+		assert.Equal(t, want, lsMode(mode), "mode %v", mode)
+	}
+}
+
+func TestWriteLsLR(t *testing.T) {
+	dn := scanCopy(t, nil)
+
+	var sb strings.Builder
+	require.NoError(t, WriteLsLR(&sb, dn))
+	out := sb.String()
+
+	sections := strings.Split(out, "\n\n")
+	assert.Len(t, sections, 5, "one section per directory")
+
+	first := strings.Split(sections[0], "\n")
+	assert.Equal(t, dn.Path()+":", first[0])
+	assert.True(t, strings.HasPrefix(first[1], "total "))
+	require.Len(t, first, 4)
+	assert.True(t, strings.HasPrefix(first[2], "d"))
+	assert.True(t, strings.HasSuffix(first[2], " ceswift"))
+	assert.True(t, strings.HasSuffix(first[3], " wsfitzpa"))
+
+	// the epoch is long ago, so the year is shown
+	assert.Contains(t, first[2], epoch.Format("2006"))
+
+	assert.Contains(t, out, dn.Path()+"/ceswift/bin:\n")
+	for _, s := range sections {
+		if strings.HasPrefix(s, dn.Path()+"/wsfitzpa/bin:") {
+			lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+			require.Len(t, lines, 3)
+			assert.True(t, strings.HasPrefix(lines[2], "-"))
+			assert.Contains(t, lines[2], " 18 ")
+			assert.True(t, strings.HasSuffix(lines[2], " zrun"))
+		}
+	}
+}
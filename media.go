@@ -0,0 +1,316 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	_ "image/gif"  // for image.DecodeConfig
+	_ "image/jpeg" // for image.DecodeConfig
+	_ "image/png"  // for image.DecodeConfig
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// MediaAnnotation names the annotation a MediaReader gives the files it
+// understands
+const MediaAnnotation = "media"
+
+// mediaHeaderSize is how much of the start of a file a MediaReader looks
+// at; only MP4 files, whose index may be at the end, are read further
+const mediaHeaderSize = 256 << 10
+
+// MediaInfo is what a MediaReader finds in the header of an image, audio,
+// or video file
+type MediaInfo struct {
+	// Format is "png", "jpeg", "gif", "wav", "flac", or "mp4"
+	Format string `json:"format"`
+	// Width and Height are the dimensions of an image, in pixels
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Taken is when a photo was taken, from its EXIF data. EXIF
+	// rarely records the zone, so it is as the camera's clock read,
+	// in UTC.
+	Taken *time.Time `json:"taken,omitempty"`
+	// Duration is the length of audio or video
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// MediaOf returns what a MediaReader found in l
+func MediaOf(l *Leaf) (MediaInfo, bool) {
+	var mi MediaInfo
+	ok, err := l.Annotation(MediaAnnotation, &mi)
+	return mi, ok && err == nil
+}
+
+// mediaExts are the extensions of the files a MediaReader reads
+var mediaExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".wav": true, ".flac": true,
+	".mp4": true, ".m4a": true, ".m4v": true, ".mov": true,
+}
+
+// MediaReader is a Processor which reads the headers of image, audio, and
+// video files, annotating them with their MediaInfo; see MediaOf. It
+// recognizes files by their contents, but only reads those with the
+// usual extensions. Files it cannot make sense of are left alone.
+type MediaReader struct{}
+
+var _ Processor = MediaReader{}
+
+// Wants reports whether l has the extension of a media file
+func (MediaReader) Wants(l *Leaf) bool {
+	return mediaExts[strings.ToLower(path.Ext(l.name))]
+}
+
+// Process reads the header of l
+func (MediaReader) Process(l *Leaf, contents io.Reader) error {
+	header := make([]byte, mediaHeaderSize)
+	n, err := io.ReadFull(contents, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	header = header[:n]
+
+	var mi MediaInfo
+	var ok bool
+	switch {
+	case bytes.HasPrefix(header, []byte("RIFF")) && len(header) >= 12 && string(header[8:12]) == "WAVE":
+		mi, ok = wavInfo(header[12:])
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		mi, ok = flacInfo(header[4:])
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		if rs, isSeeker := contents.(io.ReadSeeker); isSeeker {
+			mi, ok = mp4Info(rs, (*l.info).Size())
+		}
+	default:
+		mi, ok = imageInfo(header)
+	}
+
+	if ok {
+		l.Annotate(MediaAnnotation, mi)
+	}
+	return nil
+}
+
+// imageInfo finds the dimensions of a PNG, JPEG, or GIF, and when a JPEG
+// was taken
+func imageInfo(header []byte) (MediaInfo, bool) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return MediaInfo{}, false
+	}
+
+	mi := MediaInfo{Format: format, Width: cfg.Width, Height: cfg.Height}
+	if format == "jpeg" {
+		if t, ok := jpegTaken(header); ok {
+			mi.Taken = &t
+		}
+	}
+
+	return mi, true
+}
+
+// jpegTaken finds the time in the EXIF segment of a JPEG
+func jpegTaken(jpeg []byte) (time.Time, bool) {
+	for i := 2; i+4 <= len(jpeg) && jpeg[i] == 0xff; {
+		marker := jpeg[i+1]
+		size := int(binary.BigEndian.Uint16(jpeg[i+2:]))
+		if marker == 0xda || i+2+size > len(jpeg) {
+			break // the image data, or a truncated segment
+		}
+
+		seg := jpeg[i+4 : i+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifTime(seg[6:])
+		}
+		i += 2 + size
+	}
+
+	return time.Time{}, false
+}
+
+// The EXIF tags holding times
+const (
+	exifDateTime         = 0x0132
+	exifIFDPointer       = 0x8769
+	exifDateTimeOriginal = 0x9003
+)
+
+// exifTime finds when a photo was taken in its EXIF data, a TIFF file,
+// preferring the original time to that of the last change
+func exifTime(tiff []byte) (time.Time, bool) {
+	if len(tiff) < 8 {
+		return time.Time{}, false
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	// entries returns the value or offset of each tag of the IFD at off
+	entries := func(off uint32) map[uint16][2]uint32 {
+		tags := map[uint16][2]uint32{}
+		if int64(off)+2 > int64(len(tiff)) {
+			return tags
+		}
+		n := int(bo.Uint16(tiff[off:]))
+		for i := 0; i < n; i++ {
+			e := int(off) + 2 + 12*i
+			if e+12 > len(tiff) {
+				break
+			}
+			count, value := bo.Uint32(tiff[e+4:]), bo.Uint32(tiff[e+8:])
+			tags[bo.Uint16(tiff[e:])] = [2]uint32{count, value}
+		}
+		return tags
+	}
+	ascii := func(e [2]uint32) (time.Time, bool) {
+		count, off := e[0], e[1]
+		if count < 19 || int64(off)+19 > int64(len(tiff)) {
+			return time.Time{}, false
+		}
+		t, err := time.Parse("2006:01:02 15:04:05", string(tiff[off:off+19]))
+		return t, err == nil
+	}
+
+	ifd0 := entries(bo.Uint32(tiff[4:]))
+	if ptr, ok := ifd0[exifIFDPointer]; ok {
+		if e, ok := entries(ptr[1])[exifDateTimeOriginal]; ok {
+			if t, ok := ascii(e); ok {
+				return t, true
+			}
+		}
+	}
+	if e, ok := ifd0[exifDateTime]; ok {
+		return ascii(e)
+	}
+
+	return time.Time{}, false
+}
+
+// wavInfo finds the duration of a WAV file from its chunks
+func wavInfo(chunks []byte) (MediaInfo, bool) {
+	var byteRate, dataSize uint32
+	for len(chunks) >= 8 {
+		id, size := string(chunks[:4]), binary.LittleEndian.Uint32(chunks[4:])
+		body := chunks[8:]
+		switch id {
+		case "fmt ":
+			if len(body) >= 12 {
+				byteRate = binary.LittleEndian.Uint32(body[8:])
+			}
+		case "data":
+			dataSize = size
+		}
+		if id == "data" || int64(size)+int64(size&1) > int64(len(body)) {
+			break
+		}
+		chunks = body[size+size&1:]
+	}
+
+	if byteRate == 0 || dataSize == 0 {
+		return MediaInfo{}, false
+	}
+	return MediaInfo{
+		Format:   "wav",
+		Duration: time.Duration(float64(dataSize) / float64(byteRate) * float64(time.Second)),
+	}, true
+}
+
+// flacInfo finds the duration of a FLAC file from its STREAMINFO block
+func flacInfo(blocks []byte) (MediaInfo, bool) {
+	if len(blocks) < 4+18 || blocks[0]&0x7f != 0 {
+		return MediaInfo{}, false
+	}
+
+	v := binary.BigEndian.Uint64(blocks[4+10:])
+	rate, samples := v>>44, v&(1<<36-1)
+	if rate == 0 {
+		return MediaInfo{}, false
+	}
+	return MediaInfo{
+		Format:   "flac",
+		Duration: time.Duration(samples * uint64(time.Second) / rate),
+	}, true
+}
+
+// mp4Info finds the duration of an MP4 or QuickTime file from the movie
+// header, which may be anywhere in the file
+func mp4Info(rs io.ReadSeeker, size int64) (MediaInfo, bool) {
+	moov, moovEnd, ok := findBox(rs, 0, size, "moov")
+	if !ok {
+		return MediaInfo{}, false
+	}
+	mvhd, mvhdEnd, ok := findBox(rs, moov, moovEnd, "mvhd")
+	if !ok || mvhdEnd-mvhd > 1<<10 {
+		return MediaInfo{}, false
+	}
+
+	body := make([]byte, mvhdEnd-mvhd)
+	if _, err := rs.Seek(mvhd, io.SeekStart); err != nil {
+		return MediaInfo{}, false
+	}
+	if _, err := io.ReadFull(rs, body); err != nil {
+		return MediaInfo{}, false
+	}
+
+	var scale, duration uint64
+	switch {
+	case len(body) >= 20 && body[0] == 0:
+		scale = uint64(binary.BigEndian.Uint32(body[12:]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:]))
+	case len(body) >= 32 && body[0] == 1:
+		scale = uint64(binary.BigEndian.Uint32(body[20:]))
+		duration = binary.BigEndian.Uint64(body[24:])
+	}
+	if scale == 0 {
+		return MediaInfo{}, false
+	}
+
+	return MediaInfo{
+		Format:   "mp4",
+		Duration: time.Duration(float64(duration) / float64(scale) * float64(time.Second)),
+	}, true
+}
+
+// findBox finds the box of type typ between start and end, returning
+// where its contents start and end
+func findBox(rs io.ReadSeeker, start, end int64, typ string) (int64, int64, bool) {
+	var head [16]byte
+	for off := start; off+8 <= end; {
+		if _, err := rs.Seek(off, io.SeekStart); err != nil {
+			return 0, 0, false
+		}
+		if _, err := io.ReadFull(rs, head[:8]); err != nil {
+			return 0, 0, false
+		}
+
+		size, hlen := int64(binary.BigEndian.Uint32(head[:])), int64(8)
+		switch size {
+		case 0: // to the end
+			size = end - off
+		case 1: // a 64 bit size follows
+			if _, err := io.ReadFull(rs, head[8:16]); err != nil {
+				return 0, 0, false
+			}
+			size, hlen = int64(binary.BigEndian.Uint64(head[8:])), 16
+		}
+		if size < hlen || off+size > end {
+			return 0, 0, false
+		}
+
+		if string(head[4:8]) == typ {
+			return off + hlen, off + size, true
+		}
+		off += size
+	}
+
+	return 0, 0, false
+}
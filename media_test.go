@@ -0,0 +1,141 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exifJPEG is a JPEG of the given size, with an EXIF segment saying when
+// it was taken
+func exifJPEG(t *testing.T, w, h int, taken string) []byte {
+	var img bytes.Buffer
+	require.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, w, h)), nil))
+
+	// a little endian TIFF: IFD0 points to the EXIF IFD, which has
+	// DateTimeOriginal, which points to the time
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, exifIFDPointer)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 26)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, exifDateTimeOriginal)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint32(tiff, 20)
+	tiff = le.AppendUint32(tiff, 44)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, taken+"\x00"...)
+
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(len(seg)+2))
+	app1 = append(app1, seg...)
+
+	out := append([]byte{}, img.Bytes()[:2]...)
+	out = append(out, app1...)
+	return append(out, img.Bytes()[2:]...)
+}
+
+func TestMediaReader(t *testing.T) {
+	var pngImg, gifImg bytes.Buffer
+	require.NoError(t, png.Encode(&pngImg, image.NewGray(image.Rect(0, 0, 64, 48))))
+	require.NoError(t, gif.Encode(&gifImg, image.NewPaletted(image.Rect(0, 0, 7, 3), color.Palette{color.Black}), nil))
+
+	// two seconds of 8 kHz, 16 bit mono
+	le := binary.LittleEndian
+	wav := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	wav = le.AppendUint32(wav, 16)
+	wav = append(wav, 1, 0, 1, 0)
+	wav = le.AppendUint32(wav, 8000)
+	wav = le.AppendUint32(wav, 16000)
+	wav = append(wav, 2, 0, 16, 0)
+	wav = append(wav, "data"...)
+	wav = le.AppendUint32(wav, 32000)
+	wav = append(wav, make([]byte, 32000)...)
+
+	// 44.1 kHz, 3 minutes
+	be := binary.BigEndian
+	flac := []byte("fLaC\x80\x00\x00\x22")
+	flac = append(flac, make([]byte, 10)...)
+	flac = be.AppendUint64(flac, 44100<<44|1<<41|15<<36|44100*180)
+	flac = append(flac, make([]byte, 16)...)
+
+	// the movie header after the media, lasting 90 seconds at 600 a second
+	box := func(typ string, body []byte) []byte {
+		return append(be.AppendUint32(nil, uint32(8+len(body))), append([]byte(typ), body...)...)
+	}
+	mvhd := make([]byte, 100)
+	be.PutUint32(mvhd[12:], 600)
+	be.PutUint32(mvhd[16:], 600*90)
+	mp4 := box("ftyp", []byte("isom\x00\x00\x02\x00"))
+	mp4 = append(mp4, box("mdat", make([]byte, mediaHeaderSize))...)
+	mp4 = append(mp4, box("moov", box("mvhd", mvhd))...)
+
+	mfs := fstest.MapFS{
+		"photo.JPG":     {Data: exifJPEG(t, 40, 30, "2021:06:01 12:34:56")},
+		"shot.png":      {Data: pngImg.Bytes()},
+		"anim.gif":      {Data: gifImg.Bytes()},
+		"tone.wav":      {Data: wav},
+		"song.flac":     {Data: flac},
+		"film.mp4":      {Data: mp4},
+		"broken.jpg":    {Data: []byte("not really")},
+		"notes.txt":     {Data: pngImg.Bytes()},
+		"empty.mov":     {},
+		"truncated.m4a": {Data: mp4[:1000]},
+	}
+
+	r := NewRoot(".")
+	r.FS = mfs
+	r.Processors = []Processor{MediaReader{}}
+	dn, err := r.Run()
+	require.NoError(t, err)
+	assert.Empty(t, dn.Errors())
+
+	taken := time.Date(2021, 6, 1, 12, 34, 56, 0, time.UTC)
+	want := map[string]MediaInfo{
+		"photo.JPG": {Format: "jpeg", Width: 40, Height: 30, Taken: &taken},
+		"shot.png":  {Format: "png", Width: 64, Height: 48},
+		"anim.gif":  {Format: "gif", Width: 7, Height: 3},
+		"tone.wav":  {Format: "wav", Duration: 2 * time.Second},
+		"song.flac": {Format: "flac", Duration: 3 * time.Minute},
+		"film.mp4":  {Format: "mp4", Duration: 90 * time.Second},
+	}
+
+	for name := range mfs {
+		mi, ok := MediaOf(dn.Lookup(name).(*Leaf))
+		if w, found := want[name]; found {
+			assert.True(t, ok, name)
+			assert.Equal(t, w, mi, name)
+		} else {
+			assert.False(t, ok, name)
+		}
+	}
+
+	t.Run("snapshots", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, dn))
+		require.NoError(t, ValidateSnapshot(bytes.NewReader(buf.Bytes())))
+		read, err := ReadSnapshot(&buf)
+		require.NoError(t, err)
+
+		leaf := read.Lookup("photo.JPG").(*Leaf)
+		assert.Equal(t, []string{MediaAnnotation}, leaf.Annotations())
+		mi, ok := MediaOf(leaf)
+		assert.True(t, ok)
+		assert.Equal(t, want["photo.JPG"], mi)
+	})
+}
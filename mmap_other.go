@@ -0,0 +1,13 @@
+//go:build !unix
+
+package ctree
+
+import (
+	"io"
+	"os"
+)
+
+// mmapCopy always falls back to reading where files can't be mapped
+func mmapCopy(f *os.File, size int64, w io.Writer, chunk int) (bool, error) {
+	return false, nil
+}
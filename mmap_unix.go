@@ -0,0 +1,49 @@
+//go:build unix
+
+package ctree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapCopy maps f into memory and writes it to w in chunks of the given
+// size. It returns false, to fall back to reading, if the file can't be
+// mapped. A file truncated while mapped is reported as an error, rather
+// than crashing the program.
+func mmapCopy(f *os.File, size int64, w io.Writer, chunk int) (done bool, err error) {
+	if size <= 0 || int64(int(size)) != size {
+		return false, nil
+	}
+
+	data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return false, nil
+	}
+	defer unix.Munmap(data)
+	unix.Madvise(data, unix.MADV_SEQUENTIAL)
+
+	defer func() {
+		if p := recover(); p != nil {
+			done, err = true, fmt.Errorf("%s: fault reading mapped file: %v", f.Name(), p)
+		}
+	}()
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	for len(data) > 0 {
+		n := chunk
+		if n <= 0 || n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return true, err
+		}
+		data = data[n:]
+	}
+
+	return true, nil
+}
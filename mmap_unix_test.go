@@ -0,0 +1,48 @@
+//go:build unix
+
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// truncater truncates a file after the first write to it, and reads
+// every byte written
+type truncater struct {
+	f      *os.File
+	writes int
+	sum    byte
+}
+
+func (tr *truncater) Write(b []byte) (int, error) {
+	if tr.writes == 0 {
+		if err := tr.f.Truncate(0); err != nil {
+			return 0, err
+		}
+	}
+	tr.writes++
+	for _, c := range b {
+		tr.sum += c
+	}
+	return len(b), nil
+}
+
+func TestMmapTruncated(t *testing.T) {
+	p := path.Join(t.TempDir(), "f")
+	require.NoError(t, os.WriteFile(p, make([]byte, 1<<20), 0666))
+
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	tr := &truncater{f: f}
+	done, err := mmapCopy(f, 1<<20, tr, 64<<10)
+	assert.True(t, done)
+	assert.ErrorContains(t, err, "fault")
+	assert.Equal(t, 1, tr.writes)
+}
@@ -0,0 +1,155 @@
+package ctree
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"time"
+)
+
+// DefaultMonitorInterval is how often a Monitor rescans by default
+const DefaultMonitorInterval = time.Hour
+
+// ErrNoBaseline is returned by a Monitor asked to check or save before it
+// has a baseline
+var ErrNoBaseline = errors.New("monitor has no baseline")
+
+// ErrNoHashes is returned by NewMonitor for a Root without Hashes
+var ErrNoHashes = errors.New("monitor root has no hashes")
+
+// Alert reports changes that a Monitor found
+type Alert struct {
+	Time    time.Time
+	Changes []Change
+}
+
+// Monitor watches a tree for changes against a signed baseline snapshot,
+// in the style of Tripwire
+type Monitor struct {
+	Root     *Root
+	Key      ed25519.PrivateKey
+	Compare  Comparer
+	Interval time.Duration
+
+	baseline *DNode
+}
+
+// NewMonitor creates a Monitor of root, which signs its baselines with key.
+// root must compute at least one of Hashes, such as SHA256, so that a file
+// whose contents are changed, but whose size and modification time are put
+// back, is still caught; see MonitorComparer. Without them, NewMonitor
+// returns ErrNoHashes.
+func NewMonitor(root *Root, key ed25519.PrivateKey) (*Monitor, error) {
+	if len(root.Hashes) == 0 {
+		return nil, ErrNoHashes
+	}
+	return &Monitor{
+		Root:     root,
+		Key:      key,
+		Compare:  MonitorComparer(root),
+		Interval: DefaultMonitorInterval,
+	}, nil
+}
+
+// MonitorComparer returns the Comparer a Monitor of root uses by default:
+// DefaultComparer, and SameHash for each of root's Hashes
+func MonitorComparer(root *Root) Comparer {
+	cmps := []Comparer{DefaultComparer}
+	for _, h := range root.Hashes {
+		cmps = append(cmps, SameHash(h.Name))
+	}
+	return AllOf(cmps...)
+}
+
+// Baseline scans the tree, and uses the result as the baseline that
+// future checks are compared against
+func (m *Monitor) Baseline() error {
+	dn, err := m.Root.Run()
+	if err != nil {
+		return err
+	}
+
+	m.baseline = dn
+	return nil
+}
+
+// SaveBaseline writes the baseline to w as a snapshot, returning its
+// signature
+func (m *Monitor) SaveBaseline(w io.Writer) ([]byte, error) {
+	if m.baseline == nil {
+		return nil, ErrNoBaseline
+	}
+
+	return WriteSignedSnapshot(w, m.baseline, m.Key)
+}
+
+// LoadBaseline reads a baseline written by SaveBaseline, returning
+// ErrBadSignature if it does not match sig
+func (m *Monitor) LoadBaseline(r io.Reader, sig []byte) error {
+	snap, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	pub := m.Key.Public().(ed25519.PublicKey)
+	if err := VerifySignature(pub, bytes.NewReader(snap), sig); err != nil {
+		return err
+	}
+
+	dn, err := ReadSnapshot(bytes.NewReader(snap))
+	if err != nil {
+		return err
+	}
+
+	m.baseline = dn
+	return nil
+}
+
+// Check rescans the tree, returning how it differs from the baseline
+func (m *Monitor) Check() ([]Change, error) {
+	if m.baseline == nil {
+		return nil, ErrNoBaseline
+	}
+
+	dn, err := m.Root.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	same := m.Compare
+	if same == nil {
+		same = MonitorComparer(m.Root)
+	}
+
+	return DiffFunc(m.baseline, dn, same), nil
+}
+
+// Watch calls Check every Interval until ctx is done, calling alert
+// whenever changes are found. It returns the first error from Check, or
+// the context's error.
+func (m *Monitor) Watch(ctx context.Context, alert func(Alert)) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			changes, err := m.Check()
+			if err != nil {
+				return err
+			}
+			if len(changes) > 0 {
+				alert(Alert{Time: now, Changes: changes})
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ctree
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	hashed := func(t *testing.T, where string) *Monitor {
+		r := NewRoot(where)
+		r.Hashes = []Hasher{SHA256}
+		m, err := NewMonitor(r, key)
+		require.NoError(t, err)
+		return m
+	}
+
+	newMonitor := func(t *testing.T) (*Monitor, string) {
+		where := t.TempDir()
+		ttree.build(t, where)
+		m := hashed(t, where)
+		require.NoError(t, m.Baseline())
+		return m, where
+	}
+
+	t.Run("no hashes", func(t *testing.T) {
+		r := NewRoot(t.TempDir())
+		m, err := NewMonitor(r, key)
+		assert.ErrorIs(t, err, ErrNoHashes)
+		assert.Nil(t, m)
+		assert.Empty(t, r.Hashes)
+	})
+
+	t.Run("no baseline", func(t *testing.T) {
+		assert := assert.New(t)
+
+		m := hashed(t, t.TempDir())
+		_, err := m.Check()
+		assert.ErrorIs(err, ErrNoBaseline)
+		_, err = m.SaveBaseline(&bytes.Buffer{})
+		assert.ErrorIs(err, ErrNoBaseline)
+	})
+
+	t.Run("changes are found", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		m, where := newMonitor(t)
+		changes, err := m.Check()
+		require.NoError(err)
+		assert.Empty(changes)
+
+		writeFile(t, path.Join(where, "home", "ceswift", "rootkit"), "!")
+		changes, err = m.Check()
+		require.NoError(err)
+		require.Len(changes, 1)
+		assert.Equal("home/ceswift/rootkit", changes[0].Path)
+		assert.Equal(Added, changes[0].Kind)
+	})
+
+	t.Run("contents are hashed", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		m, where := newMonitor(t)
+
+		// the same size, and the modification time put back, as by
+		// touch -r
+		worms := path.Join(where, "home", "ceswift", "bin", "worms")
+		fi, err := os.Stat(worms)
+		require.NoError(err)
+		writeFile(t, worms, strings.Repeat("!", int(fi.Size())))
+		require.NoError(os.Chtimes(worms, fi.ModTime(), fi.ModTime()))
+
+		changes, err := m.Check()
+		require.NoError(err)
+		require.Len(changes, 1)
+		assert.Equal("home/ceswift/bin/worms", changes[0].Path)
+		assert.Equal(Modified, changes[0].Kind)
+	})
+
+	t.Run("saved baselines are verified", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		m, where := newMonitor(t)
+		var buf bytes.Buffer
+		sig, err := m.SaveBaseline(&buf)
+		require.NoError(err)
+		snap := buf.Bytes()
+
+		loaded := hashed(t, where)
+		require.NoError(loaded.LoadBaseline(bytes.NewReader(snap), sig))
+		changes, err := loaded.Check()
+		require.NoError(err)
+		assert.Empty(changes)
+
+		tampered := bytes.Replace(snap, []byte(`"size":14`), []byte(`"size":15`), 1)
+		require.NotEqual(snap, tampered)
+		err = loaded.LoadBaseline(bytes.NewReader(tampered), sig)
+		assert.ErrorIs(err, ErrBadSignature)
+	})
+
+	t.Run("watch raises alerts", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		m, where := newMonitor(t)
+		m.Interval = time.Millisecond
+		writeFile(t, path.Join(where, "home", "ceswift", "rootkit"), "!")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var alerts []Alert
+		err := m.Watch(ctx, func(a Alert) {
+			alerts = append(alerts, a)
+			cancel()
+		})
+		assert.ErrorIs(err, context.Canceled)
+		require.Len(alerts, 1)
+		assert.Len(alerts[0].Changes, 1)
+	})
+}
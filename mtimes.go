@@ -0,0 +1,198 @@
+package ctree
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// mtimes is the range of modification times beneath a directory, in
+// nanoseconds since the Unix epoch, with counts of what is beneath it. A
+// walk fills it in as each directory's subtree is finished; for other
+// trees it is filled in when first asked for.
+type mtimes struct {
+	newest, oldest int64
+	found          int32 // whether anything is beneath
+	done           int32 // whether the mtimes are complete
+
+	leaves, dirs int64
+	depth        int32 // of the deepest node beneath
+
+	// pending counts, during a walk, the directory itself while its
+	// worker has it, each child whose subtree is not finished, and each
+	// leaf still waiting to be hashed
+	pending int32
+}
+
+// NewestMTime returns the latest modification time of anything beneath
+// dn, files and directories alike, or false if dn is empty. It is
+// gathered during the walk, so asking is cheap.
+func (dn *DNode) NewestMTime() (time.Time, bool) {
+	mt := dn.mtimes()
+	if atomic.LoadInt32(&mt.found) == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(&mt.newest)), true
+}
+
+// OldestMTime returns the earliest modification time of anything beneath
+// dn, as NewestMTime does the latest
+func (dn *DNode) OldestMTime() (time.Time, bool) {
+	mt := dn.mtimes()
+	if atomic.LoadInt32(&mt.found) == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(&mt.oldest)), true
+}
+
+// NumLeaves returns how many leaves are beneath dn, at any depth. It is
+// gathered during the walk, so asking is cheap.
+func (dn *DNode) NumLeaves() int64 {
+	return atomic.LoadInt64(&dn.mtimes().leaves)
+}
+
+// NumDirs returns how many directories are beneath dn, at any depth, not
+// counting dn itself
+func (dn *DNode) NumDirs() int64 {
+	return atomic.LoadInt64(&dn.mtimes().dirs)
+}
+
+// MaxDepth returns how far beneath dn its deepest node is: 1 if nothing is
+// in its subdirectories, and 0 if it is empty
+func (dn *DNode) MaxDepth() int {
+	return int(atomic.LoadInt32(&dn.mtimes().depth))
+}
+
+// mtimes returns the directory's complete mtimes, gathering them first if
+// the tree did not come from a walk, or has changed since. They are
+// gathered apart and then published, so that goroutines asking at once
+// each publish the same complete values, rather than adding to each
+// other's.
+func (dn *DNode) mtimes() *mtimes {
+	mt := &dn.times
+	if atomic.LoadInt32(&mt.done) != 0 {
+		return mt
+	}
+
+	var gathered mtimes
+	gathered.reset()
+	gathered.count(len(dn.leaves), len(dn.children))
+	for _, leaf := range dn.leaves {
+		gathered.add((*leaf.info).ModTime())
+	}
+	for _, child := range dn.children {
+		gathered.add((*child.info).ModTime())
+		gathered.merge(child.mtimes())
+	}
+	mt.publish(&gathered)
+
+	return mt
+}
+
+// publish sets the mtimes to those gathered, and marks them complete
+func (mt *mtimes) publish(gathered *mtimes) {
+	atomic.StoreInt64(&mt.newest, gathered.newest)
+	atomic.StoreInt64(&mt.oldest, gathered.oldest)
+	atomic.StoreInt32(&mt.found, gathered.found)
+	atomic.StoreInt64(&mt.leaves, gathered.leaves)
+	atomic.StoreInt64(&mt.dirs, gathered.dirs)
+	atomic.StoreInt32(&mt.depth, gathered.depth)
+	atomic.StoreInt32(&mt.done, 1)
+}
+
+func (mt *mtimes) reset() {
+	atomic.StoreInt32(&mt.found, 0)
+	atomic.StoreInt64(&mt.newest, math.MinInt64)
+	atomic.StoreInt64(&mt.oldest, math.MaxInt64)
+	atomic.StoreInt64(&mt.leaves, 0)
+	atomic.StoreInt64(&mt.dirs, 0)
+	atomic.StoreInt32(&mt.depth, 0)
+}
+
+// count counts the leaves and directories directly beneath
+func (mt *mtimes) count(leaves, dirs int) {
+	atomic.AddInt64(&mt.leaves, int64(leaves))
+	atomic.AddInt64(&mt.dirs, int64(dirs))
+	if leaves+dirs > 0 {
+		mt.deepen(1)
+	}
+}
+
+// add widens the range to include t
+func (mt *mtimes) add(t time.Time) {
+	mt.widen(t.UnixNano(), t.UnixNano())
+}
+
+// merge widens the range to include that of other, which is complete,
+// and counts what is beneath it, one level further down
+func (mt *mtimes) merge(other *mtimes) {
+	if atomic.LoadInt32(&other.found) != 0 {
+		mt.widen(atomic.LoadInt64(&other.newest), atomic.LoadInt64(&other.oldest))
+	}
+	atomic.AddInt64(&mt.leaves, atomic.LoadInt64(&other.leaves))
+	atomic.AddInt64(&mt.dirs, atomic.LoadInt64(&other.dirs))
+	if depth := atomic.LoadInt32(&other.depth); depth > 0 {
+		mt.deepen(depth + 1)
+	}
+}
+
+// deepen makes the depth at least depth
+func (mt *mtimes) deepen(depth int32) {
+	for {
+		cur := atomic.LoadInt32(&mt.depth)
+		if depth <= cur || atomic.CompareAndSwapInt32(&mt.depth, cur, depth) {
+			break
+		}
+	}
+}
+
+// widen widens the range, which has been reset, to include newest and
+// oldest
+func (mt *mtimes) widen(newest, oldest int64) {
+	atomic.StoreInt32(&mt.found, 1)
+	for {
+		cur := atomic.LoadInt64(&mt.newest)
+		if newest <= cur || atomic.CompareAndSwapInt64(&mt.newest, cur, newest) {
+			break
+		}
+	}
+	for {
+		cur := atomic.LoadInt64(&mt.oldest)
+		if oldest >= cur || atomic.CompareAndSwapInt64(&mt.oldest, cur, oldest) {
+			break
+		}
+	}
+}
+
+// invalidate has the mtimes of dn and the directories above it gathered
+// again, after the tree has changed
+func (dn *DNode) invalidate() {
+	for ; dn != nil; dn = dn.parent {
+		atomic.StoreInt32(&dn.times.done, 0)
+	}
+}
+
+// begin counts the directory as pending while a worker has it
+func (dn *DNode) begin() {
+	atomic.StoreInt32(&dn.times.done, 0)
+	dn.times.reset()
+	atomic.AddInt32(&dn.times.pending, 1)
+}
+
+// finish counts one pending part of the directory done. Once all are, its
+// subtree is complete: its mtimes are merged into its parent, for which
+// it is one pending part, and it is written to the Root's Journal and
+// given to its Sinks.
+func (dn *DNode) finish(r *Root) {
+	for ; dn != nil; dn = dn.parent {
+		if atomic.AddInt32(&dn.times.pending, -1) > 0 {
+			return
+		}
+		atomic.StoreInt32(&dn.times.done, 1)
+		if dn.parent != nil {
+			dn.parent.times.merge(&dn.times)
+		}
+		r.journal(dn)
+		r.sink(dn)
+	}
+}
@@ -0,0 +1,177 @@
+package ctree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMTimes(t *testing.T) {
+	newest := epoch.Add(48 * time.Hour)
+	oldest := epoch.Add(-time.Hour)
+	dn := scanCopy(t, func(where string) {
+		require.NoError(t, os.Chtimes(path.Join(where, "ceswift/bin/worms"), newest, newest))
+		require.NoError(t, os.Chtimes(path.Join(where, "wsfitzpa/.cshrc"), oldest, oldest))
+		require.NoError(t, os.Mkdir(path.Join(where, "empty"), 0777))
+		require.NoError(t, os.Chtimes(path.Join(where, "empty"), epoch, epoch))
+	})
+
+	check := func(t *testing.T, dn *DNode) {
+		assert := assert.New(t)
+
+		for _, c := range []struct {
+			dir            string
+			newest, oldest time.Time
+		}{
+			{"", newest, oldest},
+			{"ceswift", newest, epoch},
+			{"ceswift/bin", newest, newest},
+			{"wsfitzpa", epoch, oldest},
+		} {
+			sub := dn
+			if c.dir != "" {
+				sub = dn.Lookup(c.dir).(*DNode)
+			}
+			n, ok := sub.NewestMTime()
+			assert.True(ok, c.dir)
+			assert.True(c.newest.Equal(n), "%s: newest %v", c.dir, n)
+			o, ok := sub.OldestMTime()
+			assert.True(ok, c.dir)
+			assert.True(c.oldest.Equal(o), "%s: oldest %v", c.dir, o)
+		}
+
+		_, ok := dn.Lookup("empty").(*DNode).NewestMTime()
+		assert.False(ok)
+		_, ok = dn.Lookup("empty").(*DNode).OldestMTime()
+		assert.False(ok)
+	}
+
+	t.Run("gathered by the walk", func(t *testing.T) {
+		for _, sub := range []string{"ceswift", "ceswift/bin", "wsfitzpa/bin"} {
+			assert.NotZero(t, dn.Lookup(sub).(*DNode).times.done, sub)
+		}
+		check(t, dn)
+	})
+
+	t.Run("gathered for snapshots", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(t, err)
+		check(t, loaded)
+	})
+
+	t.Run("gathered again after removal", func(t *testing.T) {
+		require.NoError(t, RemoveTree(dn.Lookup("ceswift/bin/worms"), nil))
+		n, ok := dn.NewestMTime()
+		assert.True(t, ok)
+		assert.False(t, newest.Equal(n))
+		_, ok = dn.Lookup("ceswift/bin").(*DNode).NewestMTime()
+		assert.False(t, ok)
+	})
+}
+
+func TestCounts(t *testing.T) {
+	dn := scanCopy(t, func(where string) {
+		require.NoError(t, os.MkdirAll(path.Join(where, "a/b/c"), 0777))
+	})
+
+	check := func(t *testing.T, dn *DNode) {
+		assert := assert.New(t)
+
+		for _, c := range []struct {
+			dir          string
+			leaves, dirs int64
+			depth        int
+		}{
+			{"", 4, 7, 3},
+			{"ceswift", 2, 1, 2},
+			{"ceswift/bin", 1, 0, 1},
+			{"a", 0, 2, 2},
+			{"a/b/c", 0, 0, 0},
+		} {
+			sub := dn
+			if c.dir != "" {
+				sub = dn.Lookup(c.dir).(*DNode)
+			}
+			assert.Equal(c.leaves, sub.NumLeaves(), c.dir)
+			assert.Equal(c.dirs, sub.NumDirs(), c.dir)
+			assert.Equal(c.depth, sub.MaxDepth(), c.dir)
+		}
+
+		var leaves, dirs int64
+		dn.walk(func(_ string, n Node) bool {
+			if _, ok := n.(*DNode); ok {
+				dirs++
+			} else {
+				leaves++
+			}
+			return true
+		})
+		assert.Equal(leaves, dn.NumLeaves())
+		assert.Equal(dirs, dn.NumDirs())
+	}
+
+	t.Run("gathered by the walk", func(t *testing.T) {
+		check(t, dn)
+	})
+
+	t.Run("gathered for snapshots", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(t, err)
+		check(t, loaded)
+	})
+
+	t.Run("gathered at once", func(t *testing.T) {
+		mfs := fstest.MapFS{}
+		for i := 0; i < 100; i++ {
+			for j := 0; j < 10; j++ {
+				mfs[fmt.Sprintf("d%d/e%d/f", i, j)] = &fstest.MapFile{}
+			}
+		}
+		r := NewRoot(".")
+		r.FS = mfs
+		scanned, err := r.Run()
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, scanned))
+		snap := buf.Bytes()
+
+		for i := 0; i < 50; i++ {
+			loaded, err := ReadSnapshot(bytes.NewReader(snap))
+			require.NoError(t, err)
+
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for j := 0; j < 8; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					loaded.NumLeaves()
+				}()
+			}
+			close(start)
+			wg.Wait()
+			require.Equal(t, int64(1000), loaded.NumLeaves())
+			require.Equal(t, int64(1100), loaded.NumDirs())
+		}
+	})
+
+	t.Run("gathered again after removal", func(t *testing.T) {
+		require.NoError(t, RemoveTree(dn.Lookup("a/b"), nil))
+		assert.Equal(t, int64(5), dn.NumDirs())
+		assert.Equal(t, 0, dn.Lookup("a").(*DNode).MaxDepth())
+		assert.Equal(t, 3, dn.MaxDepth(), "ceswift/bin/worms")
+	})
+}
@@ -0,0 +1,18 @@
+package ctree
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openNoAtime opens the file at p for reading without changing its access
+// time. Only the owner of a file, or a process with CAP_FOWNER, may.
+func openNoAtime(p string) (*os.File, error) {
+	f, err := os.OpenFile(p, os.O_RDONLY|unix.O_NOATIME, 0)
+	if errors.Is(err, unix.EPERM) {
+		return nil, &NodeError{Path: p, Kind: ErrAccessTime, Err: err}
+	}
+	return f, err
+}
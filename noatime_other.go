@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ctree
+
+import "os"
+
+// openNoAtime opens the file at p for reading, as usual, where there is no
+// way to keep its access time
+func openNoAtime(p string) (*os.File, error) {
+	return os.Open(p)
+}
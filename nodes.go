@@ -1,8 +1,12 @@
 package ctree
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"sync/atomic"
 )
 
@@ -15,6 +19,13 @@ type DNode struct {
 	children []*DNode
 	leaves   []*Leaf
 	err      error
+	scan     *ScanInfo
+	depth    int // beneath the root of the walk
+	times    mtimes
+	tags     []string
+
+	budget chan struct{} // limits the workers in this subtree
+	slot   bool          // whether this node holds a place in budget
 }
 
 var _ Node = &DNode{}
@@ -34,6 +45,33 @@ func (dn *DNode) Error() error {
 	return dn.err
 }
 
+// addError records err on dn, joined to any error it already has
+func (dn *DNode) addError(err error) {
+	if dn.err == nil {
+		dn.err = err
+		return
+	}
+	dn.err = errors.Join(dn.err, err)
+}
+
+// Parent returns the directory holding dn, or nil at the root
+func (dn *DNode) Parent() *DNode {
+	return dn.parent
+}
+
+// Entries returns the children and leaves of dn, sorted by name
+func (dn *DNode) Entries() []Node {
+	return sortedEntries(dn)
+}
+
+// ScanInfo returns the details of the scan which produced the directory
+// node. It is set on the root of a tree, and on the directory of each
+// host in a tree made by Aggregate, which holds the root of that host's
+// scan; other directories have none.
+func (dn *DNode) ScanInfo() *ScanInfo {
+	return dn.scan
+}
+
 // TotalLength counts the number of nodes
 func (dn *DNode) TotalLength() int {
 	l := len(dn.leaves) + 1 // +1 to count yourself
@@ -66,6 +104,45 @@ func (dn *DNode) Flatten() []Node {
 	return nodes
 }
 
+// Lookup finds the node at rel, a path relative to dn separated by "/" or
+// the host's separator, returning nil if there is no such node
+func (dn *DNode) Lookup(rel string) Node {
+	rel = path.Clean(slash(rel))
+	if rel == "." {
+		return dn
+	}
+
+	var found Node
+	dn.walk(func(crel string, n Node) bool {
+		if crel == rel {
+			found = n
+			return false
+		}
+		return strings.HasPrefix(rel, crel+"/")
+	})
+
+	return found
+}
+
+// walk calls fn for every node beneath dn, in no particular order, with
+// its path relative to dn. Returning false skips the contents of a
+// directory.
+func (dn *DNode) walk(fn func(rel string, n Node) bool) {
+	var visit func(dn *DNode, rel string)
+	visit = func(dn *DNode, rel string) {
+		for _, leaf := range dn.leaves {
+			fn(path.Join(rel, leaf.name), leaf)
+		}
+		for _, child := range dn.children {
+			crel := path.Join(rel, child.name)
+			if fn(crel, child) {
+				visit(child, crel)
+			}
+		}
+	}
+	visit(dn, "")
+}
+
 // Errors returns a slice of all of the errors contained in the DNode
 func (dn *DNode) Errors() []error {
 	errs := []error{}
@@ -87,6 +164,10 @@ type Leaf struct {
 	path   string
 	parent *DNode
 	info   *os.FileInfo
+	hashes map[string][]byte
+	tags   []string // sorted
+
+	annotations map[string]any // see Annotate
 }
 
 var _ Node = &Leaf{}
@@ -105,6 +186,9 @@ func (l *Leaf) Info() *os.FileInfo {
 type Node interface {
 	Path() string
 	Info() *os.FileInfo
+	AddTag(tag string)
+	HasTag(tag string) bool
+	Tags() []string
 }
 
 func newNode(fullpath string, fi *os.FileInfo) Node {
@@ -124,25 +208,87 @@ func newNode(fullpath string, fi *os.FileInfo) Node {
 	}
 }
 
-func (dn *DNode) work(work workStream, stop stopStream, pending *int32) {
-	f, err := os.Open(dn.path)
-	if err != nil {
+func (dn *DNode) work(w *worker) {
+	r := w.r
+
+	dn.begin()
+	defer dn.finish(r)
+
+	w.enter(phaseReadDir)
+	if r.MaxDepth > 0 && dn.depth >= r.MaxDepth {
+		dn.err = &NodeError{Path: dn.path, Kind: ErrTooDeep}
+		return
+	}
+	if r.skipPseudo(dn) {
+		return
+	}
+	if r.limitReached() {
+		dn.err = &NodeError{Path: dn.path, Kind: ErrLimitReached}
+		return
+	}
+	if err := r.revisit(dn); err != nil {
 		dn.err = err
 		return
 	}
 
-	infos, err := f.Readdir(0)
+	entries, err := r.readDir(dn.path)
+	if errors.Is(err, errStale) {
+		err = r.retryStale(dn.path, err, func() (err error) {
+			entries, err = r.readDir(dn.path)
+			return err
+		})
+	}
 	if err != nil {
-		dn.err = err
-		f.Close()
+		if errors.Is(err, fs.ErrNotExist) && r.vanish(dn.path) {
+			return // it stays in the tree, empty
+		}
+		dn.err = nodeError(dn.path, err)
 		return
 	}
-	f.Close()
 
-	for _, fi := range infos {
-		switch node := newNode(path.Join(dn.path, fi.Name()), &fi).(type) {
+	if r.sequential() {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+
+	w.enter(phaseStat)
+	for _, entry := range entries {
+		fi, err := r.entryInfo(dn.path, entry)
+		if errors.Is(err, errStale) {
+			err = r.retryStale(path.Join(dn.path, entry.Name()), err, func() (err error) {
+				fi, err = r.entryInfo(dn.path, entry)
+				return err
+			})
+		}
+		if err != nil {
+			p := path.Join(dn.path, entry.Name())
+			if !errors.Is(err, fs.ErrNotExist) || !r.vanish(p) {
+				dn.addError(nodeError(p, err))
+			}
+			continue
+		}
+
+		var size int64
+		if !fi.IsDir() {
+			size = fi.Size()
+		}
+		if !r.admit(size) {
+			dn.addError(&NodeError{Path: dn.path, Kind: ErrLimitReached})
+			break
+		}
+
+		p := path.Join(dn.path, fi.Name())
+		fi = r.withBirthTime(p, fi, false)
+		fi = r.withAttributes(p, fi)
+		switch node := newNode(p, &fi).(type) {
 		case *DNode:
 			node.parent = dn
+			node.depth = dn.depth + 1
+			node.budget = dn.budget
+			if dn.parent == nil {
+				node.budget = r.budgets[node.name]
+			}
 			dn.children = append(dn.children, node)
 		case *Leaf:
 			node.parent = dn
@@ -150,14 +296,78 @@ func (dn *DNode) work(work workStream, stop stopStream, pending *int32) {
 		}
 	}
 
+	if len(r.Hashes) > 0 || r.QuickHash > 0 || len(r.classifiers) > 0 || len(r.Processors) > 0 {
+		w.enter(phaseHash)
+		for _, leaf := range dn.leaves {
+			fi := *leaf.info
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+
+			if len(r.classifiers) > 0 {
+				if err := leaf.classify(w); err != nil {
+					leaf.fail(w, err)
+					continue
+				}
+			}
+			if err := leaf.process(w); err != nil {
+				leaf.fail(w, err)
+				continue
+			}
+
+			if r.QuickHash > 0 {
+				sum, err := r.quickHash(leaf, r.QuickHash)
+				if err != nil {
+					leaf.fail(w, err)
+					continue
+				}
+				leaf.setHash(QuickHashName, sum)
+			}
+
+			switch {
+			case len(r.Hashes) == 0:
+			case fi.Size() >= LargeFileSize && r.hashThreads > 0:
+				atomic.AddInt32(&dn.times.pending, 1)
+				r.Pool.idle(r.Priority, func() { r.hashWork <- leaf })
+			default:
+				leaf.hashOrFail(w)
+			}
+		}
+	}
+
+	dn.times.count(len(dn.leaves), len(dn.children))
+	for _, leaf := range dn.leaves {
+		dn.times.add((*leaf.info).ModTime())
+	}
+	for _, child := range dn.children {
+		dn.times.add((*child.info).ModTime())
+	}
+	atomic.AddInt32(&dn.times.pending, int32(len(dn.children)))
+
 	for _, dn := range dn.children {
+		if r.sequential() {
+			dn.work(w)
+			continue
+		}
+		if !dn.acquire() {
+			atomic.AddInt64(&r.stats.OverBudget, 1)
+			dn.work(w)
+			continue
+		}
+
+		// count the child before handing it off, so that a worker
+		// finishing it can't see the count reach zero early
+		atomic.AddInt32(&r.pending, 1)
 		select {
-		case <-stop:
+		case <-r.stop:
 			return
-		case work <- dn:
-			atomic.AddInt32(pending, 1)
+		case r.work <- dn:
+			atomic.AddInt64(&r.stats.Queued, 1)
 		default:
-			dn.work(work, stop, pending)
+			atomic.AddInt32(&r.pending, -1)
+			atomic.AddInt64(&r.stats.Inline, 1)
+			dn.release()
+			dn.work(w)
 		}
 	}
 }
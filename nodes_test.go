@@ -109,10 +109,10 @@ func TestWork(t *testing.T) {
 		dn, err := getDNode(where)
 		require.NoError(err)
 
-		ws := make(workStream)
-		ss := make(stopStream)
-		var i int32
-		dn.work(ws, ss, &i)
+		r := NewRoot(where)
+		r.WorkListSize = 0
+		r.setup()
+		dn.work(r.newWorker(0))
 	})
 
 	t.Run("Pure single-threaded", func(t *testing.T) {
@@ -177,7 +177,7 @@ func TestWork(t *testing.T) {
 		dn, err := r.Run()
 		assert.Error(err)
 		assert.Nil(dn)
-		assert.Contains(err.Error(), "not a directory")
+		assert.ErrorIs(err, ErrNotDir)
 	})
 
 	t.Run("running on a non-existent fails", func(t *testing.T) {
@@ -189,7 +189,7 @@ func TestWork(t *testing.T) {
 		dn, err := r.Run()
 		assert.Nil(dn)
 		require.Error(err)
-		assert.Contains(err.Error(), "no such file or directory")
+		assert.ErrorIs(err, fs.ErrNotExist)
 	})
 
 	t.Run("Flatten() works", func(t *testing.T) {
@@ -227,8 +227,10 @@ func TestWork(t *testing.T) {
 		errs := dn.Errors()
 		require.NotEmpty(errs)
 		assert.Equal(1, len(errs))
-		assert.Contains(errs[0].Error(), bad)
-		assert.Contains(errs[0].Error(), "permission")
+		assert.ErrorIs(errs[0], ErrPermission)
+		var ne *NodeError
+		require.ErrorAs(errs[0], &ne)
+		assert.Equal(bad, ne.Path)
 	})
 
 	t.Run("there are names", func(t *testing.T) {
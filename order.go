@@ -0,0 +1,145 @@
+package ctree
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	xlanguage "golang.org/x/text/language"
+)
+
+// NameOrder reports whether the name a sorts before the name b. A nil
+// NameOrder is ByteOrder.
+type NameOrder func(a, b string) bool
+
+// ByteOrder sorts names by their bytes, as the C locale does, so that
+// "Zebra" comes before "apple" and "file10" before "file2"
+func ByteOrder(a, b string) bool {
+	return a < b
+}
+
+// NaturalOrder sorts names by their bytes, but with each run of digits
+// compared as a number, so that "file2" comes before "file10". Names
+// differing only in leading zeros, such as "file02" and "file2", are
+// sorted by their bytes.
+func NaturalOrder(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// naturalCompare compares a and b as NaturalOrder does, but returns 0 for
+// names differing only in leading zeros
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			var x, y string
+			x, a = digits(a)
+			y, b = digits(b)
+			if c := numberCompare(x, y); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// digits splits s after its leading run of digits
+func digits(s string) (run, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// numberCompare compares two runs of digits as numbers, however long
+func numberCompare(x, y string) int {
+	x = strings.TrimLeft(x, "0")
+	y = strings.TrimLeft(y, "0")
+	if len(x) != len(y) {
+		return len(x) - len(y)
+	}
+	return strings.Compare(x, y)
+}
+
+// Collation returns the order in which speakers of the language with the
+// BCP 47 tag, such as "en", "de-DE", or "sv", alphabetize names, where
+// case and accents matter less than the letters themselves. If natural
+// is set, runs of digits are compared as numbers, as NaturalOrder does.
+// Names the collation finds equal are sorted by their bytes.
+func Collation(tag string, natural bool) (NameOrder, error) {
+	lang, err := xlanguage.Parse(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []collate.Option
+	if natural {
+		opts = append(opts, collate.Numeric)
+	}
+
+	// a Collator keeps buffers, so it can't be used by many goroutines
+	// at once
+	var mu sync.Mutex
+	c := collate.New(lang, opts...)
+	return func(a, b string) bool {
+		mu.Lock()
+		cmp := c.CompareString(a, b)
+		mu.Unlock()
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return a < b
+	}, nil
+}
+
+// CollationFromEnv returns the Collation of the locale set by the
+// LC_ALL, LC_COLLATE, or LANG environment variables, as ls uses, such as
+// "de_DE.UTF-8". If none is set, or it is "C" or "POSIX", or not
+// understood, the order is ByteOrder, or NaturalOrder if natural is set.
+func CollationFromEnv(natural bool) NameOrder {
+	fallback := ByteOrder
+	if natural {
+		fallback = NaturalOrder
+	}
+
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	// such as "de_DE.UTF-8@euro"
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return fallback
+	}
+
+	order, err := Collation(strings.ReplaceAll(locale, "_", "-"), natural)
+	if err != nil {
+		return fallback
+	}
+	return order
+}
+
+// EntriesFunc returns the children and leaves of dn, sorted by name in
+// order
+func (dn *DNode) EntriesFunc(order NameOrder) []Node {
+	return sortEntries(dn, order)
+}
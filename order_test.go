@@ -0,0 +1,127 @@
+package ctree
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sorted(order NameOrder, names ...string) []string {
+	sort.Slice(names, func(i, j int) bool {
+		return order(names[i], names[j])
+	})
+	return names
+}
+
+func TestNaturalOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"file1", "file2", "file10", "file10a", "file10b", "file100"},
+		sorted(NaturalOrder, "file10b", "file100", "file2", "file10", "file1", "file10a"))
+	assert.Equal([]string{"1.9.2", "1.10", "1.10.1"},
+		sorted(NaturalOrder, "1.10.1", "1.10", "1.9.2"))
+	assert.Equal([]string{"a", "a0", "a00", "a01", "a1", "b"},
+		sorted(NaturalOrder, "b", "a1", "a01", "a00", "a0", "a"))
+	assert.Equal([]string{"x99999999999999999999", "x100000000000000000000"},
+		sorted(NaturalOrder, "x100000000000000000000", "x99999999999999999999"))
+	assert.Equal([]string{"Zebra", "apple"}, sorted(NaturalOrder, "apple", "Zebra"))
+}
+
+func TestCollation(t *testing.T) {
+	assert := assert.New(t)
+
+	en, err := Collation("en", false)
+	require.NoError(t, err)
+	assert.Equal([]string{"apple", "Banana", "cherry", "éclair", "file10", "file2"},
+		sorted(en, "file2", "cherry", "éclair", "Banana", "file10", "apple"))
+	assert.Equal([]string{"a", "A"}, sorted(en, "A", "a"))
+
+	sv, err := Collation("sv", false)
+	require.NoError(t, err)
+	assert.Equal([]string{"o", "z", "å", "ö"}, sorted(sv, "ö", "å", "z", "o"))
+
+	natural, err := Collation("en", true)
+	require.NoError(t, err)
+	assert.Equal([]string{"File1", "file2", "File10"}, sorted(natural, "File10", "file2", "File1"))
+
+	_, err = Collation("not a tag", false)
+	assert.Error(err)
+}
+
+func TestCollationFromEnv(t *testing.T) {
+	names := []string{"b", "B", "a10", "a2", "A"}
+	for _, c := range []struct {
+		lcAll, lang string
+		natural     bool
+		want        []string
+	}{
+		{"", "", false, []string{"A", "B", "a10", "a2", "b"}},
+		{"", "C.UTF-8", true, []string{"A", "B", "a2", "a10", "b"}},
+		{"", "en_US.UTF-8", false, []string{"A", "a10", "a2", "b", "B"}},
+		{"POSIX", "en_US.UTF-8", false, []string{"A", "B", "a10", "a2", "b"}},
+		{"de_DE.UTF-8@euro", "", true, []string{"A", "a2", "a10", "b", "B"}},
+	} {
+		t.Setenv("LC_ALL", c.lcAll)
+		t.Setenv("LC_COLLATE", "")
+		t.Setenv("LANG", c.lang)
+		got := sorted(CollationFromEnv(c.natural), append([]string(nil), names...)...)
+		assert.Equal(t, c.want, got, "LC_ALL=%q LANG=%q", c.lcAll, c.lang)
+	}
+}
+
+func TestOrderedListings(t *testing.T) {
+	mfs := fstest.MapFS{
+		"top/f10":   {Data: []byte("ten")},
+		"top/f2":    {Data: []byte("two")},
+		"top/F1/x":  {Data: []byte("x")},
+		"top/e.txt": {Data: []byte("e")},
+	}
+	r := NewRoot("top")
+	r.FS = mfs
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	en, err := Collation("en", true)
+	require.NoError(t, err)
+
+	names := func(entries []Node) []string {
+		var names []string
+		for _, n := range entries {
+			names = append(names, nodeName(n))
+		}
+		return names
+	}
+
+	t.Run("EntriesFunc", func(t *testing.T) {
+		assert.Equal(t, []string{"F1", "e.txt", "f10", "f2"}, names(dn.Entries()))
+		assert.Equal(t, []string{"F1", "e.txt", "f2", "f10"}, names(dn.EntriesFunc(NaturalOrder)))
+		assert.Equal(t, []string{"e.txt", "F1", "f2", "f10"}, names(dn.EntriesFunc(en)))
+	})
+
+	t.Run("WriteTree", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, WriteTree(&sb, dn, &TreeOptions{Order: en}))
+		assert.Equal(t, "top\n├── e.txt\n├── F1\n│   └── x\n├── f2\n└── f10\n", sb.String())
+	})
+
+	t.Run("WriteLsLRFunc", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, WriteLsLRFunc(&sb, dn, NaturalOrder))
+		out := sb.String()
+		f1, f2, f10 := strings.Index(out, " F1\n"), strings.Index(out, " f2\n"), strings.Index(out, " f10\n")
+		assert.True(t, f1 < f2 && f2 < f10, out)
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		dn.Compact(&CompactOptions{Sort: true, Order: en})
+		var leaves []string
+		for _, l := range dn.leaves {
+			leaves = append(leaves, l.name)
+		}
+		assert.Equal(t, []string{"e.txt", "f2", "f10"}, leaves)
+	})
+}
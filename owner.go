@@ -0,0 +1,40 @@
+package ctree
+
+// OwnerUsage totals the leaves of a tree by the user and the group that
+// own them
+type OwnerUsage struct {
+	Users  map[uint32]Usage
+	Groups map[uint32]Usage
+}
+
+// UsageByOwner totals the leaves beneath dn by owning user and group.
+// Leaves whose owners are not known are left out.
+func UsageByOwner(dn *DNode) OwnerUsage {
+	ou := OwnerUsage{
+		Users:  map[uint32]Usage{},
+		Groups: map[uint32]Usage{},
+	}
+
+	dn.walk(func(_ string, n Node) bool {
+		l, ok := n.(*Leaf)
+		if !ok {
+			return true
+		}
+		uid, gid, ok := Owner(l)
+		if !ok {
+			return true
+		}
+
+		u := ou.Users[uid]
+		u.add(l)
+		ou.Users[uid] = u
+
+		g := ou.Groups[gid]
+		g.add(l)
+		ou.Groups[gid] = g
+
+		return true
+	})
+
+	return ou
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package ctree
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageByOwner(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dn := scanCopy(t, nil)
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+
+	ou := UsageByOwner(dn)
+	assert.Equal(map[uint32]Usage{uid: {Files: 4, Bytes: 62}}, ou.Users)
+	assert.Equal(map[uint32]Usage{gid: {Files: 4, Bytes: 62}}, ou.Groups)
+
+	var buf bytes.Buffer
+	require.NoError(WriteSnapshot(&buf, dn))
+	loaded, err := ReadSnapshot(&buf)
+	require.NoError(err)
+	assert.Equal(ou, UsageByOwner(loaded))
+
+	luid, lgid, ok := Owner(loaded.Lookup("ceswift/.cshrc"))
+	assert.True(ok)
+	assert.Equal(uid, luid)
+	assert.Equal(gid, lgid)
+}
@@ -0,0 +1,31 @@
+package ctree
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// hostSeparator is the separator of the host's paths, a variable so that
+// tests can pretend to be on another host
+var hostSeparator = filepath.Separator
+
+// slash converts p, which may use the host's separator, to the
+// "/"-separated form used for the paths of nodes. The paths of nodes are
+// the same whatever the host, so that snapshots are portable, and the
+// functions taking paths within trees, such as Lookup and the Rules,
+// accept either form.
+func slash(p string) string {
+	if hostSeparator == '/' {
+		return p
+	}
+	return strings.ReplaceAll(p, string(hostSeparator), "/")
+}
+
+// LocalPath returns the path of n with the host's separator, as other
+// programs on the host expect
+func LocalPath(n Node) string {
+	if hostSeparator == '/' {
+		return n.Path()
+	}
+	return strings.ReplaceAll(n.Path(), "/", string(hostSeparator))
+}
@@ -0,0 +1,48 @@
+package ctree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaths(t *testing.T) {
+	t.Run("the host's separator", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		leaf := dn.Lookup("ceswift/bin/worms")
+		assert.NotNil(leaf)
+		assert.Equal(filepath.Join(dn.Path(), "ceswift", "bin", "worms"), LocalPath(leaf))
+		assert.Equal(leaf, dn.Lookup(filepath.Join("ceswift", "bin", "worms")))
+	})
+
+	t.Run("backslashes", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dn := scanCopy(t, func(home string) {
+			require.NoError(os.Chmod(filepath.Join(home, "ceswift", "bin", "worms"), 0o777))
+		})
+		windows := strings.ReplaceAll(dn.Path(), "/", `\`)
+
+		hostSeparator = '\\'
+		defer func() { hostSeparator = filepath.Separator }()
+
+		leaf := dn.Lookup(`ceswift\bin\worms`)
+		require.NotNil(leaf)
+		assert.Equal(dn.Lookup("ceswift/bin/worms"), leaf)
+		assert.Equal(windows+`\ceswift\bin\worms`, LocalPath(leaf))
+
+		assert.Empty(Check(dn, MustExist(`wsfitzpa\bin\zrun`), DirMustExist(`ceswift\bin`)))
+		assert.Len(Check(dn, NoWorldWritable(`ceswift\bin`)), 1)
+		assert.Empty(Check(dn, NoWorldWritable(`wsfitzpa\bin`)))
+
+		assert.Equal("a/b/c", slash(`a\b\c`))
+		assert.Equal("a/b", slash("a/b"))
+	})
+}
@@ -0,0 +1,123 @@
+package ctree
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Violation describes a place where a tree breaks a Rule
+type Violation struct {
+	Rule    string
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Rule, v.Path, v.Message)
+}
+
+// Rule checks a tree, returning every violation it finds. Paths in rules
+// and violations are relative to the root of the tree being checked.
+// Rules accept paths separated by "/" or the host's separator; violations
+// use "/".
+type Rule func(dn *DNode) []Violation
+
+// Check applies every rule to dn, returning all of the violations found
+func Check(dn *DNode, rules ...Rule) []Violation {
+	violations := []Violation{}
+
+	for _, rule := range rules {
+		violations = append(violations, rule(dn)...)
+	}
+
+	return violations
+}
+
+// EachNode returns a Rule named name, which calls check for every node in
+// the tree. A non-empty message from check is a violation.
+func EachNode(name string, check func(rel string, n Node) string) Rule {
+	return func(dn *DNode) []Violation {
+		violations := []Violation{}
+
+		dn.walk(func(rel string, n Node) bool {
+			if msg := check(rel, n); msg != "" {
+				violations = append(violations, Violation{
+					Rule:    name,
+					Path:    rel,
+					Message: msg,
+				})
+			}
+			return true
+		})
+
+		return violations
+	}
+}
+
+// MaxFileSize is a Rule that no file may be larger than size bytes
+func MaxFileSize(size int64) Rule {
+	name := fmt.Sprintf("max file size %d", size)
+
+	return EachNode(name, func(rel string, n Node) string {
+		fi := *n.Info()
+		if fi.IsDir() || fi.Size() <= size {
+			return ""
+		}
+		return fmt.Sprintf("size is %d", fi.Size())
+	})
+}
+
+// NoWorldWritable is a Rule that nothing at or beneath under may be
+// writable by everyone. Symbolic links are ignored, since their
+// permissions are not used.
+func NoWorldWritable(under string) Rule {
+	name := fmt.Sprintf("no world writable under %q", under)
+
+	return EachNode(name, func(rel string, n Node) string {
+		mode := (*n.Info()).Mode()
+		if !beneath(rel, under) || mode&fs.ModeSymlink != 0 || mode&0002 == 0 {
+			return ""
+		}
+		return fmt.Sprintf("mode is %v", mode)
+	})
+}
+
+// MustExist is a Rule that there must be a node at rel
+func MustExist(rel string) Rule {
+	return func(dn *DNode) []Violation {
+		if dn.Lookup(rel) != nil {
+			return nil
+		}
+		return []Violation{{
+			Rule:    fmt.Sprintf("%q must exist", rel),
+			Path:    rel,
+			Message: "does not exist",
+		}}
+	}
+}
+
+// DirMustExist is a Rule that there must be a directory at rel
+func DirMustExist(rel string) Rule {
+	return func(dn *DNode) []Violation {
+		msg := "does not exist"
+		switch dn.Lookup(rel).(type) {
+		case *DNode:
+			return nil
+		case *Leaf:
+			msg = "is not a directory"
+		}
+		return []Violation{{
+			Rule:    fmt.Sprintf("directory %q must exist", rel),
+			Path:    rel,
+			Message: msg,
+		}}
+	}
+}
+
+// beneath reports whether rel is at or beneath dir
+func beneath(rel, dir string) bool {
+	dir = path.Clean(slash(dir))
+	return dir == "." || rel == dir || strings.HasPrefix(rel, dir+"/")
+}
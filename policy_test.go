@@ -0,0 +1,67 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dn := scanCopy(t, func(where string) {
+		writeFile(t, path.Join(where, "ceswift", "big"), strings.Repeat("x", 64))
+		require.NoError(t, os.Chmod(path.Join(where, "wsfitzpa", ".cshrc"), 0666))
+		require.NoError(t, os.Chmod(path.Join(where, "ceswift", "bin", "worms"), 0666))
+	})
+
+	t.Run("no rules", func(t *testing.T) {
+		assert.Empty(t, Check(dn))
+	})
+
+	t.Run("max file size", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		violations := Check(dn, MaxFileSize(63))
+		require.Len(violations, 1)
+		assert.Equal("ceswift/big", violations[0].Path)
+		assert.Contains(violations[0].Message, "64")
+		assert.Empty(Check(dn, MaxFileSize(64)))
+	})
+
+	t.Run("no world writable", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		violations := Check(dn, NoWorldWritable("wsfitzpa"))
+		require.Len(violations, 1)
+		assert.Equal("wsfitzpa/.cshrc", violations[0].Path)
+
+		assert.Len(Check(dn, NoWorldWritable("")), 2)
+		assert.Empty(Check(dn, NoWorldWritable("wsfitzpa/bin")))
+	})
+
+	t.Run("must exist", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		assert.Empty(Check(dn,
+			MustExist("ceswift/bin/worms"),
+			MustExist("."),
+			DirMustExist("wsfitzpa/bin/"),
+		))
+
+		violations := Check(dn,
+			MustExist("ceswift/bin/snakes"),
+			DirMustExist("ceswift/.cshrc"),
+			DirMustExist("nobody"),
+		)
+		require.Len(violations, 3)
+		assert.Equal("does not exist", violations[0].Message)
+		assert.Equal("is not a directory", violations[1].Message)
+		assert.Equal("does not exist", violations[2].Message)
+	})
+}
@@ -0,0 +1,146 @@
+package ctree
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// Priority orders the Roots sharing a Pool: when workers are scarce, they
+// go to the Roots of highest Priority first, and to Roots of equal
+// Priority in the order they asked
+type Priority int
+
+// Some priorities; any other value may be used too
+const (
+	PriorityBackground  Priority = -1
+	PriorityNormal      Priority = 0
+	PriorityInteractive Priority = 1
+)
+
+// Pool bounds the work of every Root that uses it, so that services
+// scanning many trees at once don't oversubscribe their hosts. However
+// many Roots are running, and however many Threads each has, no more than
+// the Pool's workers walk directories or hash files at once, and no more
+// than its file descriptors are open. A Pool is safe for concurrent use.
+type Pool struct {
+	mu      sync.Mutex
+	workers int // the limit, or 0 for none
+	busy    int
+	waiting waiters
+	seq     uint64
+
+	fds chan struct{}
+}
+
+// NewPool returns a Pool of the given numbers of workers and file
+// descriptors. Less than one is no limit.
+func NewPool(workers, fds int) *Pool {
+	p := &Pool{}
+	if workers > 0 {
+		p.workers = workers
+	}
+	if fds > 0 {
+		p.fds = make(chan struct{}, fds)
+	}
+	return p
+}
+
+// InUse returns how many workers and file descriptors are in use
+func (p *Pool) InUse() (workers, fds int) {
+	if p == nil {
+		return 0, 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.busy, len(p.fds)
+}
+
+// The acquire and release methods do nothing when p is nil, or has no
+// limit on what they count, so Roots without a Pool can call them freely.
+
+func (p *Pool) acquireWorker(pri Priority) {
+	if p == nil || p.workers == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.busy < p.workers {
+		p.busy++
+		p.mu.Unlock()
+		return
+	}
+	w := &waiter{pri: pri, seq: p.seq, ready: make(chan struct{})}
+	p.seq++
+	heap.Push(&p.waiting, w)
+	p.mu.Unlock()
+
+	<-w.ready
+}
+
+// releaseWorker hands the worker straight to the first in line, if any
+func (p *Pool) releaseWorker() {
+	if p == nil || p.workers == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.waiting) > 0 {
+		close(heap.Pop(&p.waiting).(*waiter).ready)
+		return
+	}
+	p.busy--
+}
+
+func (p *Pool) acquireFD() {
+	if p != nil && p.fds != nil {
+		p.fds <- struct{}{}
+	}
+}
+
+func (p *Pool) releaseFD() {
+	if p != nil && p.fds != nil {
+		<-p.fds
+	}
+}
+
+// idle gives back the worker the caller holds while it runs fn, which
+// may wait on other workers
+func (p *Pool) idle(pri Priority, fn func()) {
+	p.releaseWorker()
+	defer p.acquireWorker(pri)
+	fn()
+}
+
+// waiter is a goroutine waiting for a worker
+type waiter struct {
+	pri   Priority
+	seq   uint64
+	ready chan struct{}
+}
+
+// waiters is a heap of waiters, first in line first
+type waiters []*waiter
+
+func (ws waiters) Len() int { return len(ws) }
+
+func (ws waiters) Less(i, j int) bool {
+	if ws[i].pri != ws[j].pri {
+		return ws[i].pri > ws[j].pri
+	}
+	return ws[i].seq < ws[j].seq
+}
+
+func (ws waiters) Swap(i, j int) { ws[i], ws[j] = ws[j], ws[i] }
+
+func (ws *waiters) Push(x any) { *ws = append(*ws, x.(*waiter)) }
+
+func (ws *waiters) Pop() any {
+	old := *ws
+	w := old[len(old)-1]
+	*ws = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,191 @@
+package ctree
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gaugeFS measures how many directories are being read, and files are
+// open, at once
+type gaugeFS struct {
+	fs.FS
+	busy, most int32
+}
+
+func (g *gaugeFS) enter() {
+	n := atomic.AddInt32(&g.busy, 1)
+	for {
+		most := atomic.LoadInt32(&g.most)
+		if n <= most || atomic.CompareAndSwapInt32(&g.most, most, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func (g *gaugeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	g.enter()
+	defer atomic.AddInt32(&g.busy, -1)
+	return fs.ReadDir(g.FS, name)
+}
+
+func (g *gaugeFS) Open(name string) (fs.File, error) {
+	f, err := g.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil && fi.IsDir() {
+		return f, nil // fs.Stat opens directories to stat them
+	}
+	g.enter()
+	return &gaugeFile{File: f, g: g}, nil
+}
+
+type gaugeFile struct {
+	fs.File
+	g *gaugeFS
+}
+
+func (gf *gaugeFile) Close() error {
+	atomic.AddInt32(&gf.g.busy, -1)
+	return gf.File.Close()
+}
+
+// wideFS is a tree of many directories, each with a file, and a couple
+// of files large enough to be handed to the hashing goroutines
+func wideFS() fstest.MapFS {
+	m := fstest.MapFS{
+		"top/big1": {Data: make([]byte, LargeFileSize)},
+		"top/big2": {Data: make([]byte, LargeFileSize+1)},
+	}
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			m[fmt.Sprintf("top/d%d/e%d/f", i, j)] = &fstest.MapFile{Data: []byte("x")}
+		}
+	}
+	return m
+}
+
+func TestPool(t *testing.T) {
+	scanAll := func(t *testing.T, pool *Pool, hash bool) *gaugeFS {
+		g := &gaugeFS{FS: wideFS()}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			r := NewRoot("top")
+			r.FS = g
+			r.Threads = 4
+			r.Pool = pool
+			if hash {
+				r.Hashes = []Hasher{XXH3}
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dn, err := r.Run()
+				require.NoError(t, err)
+				assert.Equal(t, 1+20+20*5*2+2, dn.TotalLength())
+			}()
+		}
+		wg.Wait()
+
+		workers, fds := pool.InUse()
+		assert.Zero(t, workers)
+		assert.Zero(t, fds)
+		return g
+	}
+
+	t.Run("bounds workers", func(t *testing.T) {
+		g := scanAll(t, NewPool(2, 0), false)
+		assert.LessOrEqual(t, g.most, int32(2))
+	})
+
+	t.Run("bounds open files", func(t *testing.T) {
+		g := scanAll(t, NewPool(0, 3), true)
+		assert.LessOrEqual(t, g.most, int32(3))
+	})
+
+	t.Run("hashing does not deadlock", func(t *testing.T) {
+		g := scanAll(t, NewPool(1, 1), true)
+		assert.Equal(t, int32(1), g.most)
+	})
+
+	t.Run("no pool", func(t *testing.T) {
+		g := scanAll(t, nil, true)
+		assert.Greater(t, g.most, int32(3))
+	})
+}
+
+func TestPoolPriority(t *testing.T) {
+	t.Run("first in line by priority", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p := NewPool(1, 0)
+		p.acquireWorker(PriorityNormal)
+
+		order := make(chan Priority, 4)
+		queue := func(pri Priority) {
+			go func() {
+				p.acquireWorker(pri)
+				order <- pri
+				p.releaseWorker()
+			}()
+			require.Eventually(t, func() bool {
+				p.mu.Lock()
+				defer p.mu.Unlock()
+				for _, w := range p.waiting {
+					if w.pri == pri {
+						return true
+					}
+				}
+				return false
+			}, time.Second, time.Millisecond)
+		}
+		queue(PriorityBackground)
+		queue(PriorityNormal)
+		queue(PriorityInteractive)
+		queue(5)
+
+		p.releaseWorker()
+		for _, want := range []Priority{5, PriorityInteractive, PriorityNormal, PriorityBackground} {
+			assert.Equal(want, <-order)
+		}
+
+		workers, _ := p.InUse()
+		assert.Zero(workers)
+	})
+
+	t.Run("interactive scans go first", func(t *testing.T) {
+		pool := NewPool(1, 0)
+		finished := make(chan Priority, 2)
+		scan := func(pri Priority) {
+			r := NewRoot("top")
+			r.FS = &gaugeFS{FS: wideFS()}
+			r.Threads = 4
+			r.Pool = pool
+			r.Priority = pri
+			go func() {
+				_, err := r.Run()
+				assert.NoError(t, err)
+				finished <- pri
+			}()
+			require.Eventually(t, r.Running, time.Second, time.Millisecond)
+		}
+
+		scan(PriorityBackground)
+		time.Sleep(10 * time.Millisecond)
+		scan(PriorityInteractive)
+
+		assert.Equal(t, PriorityInteractive, <-finished)
+		assert.Equal(t, PriorityBackground, <-finished)
+	})
+}
@@ -0,0 +1,204 @@
+package ctree
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// TreeOptions controls how WriteTree draws a tree
+type TreeOptions struct {
+	// Colors, if set, colors names with ANSI escapes
+	Colors *Colors
+	// CompressChains draws each chain of directories holding only one
+	// other directory as one entry, named like "a/b/c"; see DNode.Chain
+	CompressChains bool
+	// Order is the order of the entries of each directory; ByteOrder if
+	// nil
+	Order NameOrder
+}
+
+// WriteTree draws dn to w in the style of the tree command, with the
+// entries of each directory sorted by name, in opts' Order
+func WriteTree(w io.Writer, dn *DNode, opts *TreeOptions) error {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+
+	bw := bufio.NewWriter(w)
+	tw := &treeWriter{w: bw, colors: opts.Colors, chains: opts.CompressChains, order: opts.Order}
+
+	bw.WriteString(tw.name(dn, dn.path))
+	bw.WriteString("\n")
+	tw.dir(dn, "")
+
+	return bw.Flush()
+}
+
+type treeWriter struct {
+	w      *bufio.Writer
+	colors *Colors
+	chains bool
+	order  NameOrder
+}
+
+func (tw *treeWriter) dir(dn *DNode, prefix string) {
+	entries := sortEntries(dn, tw.order)
+
+	for i, n := range entries {
+		branch, indent := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		name := nodeName(n)
+		child, isDir := n.(*DNode)
+		if isDir && tw.chains {
+			child, name = child.Chain()
+		}
+
+		tw.w.WriteString(prefix + branch)
+		tw.w.WriteString(tw.name(n, name))
+		tw.w.WriteString("\n")
+
+		if isDir {
+			tw.dir(child, prefix+indent)
+		}
+	}
+}
+
+func (tw *treeWriter) name(n Node, name string) string {
+	if tw.colors == nil {
+		return name
+	}
+	return tw.colors.Paint(n, name)
+}
+
+// Colors classifies nodes the way ls does with LS_COLORS, and holds the
+// SGR sequence for each class
+type Colors struct {
+	types map[string]string // by two letter LS_COLORS key, such as "di"
+	exts  map[string]string // by suffix, such as ".go"
+}
+
+// DefaultColors returns the colors dircolors uses when nothing is
+// configured
+func DefaultColors() *Colors {
+	return ParseLSColors("rs=0:di=01;34:ln=01;36:pi=40;33:so=01;35:do=01;35:" +
+		"bd=40;33;01:cd=40;33;01:su=37;41:sg=30;43:tw=30;42:ow=34;42:" +
+		"st=37;44:ex=01;32")
+}
+
+// ColorsFromEnv returns the colors in the LS_COLORS environment variable,
+// or DefaultColors if it is not set
+func ColorsFromEnv() *Colors {
+	if s := os.Getenv("LS_COLORS"); s != "" {
+		return ParseLSColors(s)
+	}
+	return DefaultColors()
+}
+
+// ParseLSColors parses the LS_COLORS format: colon separated key=value
+// pairs, where a key is either a two letter type, such as "di" or "ex",
+// or a "*" pattern matching a name suffix, such as "*.tar". Entries it
+// does not understand are ignored.
+func ParseLSColors(s string) *Colors {
+	c := &Colors{
+		types: map[string]string{},
+		exts:  map[string]string{},
+	}
+
+	for _, entry := range strings.Split(s, ":") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(key, "*") {
+			c.exts[key[1:]] = value
+		} else {
+			c.types[key] = value
+		}
+	}
+
+	return c
+}
+
+// Paint wraps name in the escapes for n's class, unless it has no color or
+// is reset to normal, such as "0" or "00"
+func (c *Colors) Paint(n Node, name string) string {
+	sgr := c.Of(n)
+	if strings.Trim(sgr, "0;") == "" {
+		return name
+	}
+	return "\x1b[" + sgr + "m" + name + "\x1b[" + c.reset() + "m"
+}
+
+func (c *Colors) reset() string {
+	if rs, ok := c.types["rs"]; ok {
+		return rs
+	}
+	return "0"
+}
+
+// Of returns the SGR sequence for n, such as "01;34", or "" if it has no
+// color
+func (c *Colors) Of(n Node) string {
+	mode := (*n.Info()).Mode()
+
+	switch {
+	case mode.IsDir():
+		sticky, writable := mode&fs.ModeSticky != 0, mode&0002 != 0
+		switch {
+		case sticky && writable && c.has("tw"):
+			return c.types["tw"]
+		case writable && c.has("ow"):
+			return c.types["ow"]
+		case sticky && c.has("st"):
+			return c.types["st"]
+		}
+		return c.types["di"]
+	case mode&fs.ModeSymlink != 0:
+		return c.types["ln"]
+	case mode&fs.ModeNamedPipe != 0:
+		return c.types["pi"]
+	case mode&fs.ModeSocket != 0:
+		return c.types["so"]
+	case mode&fs.ModeCharDevice != 0:
+		return c.types["cd"]
+	case mode&fs.ModeDevice != 0:
+		return c.types["bd"]
+	case mode&fs.ModeSetuid != 0 && c.has("su"):
+		return c.types["su"]
+	case mode&fs.ModeSetgid != 0 && c.has("sg"):
+		return c.types["sg"]
+	case mode&0111 != 0 && c.has("ex"):
+		return c.types["ex"]
+	}
+
+	if sgr, ok := c.ext(path.Base(n.Path())); ok {
+		return sgr
+	}
+	return c.types["fi"]
+}
+
+func (c *Colors) has(key string) bool {
+	_, ok := c.types[key]
+	return ok
+}
+
+// ext finds the color of the longest suffix pattern matching name
+func (c *Colors) ext(name string) (string, bool) {
+	best, found := "", false
+	longest := 0
+
+	for suffix, sgr := range c.exts {
+		if len(suffix) > longest && strings.HasSuffix(name, suffix) {
+			best, found, longest = sgr, true, len(suffix)
+		}
+	}
+
+	return best, found
+}
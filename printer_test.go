@@ -0,0 +1,96 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTree(t *testing.T) {
+	dn := scanCopy(t, nil)
+
+	var sb strings.Builder
+	require.NoError(t, WriteTree(&sb, dn, nil))
+
+	want := dn.Path() + `
+├── ceswift
+│   ├── .cshrc
+│   └── bin
+│       └── worms
+└── wsfitzpa
+    ├── .cshrc
+    └── bin
+        └── zrun
+`
+	assert.Equal(t, want, sb.String())
+
+	sb.Reset()
+	require.NoError(t, WriteTree(&sb, dn, &TreeOptions{Colors: DefaultColors()}))
+	assert.Contains(t, sb.String(), "├── \x1b[01;34mceswift\x1b[0m\n")
+	assert.Contains(t, sb.String(), "│   ├── .cshrc\n")
+}
+
+func TestWriteTreeChains(t *testing.T) {
+	dn := scanChains(t)
+
+	var sb strings.Builder
+	require.NoError(t, WriteTree(&sb, dn, &TreeOptions{CompressChains: true}))
+	assert.Equal(t, dn.Path()+`
+├── ceswift
+│   ├── .cshrc
+│   └── bin
+│       └── worms
+├── empty/a/b
+├── src/github.com/samf/ctree
+│   └── go.mod
+└── wsfitzpa
+    ├── .cshrc
+    └── bin
+        └── zrun
+`, sb.String())
+}
+
+// modeNode is a leaf with only a name and a mode
+func modeNode(name string, mode fs.FileMode) Node {
+	var fi os.FileInfo = &nodeInfo{name: name, mode: mode, modTime: time.Now()}
+	return newNode("/x/"+name, &fi)
+}
+
+func TestColors(t *testing.T) {
+	c := ParseLSColors("rs=0:di=01;34:ln=01;36:ex=01;32:su=37;41:tw=30;42:" +
+		"ow=34;42:fi=00:*.tar=01;31:*.tar.gz=01;35:bogus:*.go=33")
+
+	cases := []struct {
+		node Node
+		want string
+	}{
+		{modeNode("d", fs.ModeDir|0755), "01;34"},
+		{modeNode("tmp", fs.ModeDir|fs.ModeSticky|0777), "30;42"},
+		{modeNode("pub", fs.ModeDir|0777), "34;42"},
+		{modeNode("l", fs.ModeSymlink|0777), "01;36"},
+		{modeNode("run", 0755), "01;32"},
+		{modeNode("sudo", fs.ModeSetuid|0755), "37;41"},
+		{modeNode("x.tar", 0644), "01;31"},
+		{modeNode("x.tar.gz", 0644), "01;35"},
+		{modeNode("main.go", 0644), "33"},
+		{modeNode("main.go", 0755), "01;32"},
+		{modeNode("README", 0644), "00"},
+		{modeNode("fifo", fs.ModeNamedPipe|0644), ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, c.Of(tc.node), tc.node.Path())
+	}
+
+	assert.Equal(t, "README", c.Paint(modeNode("README", 0644), "README"))
+	assert.Equal(t, "\x1b[33mmain.go\x1b[0m", c.Paint(modeNode("main.go", 0644), "main.go"))
+
+	t.Setenv("LS_COLORS", "di=35")
+	assert.Equal(t, "35", ColorsFromEnv().Of(modeNode("d", fs.ModeDir|0755)))
+	t.Setenv("LS_COLORS", "")
+	assert.Equal(t, "01;34", ColorsFromEnv().Of(modeNode("d", fs.ModeDir|0755)))
+}
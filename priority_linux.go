@@ -0,0 +1,37 @@
+//go:build linux
+
+package ctree
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	schedIdle         = 5 // SCHED_IDLE
+	ioprioWhoProcess  = 1 // IOPRIO_WHO_PROCESS
+	ioprioClassIdle   = 3 // IOPRIO_CLASS_IDLE
+	ioprioClassShift  = 13
+	ioprioIdlePrioVal = ioprioClassIdle << ioprioClassShift
+)
+
+// lowerPriority moves the calling thread into the idle CPU scheduling
+// class and the idle I/O scheduling class. The caller must have locked
+// itself to its thread.
+func lowerPriority() error {
+	var param struct{ priority int32 }
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER,
+		0, schedIdle, uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+
+	_, _, errno = syscall.RawSyscall(syscall.SYS_IOPRIO_SET,
+		ioprioWhoProcess, 0, ioprioIdlePrioVal)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package ctree
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowerPriority(t *testing.T) {
+	t.Run("the thread is deprioritized", func(t *testing.T) {
+		assert := assert.New(t)
+
+		type result struct {
+			err          error
+			policy, prio uintptr
+		}
+		done := make(chan result)
+		go func() {
+			runtime.LockOSThread()
+			var res result
+			res.err = lowerPriority()
+			res.policy, _, _ = syscall.RawSyscall(syscall.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+			res.prio, _, _ = syscall.RawSyscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+			done <- res
+		}()
+
+		res := <-done
+		assert.NoError(res.err)
+		assert.Equal(uintptr(schedIdle), res.policy)
+		assert.Equal(uintptr(ioprioClassIdle), res.prio>>ioprioClassShift)
+	})
+
+	t.Run("low priority runs", func(t *testing.T) {
+		require := require.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where)
+		r.LowPriority = true
+		dn, err := r.Run()
+		require.NoError(err)
+		assert.Equal(t, 10, dn.TotalLength())
+		assert.Zero(t, r.Stats().PriorityErrors)
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ctree
+
+// lowerPriority is not supported on this platform
+func lowerPriority() error {
+	return nil
+}
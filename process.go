@@ -0,0 +1,39 @@
+package ctree
+
+import "io"
+
+// Processor reads the contents of regular files during a walk, recording
+// what it finds, so that content scanners get the walk's parallelism
+// for free. Its methods are called by many workers at once; a worker may
+// tag the leaf it is given, but nothing else in the tree.
+type Processor interface {
+	// Wants reports whether the processor should read the leaf, so
+	// that files it has no use for are not opened for it
+	Wants(l *Leaf) bool
+	// Process reads the contents of the leaf. An error is recorded on
+	// the leaf's directory.
+	Process(l *Leaf, contents io.Reader) error
+}
+
+// process gives the leaf to each of the Root's Processors that wants it
+func (l *Leaf) process(w *worker) error {
+	r := w.r
+
+	for _, p := range r.Processors {
+		if !p.Wants(l) {
+			continue
+		}
+
+		f, err := r.open(l.path)
+		if err != nil {
+			return err
+		}
+		err = p.Process(l, r.counted(f))
+		r.close(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package ctree
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter decides whether to keep a node, given its path relative to the
+// root of the tree
+type Filter func(rel string, n Node) bool
+
+// Exclude is a Filter removing the nodes matched by patterns, which are
+// written as the lines of a .gitignore file are: "*.o" matches anywhere,
+// "/build" and "docs/*.pdf" are relative to the root, a trailing "/"
+// only matches directories, and a leading "!" keeps what earlier patterns
+// removed, except beneath a removed directory.
+func Exclude(patterns ...string) Filter {
+	rules := parseIgnore("", []byte(strings.Join(patterns, "\n")))
+	return func(rel string, n Node) bool {
+		_, dir := n.(*DNode)
+		return !isIgnored(rules, rel, dir)
+	}
+}
+
+// Projection is what applying a Filter to a tree would keep and remove.
+// Nodes counts files and directories; Bytes counts the sizes of files.
+type Projection struct {
+	KeptNodes    int64
+	KeptBytes    int64
+	RemovedNodes int64
+	RemovedBytes int64
+}
+
+// Project reports what filter would keep of dn and what it would remove,
+// without changing it, so that exclusions can be tried before a rescan or
+// a deletion. A directory which is removed takes everything beneath it
+// with it, and filter isn't called for its contents. dn itself is kept.
+func Project(dn *DNode, filter Filter) Projection {
+	p := Projection{KeptNodes: 1}
+
+	dn.walk(func(rel string, n Node) bool {
+		if filter(rel, n) {
+			p.KeptNodes++
+			if l, ok := n.(*Leaf); ok {
+				p.KeptBytes += (*l.info).Size()
+			}
+			return true
+		}
+
+		switch n := n.(type) {
+		case *Leaf:
+			p.RemovedNodes++
+			p.RemovedBytes += (*n.info).Size()
+		case *DNode:
+			u := n.Usage()
+			p.RemovedNodes += int64(n.TotalLength())
+			p.RemovedBytes += u.Bytes
+		}
+		return false
+	})
+
+	return p
+}
+
+// Select returns a copy of the tree at dn with just what filter keeps, as
+// Project counts it: a directory which is removed takes everything beneath
+// it with it, and dn itself is kept. See ParseFilter.
+func Select(dn *DNode, filter Filter) *DNode {
+	cp := selected(dn, filter, "", 0)
+	cp.scan = dn.scan
+	return cp
+}
+
+func selected(dn *DNode, filter Filter, rel string, depth int) *DNode {
+	cp := &DNode{
+		name:  dn.name,
+		path:  dn.path,
+		info:  dn.info,
+		err:   dn.err,
+		depth: depth,
+		tags:  dn.tags,
+	}
+
+	for _, leaf := range dn.leaves {
+		if filter(path.Join(rel, leaf.name), leaf) {
+			cp.leaves = append(cp.leaves, leaf.copyTo(cp))
+		}
+	}
+	for _, child := range dn.children {
+		crel := path.Join(rel, child.name)
+		if filter(crel, child) {
+			kept := selected(child, filter, crel, depth+1)
+			kept.parent = cp
+			cp.children = append(cp.children, kept)
+		}
+	}
+
+	return cp
+}
@@ -0,0 +1,52 @@
+package ctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProject(t *testing.T) {
+	r := NewRoot("home")
+	r.FS = tfs
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   Projection
+	}{
+		{
+			name:   "keep everything",
+			filter: func(string, Node) bool { return true },
+			want:   Projection{KeptNodes: 9, KeptBytes: 62},
+		},
+		{
+			name:   "directories take their contents",
+			filter: Exclude("bin/"),
+			want:   Projection{KeptNodes: 5, KeptBytes: 34, RemovedNodes: 4, RemovedBytes: 28},
+		},
+		{
+			name:   "negation",
+			filter: Exclude("/ceswift", ".cshrc", "!wsfitzpa/.cshrc"),
+			want:   Projection{KeptNodes: 5, KeptBytes: 38, RemovedNodes: 4, RemovedBytes: 24},
+		},
+		{
+			name: "functions",
+			filter: func(_ string, n Node) bool {
+				return (*n.Info()).Size() <= 15 || (*n.Info()).IsDir()
+			},
+			want: Projection{KeptNodes: 7, KeptBytes: 24, RemovedNodes: 2, RemovedBytes: 38},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Project(dn, test.filter))
+		})
+	}
+
+	assert.Equal(t, 9, dn.TotalLength(), "the tree is left alone")
+}
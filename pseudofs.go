@@ -0,0 +1,34 @@
+package ctree
+
+import (
+	"io/fs"
+	"sync/atomic"
+)
+
+// skipPseudo reports whether dn, beneath the root, is on a pseudo
+// filesystem that should not be walked, counting it if so
+func (r *Root) skipPseudo(dn *DNode) bool {
+	if dn.parent == nil || !r.pseudo(dn.path, *dn.info, *dn.parent.info) {
+		return false
+	}
+
+	atomic.AddInt64(&r.stats.PseudoSkipped, 1)
+	return true
+}
+
+// pseudo reports whether the directory at p, with info fi, in a
+// directory with info parent, is on a pseudo filesystem that should not
+// be walked. The check is only made where the device changes, at mount
+// points, when the platform says which device a directory is on.
+func (r *Root) pseudo(p string, fi, parent fs.FileInfo) bool {
+	if r.IncludePseudoFS || !r.onOS() {
+		return false
+	}
+
+	si, psi := statOf(fi), statOf(parent)
+	if si.hasDev && psi.hasDev && si.dev == psi.dev {
+		return false
+	}
+
+	return isPseudoFS(p)
+}
@@ -0,0 +1,60 @@
+package ctree
+
+import "path/filepath"
+
+// Pseudo filesystem magic numbers, from statfs(2)
+const (
+	procMagic       = 0x9fa0
+	sysfsMagic      = 0x62656572
+	devptsMagic     = 0x1cd1
+	debugfsMagic    = 0x64626720
+	tracefsMagic    = 0x74726163
+	securityfsMagic = 0x73636673
+	cgroupMagic     = 0x27e0eb
+	cgroup2Magic    = 0x63677270
+	pstoreMagic     = 0x6165676c
+	bpfMagic        = 0xcafe4a11
+	configfsMagic   = 0x62656570
+	efivarfsMagic   = 0xde5e81e4
+	mqueueMagic     = 0x19800202
+	binfmtfsMagic   = 0x42494e4d
+	selinuxMagic    = 0xf97cff8c
+	fusectlMagic    = 0x65735543
+	nsfsMagic       = 0x6e736673
+	tmpfsMagic      = 0x01021994
+)
+
+var pseudoFS = map[uint32]bool{
+	procMagic:       true,
+	sysfsMagic:      true,
+	devptsMagic:     true,
+	debugfsMagic:    true,
+	tracefsMagic:    true,
+	securityfsMagic: true,
+	cgroupMagic:     true,
+	cgroup2Magic:    true,
+	pstoreMagic:     true,
+	bpfMagic:        true,
+	configfsMagic:   true,
+	efivarfsMagic:   true,
+	mqueueMagic:     true,
+	binfmtfsMagic:   true,
+	selinuxMagic:    true,
+	fusectlMagic:    true,
+	nsfsMagic:       true,
+}
+
+// isPseudoFS reports whether p is on a filesystem of the kernel's making,
+// rather than one holding files. /dev is devtmpfs, which can't be told
+// apart from other tmpfs by its magic number, so it goes by its path.
+func isPseudoFS(p string) bool {
+	t, ok := fsType(p)
+	if !ok {
+		return false
+	}
+	if t == tmpfsMagic {
+		abs, err := filepath.Abs(p)
+		return err == nil && abs == "/dev"
+	}
+	return pseudoFS[t]
+}
@@ -0,0 +1,59 @@
+package ctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudoFS(t *testing.T) {
+	if !isPseudoFS("/proc") {
+		t.Skip("/proc is not mounted")
+	}
+	assert.False(t, isPseudoFS(t.TempDir()))
+
+	scan := func(root string, include bool) (*DNode, Stats) {
+		r := NewRoot(root)
+		r.MaxDepth = 2
+		r.IncludePseudoFS = include
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn, r.Stats()
+	}
+
+	t.Run("skipped beneath the root", func(t *testing.T) {
+		dn, stats := scan("/", false)
+		proc, ok := dn.Lookup("proc").(*DNode)
+		require.True(t, ok)
+		assert.Empty(t, proc.Entries())
+		assert.NoError(t, proc.Error())
+		assert.GreaterOrEqual(t, stats.PseudoSkipped, int64(1))
+	})
+
+	t.Run("verified", func(t *testing.T) {
+		r := NewRoot("/")
+		r.MaxDepth = 2
+		dn, err := r.Run()
+		require.NoError(t, err)
+		problems, err := r.Verify(dn)
+		require.NoError(t, err)
+		for _, p := range problems {
+			assert.NotRegexp(t, "^/proc/", p.Path)
+		}
+	})
+
+	t.Run("walked if asked", func(t *testing.T) {
+		dn, stats := scan("/", true)
+		proc, ok := dn.Lookup("proc").(*DNode)
+		require.True(t, ok)
+		assert.NotEmpty(t, proc.Entries())
+		assert.Zero(t, stats.PseudoSkipped)
+	})
+
+	t.Run("the root is always walked", func(t *testing.T) {
+		dn, stats := scan("/proc", false)
+		assert.NotNil(t, dn.Lookup("self"))
+		assert.Zero(t, stats.PseudoSkipped)
+	})
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package ctree
+
+import "path/filepath"
+
+// pseudoPaths are where pseudo filesystems are usually mounted
+var pseudoPaths = map[string]bool{
+	"/proc": true,
+	"/sys":  true,
+	"/dev":  true,
+}
+
+// isPseudoFS reports whether p is where a pseudo filesystem is usually
+// mounted, since this platform can't say what p is
+func isPseudoFS(p string) bool {
+	abs, err := filepath.Abs(p)
+	return err == nil && pseudoPaths[abs]
+}
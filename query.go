@@ -0,0 +1,549 @@
+package ctree
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseFilter parses a query into a Filter, so that selections can be
+// written without Go, as in
+//
+//	size > 100MB && ext == ".log" && age > 30d
+//
+// A query compares fields of each node with values, joined with "&&" (or
+// "and"), "||" (or "or"), "!" (or "not"), and parentheses. The fields are:
+//
+//	name   the node's name
+//	path   its path relative to the root of the tree
+//	ext    the extension of its name, with the dot, such as ".log"
+//	type   its TypeName, such as "file", "dir", or "symlink"
+//	tag    each of its tags; see Node.Tags
+//	size   its size, or the total size of the files beneath a directory
+//	age    how long before the query was parsed it was last modified
+//	mtime  when it was last modified
+//	depth  how many directories deep it is, from 1
+//	uid    the user ID that owns it, where known
+//	gid    the group ID that owns it, where known
+//
+// Text fields may be compared with "==" and "!=", and matched with
+// regular expressions with "=~" and "!~", against quoted strings or bare
+// words; a node matches "tag == x" if any of its tags is x. Other fields
+// may be compared with "==", "!=", "<", "<=", ">", and ">=". Sizes may
+// have units: B, KB, MB, GB, TB, and PB are powers of 1000, and KiB, MiB,
+// GiB, TiB, and PiB of 1024, whatever their case. Ages are written as
+// numbers with units, such as "30d" or "1h30m": s, m, h, d, w (7 days),
+// and y (365 days). Times are written "2006-01-02", "2006-01-02T15:04:05"
+// in local time, or in RFC 3339 format.
+//
+// The Filter keeps the nodes the query matches, and the directories
+// holding them, so that Select leaves what find(1) would print, and the
+// directories leading to it. It remembers what it found beneath each
+// directory, and the sizes of directories, so that each node is looked
+// at once; a tree changed after it has been filtered should be given a
+// new Filter.
+func ParseFilter(query string) (Filter, error) {
+	toks, err := lexQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", query, err)
+	}
+
+	q := &queryState{
+		now:   time.Now(),
+		sizes: map[*DNode]int64{},
+		below: map[queryAt]bool{},
+	}
+	p := &queryParser{toks: toks, q: q}
+	match, err := p.or()
+	if err == nil && !p.atEnd() {
+		err = p.errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", query, err)
+	}
+
+	return func(rel string, n Node) bool {
+		if match(rel, n) {
+			return true
+		}
+		dn, ok := n.(*DNode)
+		return ok && q.beneath(dn, rel, match)
+	}, nil
+}
+
+// queryState is what a Filter from ParseFilter keeps between calls
+type queryState struct {
+	now time.Time
+
+	mu    sync.Mutex
+	sizes map[*DNode]int64
+	below map[queryAt]bool
+}
+
+// queryAt is a directory, at a path relative to the root of the tree
+// being filtered
+type queryAt struct {
+	dn  *DNode
+	rel string
+}
+
+// beneath reports whether anything beneath dn, at rel, matches
+func (q *queryState) beneath(dn *DNode, rel string, match queryMatch) bool {
+	at := queryAt{dn, rel}
+	q.mu.Lock()
+	found, ok := q.below[at]
+	q.mu.Unlock()
+	if ok {
+		return found
+	}
+
+	for _, leaf := range dn.leaves {
+		if found = match(path.Join(rel, leaf.name), leaf); found {
+			break
+		}
+	}
+	for _, child := range dn.children {
+		if found {
+			break
+		}
+		crel := path.Join(rel, child.name)
+		found = match(crel, child) || q.beneath(child, crel, match)
+	}
+
+	q.mu.Lock()
+	q.below[at] = found
+	q.mu.Unlock()
+	return found
+}
+
+// size returns the total size of the leaves beneath dn, as Usage does
+func (q *queryState) size(dn *DNode) int64 {
+	q.mu.Lock()
+	size, ok := q.sizes[dn]
+	q.mu.Unlock()
+	if ok {
+		return size
+	}
+
+	for _, leaf := range dn.leaves {
+		size += (*leaf.info).Size()
+	}
+	for _, child := range dn.children {
+		size += q.size(child)
+	}
+
+	q.mu.Lock()
+	q.sizes[dn] = size
+	q.mu.Unlock()
+	return size
+}
+
+// queryToken is a word, a quoted string, or an operator of a query; the
+// last token of a query is empty
+type queryToken struct {
+	text   string
+	quoted bool
+	pos    int // in bytes
+}
+
+var queryOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "(", ")"}
+
+func lexQuery(query string) ([]queryToken, error) {
+	var toks []queryToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			s, err := strconv.QuotedPrefix(query[i:])
+			if err != nil {
+				return nil, fmt.Errorf("at %d: bad or unterminated string", i+1)
+			}
+			text, _ := strconv.Unquote(s)
+			toks = append(toks, queryToken{text: text, quoted: true, pos: i})
+			i += len(s)
+		case c == '\'':
+			end := strings.IndexByte(query[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("at %d: unterminated string", i+1)
+			}
+			toks = append(toks, queryToken{text: query[i+1 : i+1+end], quoted: true, pos: i})
+			i += end + 2
+		case startsWord(query[i:]):
+			start := i
+			for i < len(query) && startsWord(query[i:]) {
+				_, size := utf8.DecodeRuneInString(query[i:])
+				i += size
+			}
+			toks = append(toks, queryToken{text: query[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range queryOps {
+				if strings.HasPrefix(query[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("at %d: unexpected %q", i+1, c)
+			}
+			toks = append(toks, queryToken{text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, queryToken{pos: len(query)}), nil
+}
+
+// startsWord reports whether s starts with a character of a bare word
+func startsWord(s string) bool {
+	c, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.-:+/*", c)
+}
+
+// queryParser parses the tokens of a query by recursive descent
+type queryParser struct {
+	toks []queryToken
+	i    int
+	q    *queryState
+}
+
+// queryMatch reports whether a node, at rel, matches part of a query
+type queryMatch func(rel string, n Node) bool
+
+func (p *queryParser) peek() queryToken {
+	return p.toks[p.i]
+}
+
+// atEnd reports whether the parser has taken every token
+func (p *queryParser) atEnd() bool {
+	return p.i == len(p.toks)-1
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.toks[p.i]
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	return t
+}
+
+// accept takes the next token if it is an operator among ops
+func (p *queryParser) accept(ops ...string) bool {
+	t := p.peek()
+	for _, op := range ops {
+		if !t.quoted && t.text == op {
+			p.next()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.peek().pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) or() (queryMatch, error) {
+	left, err := p.and()
+	for err == nil && p.accept("||", "or") {
+		var right queryMatch
+		if right, err = p.and(); err == nil {
+			l := left
+			left = func(rel string, n Node) bool { return l(rel, n) || right(rel, n) }
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) and() (queryMatch, error) {
+	left, err := p.not()
+	for err == nil && p.accept("&&", "and") {
+		var right queryMatch
+		if right, err = p.not(); err == nil {
+			l := left
+			left = func(rel string, n Node) bool { return l(rel, n) && right(rel, n) }
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) not() (queryMatch, error) {
+	switch {
+	case p.accept("!", "not"):
+		m, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(rel string, n Node) bool { return !m(rel, n) }, nil
+	case p.accept("("):
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf(`expected ")"`)
+		}
+		return m, nil
+	}
+	return p.compare()
+}
+
+// compare parses a field, an operator, and a value
+func (p *queryParser) compare() (queryMatch, error) {
+	t := p.peek()
+	field, ok := queryFields[t.text]
+	if t.quoted || !ok {
+		if p.atEnd() {
+			return nil, p.errorf("expected a field")
+		}
+		return nil, p.errorf("unknown field %q", t.text)
+	}
+	p.next()
+
+	op := p.peek()
+	if op.quoted || !strings.Contains(" == != < <= > >= =~ !~ ", " "+op.text+" ") {
+		return nil, p.errorf("expected a comparison after %s", t.text)
+	}
+	p.next()
+
+	value := p.peek()
+	if p.atEnd() {
+		return nil, p.errorf("expected a value")
+	}
+	if !value.quoted && !startsWord(value.text) {
+		return nil, p.errorf("expected a value, not %q", value.text)
+	}
+
+	var m queryMatch
+	var err error
+	if field.strs != nil {
+		m, err = stringMatch(field.strs, op.text, value.text)
+	} else {
+		m, err = p.numberMatch(field, op.text, value.text)
+	}
+	if err != nil {
+		return nil, p.errorf("%s: %v", t.text, err)
+	}
+	p.next()
+	return m, nil
+}
+
+func stringMatch(strs func(rel string, n Node) []string, op, value string) (queryMatch, error) {
+	var is func(s string) bool
+	switch op {
+	case "==", "!=":
+		is = func(s string) bool { return s == value }
+	case "=~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		is = re.MatchString
+	default:
+		return nil, fmt.Errorf("%s is for numbers, times and sizes", op)
+	}
+
+	want := op == "==" || op == "=~"
+	return func(rel string, n Node) bool {
+		for _, s := range strs(rel, n) {
+			if is(s) {
+				return want
+			}
+		}
+		return !want
+	}, nil
+}
+
+func (p *queryParser) numberMatch(field queryField, op, value string) (queryMatch, error) {
+	want, err := field.parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmp func(have int64) bool
+	switch op {
+	case "==":
+		cmp = func(have int64) bool { return have == want }
+	case "!=":
+		cmp = func(have int64) bool { return have != want }
+	case "<":
+		cmp = func(have int64) bool { return have < want }
+	case "<=":
+		cmp = func(have int64) bool { return have <= want }
+	case ">":
+		cmp = func(have int64) bool { return have > want }
+	case ">=":
+		cmp = func(have int64) bool { return have >= want }
+	default:
+		return nil, fmt.Errorf("%s is for text", op)
+	}
+
+	q := p.q
+	return func(rel string, n Node) bool {
+		have, ok := field.num(q, rel, n)
+		return ok && cmp(have)
+	}, nil
+}
+
+// queryField is a field of a query: text, with strs, or a number, with
+// num and parse
+type queryField struct {
+	strs  func(rel string, n Node) []string
+	num   func(q *queryState, rel string, n Node) (int64, bool)
+	parse func(value string) (int64, error)
+}
+
+var queryFields = map[string]queryField{
+	"name": {strs: func(_ string, n Node) []string {
+		return []string{nodeName(n)}
+	}},
+	"path": {strs: func(rel string, _ Node) []string {
+		return []string{rel}
+	}},
+	"ext": {strs: func(_ string, n Node) []string {
+		return []string{path.Ext(nodeName(n))}
+	}},
+	"type": {strs: func(_ string, n Node) []string {
+		return []string{TypeName((*n.Info()).Mode())}
+	}},
+	"tag": {strs: func(_ string, n Node) []string {
+		return n.Tags()
+	}},
+	"size": {
+		num: func(q *queryState, _ string, n Node) (int64, bool) {
+			if dn, ok := n.(*DNode); ok {
+				return q.size(dn), true
+			}
+			return (*n.Info()).Size(), true
+		},
+		parse: parseQuerySize,
+	},
+	"age": {
+		num: func(q *queryState, _ string, n Node) (int64, bool) {
+			return int64(q.now.Sub((*n.Info()).ModTime())), true
+		},
+		parse: parseQueryAge,
+	},
+	"mtime": {
+		num: func(_ *queryState, _ string, n Node) (int64, bool) {
+			return (*n.Info()).ModTime().UnixNano(), true
+		},
+		parse: parseQueryTime,
+	},
+	"depth": {
+		num: func(_ *queryState, rel string, _ Node) (int64, bool) {
+			return int64(strings.Count(rel, "/") + 1), true
+		},
+		parse: parseQueryInt,
+	},
+	"uid": {
+		num: func(_ *queryState, _ string, n Node) (int64, bool) {
+			uid, _, ok := Owner(n)
+			return int64(uid), ok
+		},
+		parse: parseQueryInt,
+	},
+	"gid": {
+		num: func(_ *queryState, _ string, n Node) (int64, bool) {
+			_, gid, ok := Owner(n)
+			return int64(gid), ok
+		},
+		parse: parseQueryInt,
+	},
+}
+
+func parseQueryInt(value string) (int64, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// splitNumber splits s after the number it starts with
+func splitNumber(s string) (float64, string, error) {
+	i := 0
+	for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+		i++
+	}
+	if i == 0 {
+		return 0, "", fmt.Errorf("%q is not a number", s)
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%q is not a number", s[:i])
+	}
+	return f, s[i:], nil
+}
+
+func parseQuerySize(value string) (int64, error) {
+	f, unit, err := splitNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	scale, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("%q: unknown unit %q", value, unit)
+	}
+	return int64(f * scale), nil
+}
+
+var ageUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": day,
+	"w": 7 * day,
+	"y": 365 * day,
+}
+
+func parseQueryAge(value string) (int64, error) {
+	var age time.Duration
+	for rest := value; rest != ""; {
+		f, after, err := splitNumber(rest)
+		if err != nil {
+			return 0, err
+		}
+		i := 0
+		for i < len(after) && !isDigit(after[i]) && after[i] != '.' {
+			i++
+		}
+		unit, ok := ageUnits[after[:i]]
+		if !ok {
+			return 0, fmt.Errorf("%q: unknown unit %q; want s, m, h, d, w, or y", value, after[:i])
+		}
+		age += time.Duration(f * float64(unit))
+		rest = after[i:]
+	}
+	return int64(age), nil
+}
+
+func parseQueryTime(value string) (int64, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UnixNano(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UnixNano(), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a time, such as 2006-01-02", value)
+}
@@ -0,0 +1,103 @@
+package ctree
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-40 * day)
+	mfs := fstest.MapFS{
+		"top/logs/app.log":      {Data: make([]byte, 2000), ModTime: old},
+		"top/logs/app.1.log":    {Data: make([]byte, 500), ModTime: old},
+		"top/logs/new.log":      {Data: make([]byte, 3000), ModTime: now},
+		"top/src/main.go":       {Data: []byte("package main"), ModTime: now},
+		"top/src/Main_test.go":  {Data: []byte("package main"), ModTime: old},
+		"top/src/vendor/x/x.go": {Data: []byte("package x"), ModTime: old},
+		"top/README":            {Data: []byte("read me"), ModTime: time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local)},
+		"top/empty":             {Mode: 0o755 | 1<<31, ModTime: old},
+	}
+	r := NewRoot("top")
+	r.FS = mfs
+	dn, err := r.Run()
+	require.NoError(t, err)
+	dn.Lookup("src/main.go").AddTag("entry")
+
+	selected := func(t *testing.T, query string) []string {
+		filter, err := ParseFilter(query)
+		require.NoError(t, err, query)
+		var paths []string
+		Select(dn, filter).walk(func(rel string, _ Node) bool {
+			paths = append(paths, rel)
+			return true
+		})
+		sort.Strings(paths)
+		return paths
+	}
+
+	for _, c := range []struct {
+		query string
+		want  []string
+	}{
+		{`size > 1KB && ext == ".log" && age > 30d`, []string{"logs", "logs/app.log"}},
+		{`size > 1KiB and ext == .log`, []string{"logs", "logs/app.log", "logs/new.log"}},
+		{`ext == ".log" && !(age > 30d)`, []string{"logs", "logs/new.log"}},
+		{`not ext == .log && type == file && depth == 2`, []string{"src", "src/Main_test.go", "src/main.go"}},
+		{`name =~ '^[a-z]+\.go$'`, []string{"src", "src/main.go", "src/vendor", "src/vendor/x", "src/vendor/x/x.go"}},
+		{`path !~ vendor && ext == .go`, []string{"src", "src/Main_test.go", "src/main.go"}},
+		{`tag == entry`, []string{"src", "src/main.go"}},
+		{`tag != entry && ext == .go && age < 1h`, nil},
+		{`type == dir && size == 0`, []string{"empty"}},
+		{`type == dir && size > 5KB`, []string{"logs"}},
+		{`type == file && (mtime < 2020-01-03 || mtime == "2020-01-02T12:00:00")`, []string{"README"}},
+		{`size >= 2000 && size <= 2KB || name == "x.go"`, []string{"logs", "logs/app.log", "src", "src/vendor", "src/vendor/x", "src/vendor/x/x.go"}},
+		{`age > 1w2d12h && ext == .log && size < 0.001MB`, []string{"logs", "logs/app.1.log"}},
+	} {
+		assert.Equal(t, c.want, selected(t, c.query), c.query)
+	}
+
+	t.Run("Project", func(t *testing.T) {
+		filter, err := ParseFilter(`ext == .log`)
+		require.NoError(t, err)
+		p := Project(dn, filter)
+		assert.Equal(t, int64(5), p.KeptNodes)
+		assert.Equal(t, int64(5500), p.KeptBytes)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, c := range []struct {
+			query, err string
+		}{
+			{``, "at 1: expected a field"},
+			{`size >`, "at 7: expected a value"},
+			{`colour == red`, `at 1: unknown field "colour"`},
+			{`size ~ 1`, `at 6: unexpected '~'`},
+			{`size 1`, "at 6: expected a comparison after size"},
+			{`size > 10XB`, `at 8: size: "10XB": unknown unit "XB"`},
+			{`age > 30`, `unknown unit ""`},
+			{`age > 3mo`, `unknown unit "mo"`},
+			{`mtime > yesterday`, `"yesterday" is not a time`},
+			{`name > a`, "> is for numbers"},
+			{`size =~ 1`, "=~ is for text"},
+			{`name =~ "("`, "missing closing )"},
+			{`name == "unterminated`, "at 9: bad or unterminated string"},
+			{`name == 'unterminated`, "at 9: unterminated string"},
+			{`(size > 1`, `at 10: expected ")"`},
+			{`size > 1 size`, `at 10: unexpected "size"`},
+			{`size > )`, `at 8: expected a value, not ")"`},
+		} {
+			_, err := ParseFilter(c.query)
+			if assert.Error(t, err, c.query) {
+				assert.Contains(t, err.Error(), c.err, c.query)
+				assert.True(t, strings.HasPrefix(err.Error(), `"`+strings.ReplaceAll(c.query, `"`, `\"`)+`": `), err.Error())
+			}
+		}
+	})
+}
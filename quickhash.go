@@ -0,0 +1,171 @@
+package ctree
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+)
+
+// QuickHashName names the digest made by Root.QuickHash
+const QuickHashName = "quick"
+
+// DefaultQuickHashSize is how much of each end of a file Duplicates reads
+// for a quick digest, when the Root does not say
+const DefaultQuickHashSize = 64 << 10
+
+// quickHash digests the size of the leaf, and its first and last n bytes
+func (r *Root) quickHash(l *Leaf, n int64) ([]byte, error) {
+	f, err := r.open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close(f)
+
+	rd := r.counted(f)
+	size := (*l.info).Size()
+	h := xxh3.New()
+	binary.Write(h, binary.BigEndian, size)
+
+	if size <= 2*n {
+		_, err = io.Copy(h, rd)
+		return h.Sum(nil), err
+	}
+
+	if _, err := io.CopyN(h, rd, n); err != nil {
+		return nil, err
+	}
+	if s, ok := rd.(io.Seeker); ok {
+		_, err = s.Seek(size-n, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, rd, size-2*n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(h, rd, n); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// fullHash returns a digest of all of the leaf: one computed by the walk
+// if there is one, or else BLAKE3
+func (r *Root) fullHash(l *Leaf) ([]byte, error) {
+	for _, h := range r.Hashes {
+		if sum := l.Hash(h.Name); sum != nil {
+			return sum, nil
+		}
+	}
+
+	f, err := r.open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close(f)
+
+	h := blake3.New()
+	buf := make([]byte, hashBufferSize)
+	if err := r.readAll(f, (*l.info).Size(), h, buf); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Duplicates finds the regular files beneath dn, which r walked, with
+// identical contents. Files are first grouped by size, then by quick
+// digest, made as Root.QuickHash describes, and only files which still
+// collide are read in full to confirm. Empty files are ignored. Each
+// group is sorted by path, and the groups by size, largest first. Files
+// which cannot be read are left out, and their errors returned.
+func (r *Root) Duplicates(dn *DNode) ([][]*Leaf, error) {
+	n := r.QuickHash
+	if n <= 0 {
+		n = DefaultQuickHashSize
+	}
+
+	bySize := map[int64][]*Leaf{}
+	dn.walk(func(_ string, node Node) bool {
+		if l, ok := node.(*Leaf); ok {
+			fi := *l.info
+			if fi.Mode().IsRegular() && fi.Size() > 0 {
+				bySize[fi.Size()] = append(bySize[fi.Size()], l)
+			}
+		}
+		return true
+	})
+
+	var errs []error
+	digest := func(sum func(*Leaf) ([]byte, error)) func(*Leaf) (string, bool) {
+		return func(l *Leaf) (string, bool) {
+			b, err := sum(l)
+			if err != nil {
+				errs = append(errs, err)
+				return "", false
+			}
+			return string(b), true
+		}
+	}
+	quick := digest(func(l *Leaf) ([]byte, error) {
+		if sum := l.Hash(QuickHashName); sum != nil && r.QuickHash > 0 {
+			return sum, nil
+		}
+		return r.quickHash(l, n)
+	})
+	full := digest(r.fullHash)
+
+	var dups [][]*Leaf
+	for _, same := range bySize {
+		for _, similar := range split(same, quick) {
+			dups = append(dups, split(similar, full)...)
+		}
+	}
+
+	for _, group := range dups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].path < group[j].path
+		})
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		si, sj := (*dups[i][0].info).Size(), (*dups[j][0].info).Size()
+		if si != sj {
+			return si > sj
+		}
+		return dups[i][0].path < dups[j][0].path
+	})
+
+	return dups, errors.Join(errs...)
+}
+
+// split divides leaves into the groups of more than one which share a
+// key, dropping leaves without one
+func split(leaves []*Leaf, key func(*Leaf) (string, bool)) [][]*Leaf {
+	if len(leaves) < 2 {
+		return nil
+	}
+
+	groups := map[string][]*Leaf{}
+	var order []string
+	for _, l := range leaves {
+		k, ok := key(l)
+		if !ok {
+			continue
+		}
+		if _, seen := groups[k]; !seen {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], l)
+	}
+
+	var out [][]*Leaf
+	for _, k := range order {
+		if len(groups[k]) > 1 {
+			out = append(out, groups[k])
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package ctree
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuickHash(t *testing.T) {
+	head := bytes.Repeat([]byte("h"), 100)
+	tail := bytes.Repeat([]byte("t"), 100)
+	middle := func(b byte) []byte { return bytes.Repeat([]byte{b}, 50) }
+	file := func(parts ...[]byte) *fstest.MapFile {
+		return &fstest.MapFile{Data: bytes.Join(parts, nil)}
+	}
+
+	r := NewRoot(".")
+	r.FS = fstest.MapFS{
+		"a":     file(head, middle('x'), tail),
+		"b":     file(head, middle('y'), tail),
+		"short": file([]byte("short")),
+	}
+	r.QuickHash = 100
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	a := dn.Lookup("a").(*Leaf).Hash(QuickHashName)
+	require.NotNil(t, a)
+	assert.Equal(t, a, dn.Lookup("b").(*Leaf).Hash(QuickHashName),
+		"only the ends are hashed")
+	assert.NotNil(t, dn.Lookup("short").(*Leaf).Hash(QuickHashName))
+
+	r.QuickHash = 101
+	dn, err = r.Run()
+	require.NoError(t, err)
+	assert.NotEqual(t, dn.Lookup("a").(*Leaf).Hash(QuickHashName),
+		dn.Lookup("b").(*Leaf).Hash(QuickHashName))
+}
+
+func TestDuplicates(t *testing.T) {
+	big := func(last byte) []byte {
+		b := bytes.Repeat([]byte("x"), 3*DefaultQuickHashSize)
+		b[len(b)/2] = last
+		return b
+	}
+
+	fsys := fstest.MapFS{
+		"one/a":      {Data: big('a')},
+		"two/a":      {Data: big('a')},
+		"two/b":      {Data: big('b')}, // same ends as a, but not the same
+		"small1":     {Data: []byte("same")},
+		"small2":     {Data: []byte("same")},
+		"other":      {Data: []byte("diff")},
+		"empty1":     {},
+		"empty2":     {},
+		"three/a":    {Data: big('a')},
+		"unreadable": {Data: []byte("same")},
+	}
+
+	for _, quick := range []int64{0, 1024} {
+		r := NewRoot(".")
+		r.FS = &FaultFS{
+			FS:     fsys,
+			Faults: []Fault{{Pattern: "unreadable", Ops: []string{OpOpen}, Err: os.ErrPermission}},
+		}
+		dn, err := r.Run()
+		require.NoError(t, err)
+		r.QuickHash = quick
+
+		dups, err := r.Duplicates(dn)
+		assert.ErrorIs(t, err, os.ErrPermission)
+
+		var paths [][]string
+		for _, group := range dups {
+			var ps []string
+			for _, l := range group {
+				ps = append(ps, l.Path())
+			}
+			paths = append(paths, ps)
+		}
+		assert.Equal(t, [][]string{
+			{"one/a", "three/a", "two/a"},
+			{"small1", "small2"},
+		}, paths)
+	}
+}
+
+func TestDuplicatesUsesWalkHashes(t *testing.T) {
+	r := NewRoot(".")
+	r.FS = fstest.MapFS{"a": {Data: []byte("a")}, "b": {Data: []byte("a")}}
+	r.Hashes = []Hasher{SHA256}
+	r.QuickHash = 8
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	// make the files differ from what was hashed, so reading them would
+	// split them up
+	r.FS = fstest.MapFS{"a": {Data: []byte("a")}, "b": {Data: []byte("b")}}
+	dups, err := r.Duplicates(dn)
+	require.NoError(t, err)
+	assert.Len(t, dups, 1)
+}
@@ -0,0 +1,42 @@
+package ctree
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ReadMethod is how a Root reads the contents of files to hash them
+type ReadMethod int
+
+const (
+	// ReadAuto maps files of MmapThreshold or more into memory, where
+	// the platform allows, and reads others through a buffer with a
+	// hint to the kernel to read ahead
+	ReadAuto ReadMethod = iota
+	// ReadPlain reads every file through a buffer, with no hints
+	ReadPlain
+	// ReadMmap maps every file into memory, where the platform allows
+	ReadMmap
+)
+
+// MmapThreshold is the size from which ReadAuto maps files into memory
+const MmapThreshold = 1 << 20
+
+// readAll copies the contents of f, which is size bytes long, to w, by
+// the Root's ReadMethod. Files which are not from the operating system
+// are always read through buf.
+func (r *Root) readAll(f fs.File, size int64, w io.Writer, buf []byte) error {
+	w = countingWriter{w, &r.counters.bytesRead}
+	if osf, ok := f.(*os.File); ok && r.ReadMethod != ReadPlain {
+		if r.ReadMethod == ReadMmap || size >= MmapThreshold {
+			if done, err := mmapCopy(osf, size, w, len(buf)); done {
+				return err
+			}
+		}
+		adviseSequential(osf)
+	}
+
+	_, err := io.CopyBuffer(w, onlyReader{f}, buf)
+	return err
+}
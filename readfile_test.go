@@ -0,0 +1,44 @@
+package ctree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMethods(t *testing.T) {
+	where := t.TempDir()
+	sizes := map[string]int{
+		"empty": 0,
+		"small": 1000,
+		"large": MmapThreshold + 123,
+	}
+	for name, size := range sizes {
+		data := bytes.Repeat([]byte(name), size/len(name)+1)[:size]
+		require.NoError(t, os.WriteFile(path.Join(where, name), data, 0666))
+	}
+
+	for _, method := range []ReadMethod{ReadAuto, ReadPlain, ReadMmap} {
+		t.Run(fmt.Sprint(method), func(t *testing.T) {
+			r := NewRoot(where)
+			r.Hashes = []Hasher{SHA256}
+			r.ReadMethod = method
+			dn, err := r.Run()
+			require.NoError(t, err)
+			assert.Empty(t, dn.Errors())
+
+			for name := range sizes {
+				data, err := os.ReadFile(path.Join(where, name))
+				require.NoError(t, err)
+				sum := sha256.Sum256(data)
+				assert.Equal(t, sum[:], dn.Lookup(name).(*Leaf).Hash("sha256"), name)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package ctree
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyAccessTimes(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sub", "file")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	require.NoError(t, os.WriteFile(file, []byte("read me"), 0o644))
+
+	// an access time before the modification time is updated by every
+	// read, even on filesystems mounted relatime
+	stale := time.Now().Add(-48 * time.Hour)
+	reset := func() {
+		for _, p := range []string{file, filepath.Dir(file)} {
+			require.NoError(t, os.Chtimes(p, stale, time.Now()))
+		}
+	}
+	times := func(p string) [3]syscall.Timespec {
+		var st syscall.Stat_t
+		require.NoError(t, syscall.Stat(p, &st))
+		return [3]syscall.Timespec{st.Atim, st.Mtim, st.Ctim}
+	}
+	scan := func(readOnly bool) {
+		r := NewRoot(dir)
+		r.Hashes = []Hasher{SHA256}
+		r.ReadOnly = readOnly
+		_, err := r.Run()
+		require.NoError(t, err)
+	}
+
+	reset()
+	before := times(file)
+	scan(false)
+	if times(file) == before {
+		t.Skip("reading does not change access times here")
+	}
+
+	reset()
+	before, dirBefore := times(file), times(filepath.Dir(file))
+	scan(true)
+	assert.Equal(t, before, times(file))
+	assert.Equal(t, dirBefore, times(filepath.Dir(file)))
+}
@@ -0,0 +1,132 @@
+package ctree
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// OpWrite is an attempt to write to a file of a RecordingFS
+const OpWrite = "write"
+
+// FSOp is an operation on a RecordingFS
+type FSOp struct {
+	Op   string
+	Name string
+}
+
+// RecordingFS wraps an fs.FS, recording every operation on it, so tests
+// can check what code does to a filesystem: OpOpen, OpReadDir, and
+// OpStat, for Stat and for the Info of directory entries. The files it
+// opens have Write and WriteAt methods, which record OpWrite and fail,
+// so that code trying to write to them is caught. It is safe for
+// concurrent use.
+type RecordingFS struct {
+	FS fs.FS
+
+	mu  sync.Mutex
+	ops []FSOp
+}
+
+var (
+	_ fs.ReadDirFS = &RecordingFS{}
+	_ fs.StatFS    = &RecordingFS{}
+)
+
+// Ops returns the operations recorded, in order
+func (rfs *RecordingFS) Ops() []FSOp {
+	rfs.mu.Lock()
+	defer rfs.mu.Unlock()
+	return append([]FSOp(nil), rfs.ops...)
+}
+
+func (rfs *RecordingFS) record(op, name string) {
+	rfs.mu.Lock()
+	rfs.ops = append(rfs.ops, FSOp{Op: op, Name: name})
+	rfs.mu.Unlock()
+}
+
+// Open opens the named file
+func (rfs *RecordingFS) Open(name string) (fs.File, error) {
+	rfs.record(OpOpen, name)
+	f, err := rfs.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedFile{File: f, rfs: rfs, name: name}, nil
+}
+
+// ReadDir reads the named directory
+func (rfs *RecordingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	rfs.record(OpReadDir, name)
+	entries, err := fs.ReadDir(rfs.FS, name)
+	for i, entry := range entries {
+		entries[i] = &recordedEntry{
+			DirEntry: entry,
+			rfs:      rfs,
+			name:     path.Join(name, entry.Name()),
+		}
+	}
+	return entries, err
+}
+
+// Stat returns a FileInfo describing the named file
+func (rfs *RecordingFS) Stat(name string) (fs.FileInfo, error) {
+	rfs.record(OpStat, name)
+	return fs.Stat(rfs.FS, name)
+}
+
+// recordedEntry is a directory entry whose Info is recorded
+type recordedEntry struct {
+	fs.DirEntry
+	rfs  *RecordingFS
+	name string
+}
+
+func (re *recordedEntry) Info() (fs.FileInfo, error) {
+	re.rfs.record(OpStat, re.name)
+	return re.DirEntry.Info()
+}
+
+// errNotWritable is returned by the files of a RecordingFS when written
+var errNotWritable = errors.New("recording filesystem is read-only")
+
+// recordedFile is a file of a RecordingFS, which may be read as the
+// underlying file allows, but not written
+type recordedFile struct {
+	fs.File
+	rfs  *RecordingFS
+	name string
+}
+
+func (f *recordedFile) Write([]byte) (int, error) {
+	f.rfs.record(OpWrite, f.name)
+	return 0, &fs.PathError{Op: OpWrite, Path: f.name, Err: errNotWritable}
+}
+
+func (f *recordedFile) WriteAt([]byte, int64) (int, error) {
+	return f.Write(nil)
+}
+
+func (f *recordedFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *recordedFile) ReadAt(p []byte, off int64) (int, error) {
+	if ra, ok := f.File.(io.ReaderAt); ok {
+		return ra.ReadAt(p, off)
+	}
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *recordedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,174 @@
+package ctree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// RemoveOptions controls RemoveTree
+type RemoveOptions struct {
+	// Threads is how many directories may be emptied at once;
+	// DefaultThreads if zero
+	Threads int
+
+	// Trash, if set, moves what would be deleted to the user's trash
+	// instead: the freedesktop.org trash, with a trash info file saying
+	// where it came from, or ~/.Trash on macOS. Elsewhere, RemoveTree
+	// fails with ErrNoTrash. What is moved keeps its place beneath the
+	// node being removed, and must be on the same filesystem as the
+	// trash.
+	Trash bool
+	// Quarantine, if set, is a directory to move what would be deleted
+	// to instead, as Trash does
+	Quarantine string
+	// Manifest, if set, is written a description in JSON of where
+	// everything moved to the trash or quarantine went, with which
+	// Restore puts it back
+	Manifest io.Writer
+}
+
+// RemoveTree deletes n, and if it is a directory, everything beneath it,
+// emptying directories in parallel, or moves them to the trash; see
+// RemoveOptions. Only what the scan saw is deleted: a directory which
+// has gained entries since is left in place, with an error. Removed
+// nodes are detached from the tree, so what remains describes what is
+// left on disk. All errors are returned, joined.
+func RemoveTree(n Node, opts *RemoveOptions) error {
+	if opts == nil {
+		opts = &RemoveOptions{}
+	}
+	threads := opts.Threads
+	if threads <= 0 {
+		threads = DefaultThreads
+	}
+
+	rm := &remover{sem: make(chan struct{}, threads-1)}
+	if opts.Trash || opts.Quarantine != "" {
+		t, err := newTrash(n, opts)
+		if err != nil {
+			return err
+		}
+		rm.trash = t
+	}
+
+	var removed bool
+	var parent *DNode
+	switch n := n.(type) {
+	case *DNode:
+		removed, parent = rm.dir(n), n.parent
+	case *Leaf:
+		removed, parent = rm.remove(n), n.parent
+	}
+
+	if removed && parent != nil {
+		parent.detach(n)
+	}
+
+	if rm.trash != nil {
+		if err := rm.trash.finish(opts.Manifest); err != nil {
+			rm.errs = append(rm.errs, fmt.Errorf("trash manifest: %w", err))
+		}
+	}
+
+	return errors.Join(rm.errs...)
+}
+
+type remover struct {
+	sem   chan struct{} // a place for each extra goroutine
+	trash *trash        // where to move what is removed, if anywhere
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// remove deletes n, a leaf or an emptied directory, or moves it to the
+// trash, recording any error, and reports whether it is gone
+func (rm *remover) remove(n Node) bool {
+	var err error
+	switch {
+	case rm.trash == nil:
+		err = os.Remove(n.Path())
+	case (*n.Info()).IsDir():
+		err = rm.trash.dir(n.Path(), (*n.Info()).Mode())
+	default:
+		err = rm.trash.leaf(n.Path(), (*n.Info()).Mode())
+	}
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+
+	rm.mu.Lock()
+	rm.errs = append(rm.errs, err)
+	rm.mu.Unlock()
+
+	return false
+}
+
+// dir empties and deletes dn, keeping in it whatever could not be deleted
+func (rm *remover) dir(dn *DNode) bool {
+	var wg sync.WaitGroup
+	gone := make([]bool, len(dn.children))
+
+	for i, child := range dn.children {
+		select {
+		case rm.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int, child *DNode) {
+				defer wg.Done()
+				gone[i] = rm.dir(child)
+				<-rm.sem
+			}(i, child)
+		default:
+			gone[i] = rm.dir(child)
+		}
+	}
+
+	leaves := dn.leaves[:0]
+	for _, leaf := range dn.leaves {
+		if !rm.remove(leaf) {
+			leaves = append(leaves, leaf)
+		}
+	}
+	dn.leaves = leaves
+
+	wg.Wait()
+
+	children := dn.children[:0]
+	for i, child := range dn.children {
+		if !gone[i] {
+			children = append(children, child)
+		}
+	}
+	dn.children = children
+	dn.invalidate()
+
+	if len(dn.children) > 0 || len(dn.leaves) > 0 {
+		return false
+	}
+	return rm.remove(dn)
+}
+
+// detach removes n from the entries of dn
+func (dn *DNode) detach(n Node) {
+	defer dn.invalidate()
+	switch n := n.(type) {
+	case *DNode:
+		for i, child := range dn.children {
+			if child == n {
+				dn.children = append(dn.children[:i], dn.children[i+1:]...)
+				break
+			}
+		}
+	case *Leaf:
+		for i, leaf := range dn.leaves {
+			if leaf == n {
+				dn.leaves = append(dn.leaves[:i], dn.leaves[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveTree(t *testing.T) {
+	t.Run("a directory", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		require.NoError(RemoveTree(dn.Lookup("ceswift"), &RemoveOptions{Threads: 8}))
+
+		assert.NoDirExists(path.Join(dn.Path(), "ceswift"))
+		assert.FileExists(path.Join(dn.Path(), "wsfitzpa/bin/zrun"))
+		assert.Nil(dn.Lookup("ceswift"))
+		assert.Equal(5, dn.TotalLength())
+	})
+
+	t.Run("a leaf", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		require.NoError(RemoveTree(dn.Lookup("wsfitzpa/.cshrc"), nil))
+
+		assert.NoFileExists(path.Join(dn.Path(), "wsfitzpa/.cshrc"))
+		assert.Nil(dn.Lookup("wsfitzpa/.cshrc"))
+		assert.Equal(8, dn.TotalLength())
+	})
+
+	t.Run("everything", func(t *testing.T) {
+		dn := scanCopy(t, nil)
+		require.NoError(t, RemoveTree(dn, &RemoveOptions{Threads: 1}))
+		assert.NoDirExists(t, dn.Path())
+	})
+
+	t.Run("only what was scanned", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		extra := path.Join(dn.Path(), "ceswift/bin/new")
+		writeFile(t, extra, "new")
+
+		err := RemoveTree(dn.Lookup("ceswift"), nil)
+		assert.Error(err)
+
+		assert.FileExists(extra)
+		assert.NoFileExists(path.Join(dn.Path(), "ceswift/bin/worms"))
+		assert.NoFileExists(path.Join(dn.Path(), "ceswift/.cshrc"))
+
+		// the tree keeps the directories which could not be removed
+		require.NotNil(dn.Lookup("ceswift/bin"))
+		assert.Nil(dn.Lookup("ceswift/bin/worms"))
+		assert.Nil(dn.Lookup("ceswift/.cshrc"))
+	})
+
+	t.Run("already gone", func(t *testing.T) {
+		dn := scanCopy(t, nil)
+		require.NoError(t, os.RemoveAll(path.Join(dn.Path(), "ceswift")))
+		assert.NoError(t, RemoveTree(dn.Lookup("ceswift"), nil))
+		assert.Nil(t, dn.Lookup("ceswift"))
+	})
+}
@@ -0,0 +1,207 @@
+package ctree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RenameKey returns a key for a node which is the same in two trees when
+// the node is the same file, wherever it is, or false if the node can't
+// be told apart from others that way; see DiffRenames
+type RenameKey func(n Node) (key string, ok bool)
+
+// ByInode identifies nodes by their device and inode, which survive being
+// renamed or moved within a filesystem, and the inode's generation, where
+// it is known. It only makes sense between scans of the same filesystem.
+func ByInode(n Node) (string, bool) {
+	si := statOf(*n.Info())
+	if !si.hasDev {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d:%d", si.dev, si.ino, si.gen), true
+}
+
+// BySizeAndModTime identifies leaves by their size and modification time,
+// which are usually kept when files are moved. Empty files are not
+// identified, since there are too many alike.
+func BySizeAndModTime(n Node) (string, bool) {
+	fi := *n.Info()
+	if fi.IsDir() || fi.Size() == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d@%d", fi.Size(), fi.ModTime().UnixNano()), true
+}
+
+// ByHash identifies leaves by their contents, with the digest of the
+// given name; see Root.Hashes. Empty files are not identified, since
+// there are too many alike.
+func ByHash(name string) RenameKey {
+	return func(n Node) (string, bool) {
+		l, ok := n.(*Leaf)
+		if !ok || (*l.info).Size() == 0 {
+			return "", false
+		}
+		sum := l.Hash(name)
+		if sum == nil {
+			return "", false
+		}
+		return string(sum), true
+	}
+}
+
+// DiffRenames is like DiffFunc, but finds nodes which were renamed or
+// moved: those removed from old whose key is that of one added to cur.
+// Each is one change of kind Renamed, at its new Path, with its OldPath.
+// A directory is identified by its key if it has one, and otherwise by
+// its contents, if they are all identified; a renamed directory's changes
+// are just those within it, so that moving a large directory is one
+// change rather than thousands. When several added nodes could be the one
+// removed, one of the same name is preferred.
+func DiffRenames(old, cur *DNode, same Comparer, key RenameKey) []Change {
+	changes := DiffFunc(old, cur, same)
+
+	keys := map[Node]string{}
+	var keyOf func(n Node) (string, bool)
+	keyOf = func(n Node) (string, bool) {
+		if k, ok := keys[n]; ok {
+			return k, k != ""
+		}
+		k, ok := key(n)
+		if dn, isDir := n.(*DNode); isDir && !ok {
+			k, ok = contentKey(dn, keyOf)
+		}
+		if !ok {
+			k = ""
+		}
+		keys[n] = k
+		return k, ok
+	}
+
+	// the added nodes of each key, by path
+	added := map[string][]int{}
+	for i, c := range changes {
+		if c.Kind != Added {
+			continue
+		}
+		if k, ok := keyOf(c.New); ok {
+			added[k] = append(added[k], i)
+		}
+	}
+
+	taken := make([]bool, len(changes))
+	// within are the paths of renamed directories, old and new, whose
+	// changes are replaced
+	var within []string
+	inside := func(p string) bool {
+		for _, dir := range within {
+			if strings.HasPrefix(p, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// pick finds the added node to pair with the removed one, preferring
+	// one of the same name
+	pick := func(removed Change) (int, bool) {
+		want, ok := keyOf(removed.Old)
+		if !ok {
+			return 0, false
+		}
+		found := -1
+		for _, j := range added[want] {
+			a := changes[j]
+			if taken[j] || inside(a.Path) || !SameType(removed.Old, a.New) {
+				continue
+			}
+			if nodeName(a.New) == nodeName(removed.Old) {
+				return j, true
+			}
+			if found < 0 {
+				found = j
+			}
+		}
+		return found, found >= 0
+	}
+
+	// directories first, so that their contents are not taken apart by
+	// the renames of leaves, and parents before their children
+	var renames []Change
+	for _, dirs := range []bool{true, false} {
+		for i, c := range changes {
+			_, isDir := c.Old.(*DNode)
+			if c.Kind != Removed || isDir != dirs || taken[i] || inside(c.Path) {
+				continue
+			}
+			j, ok := pick(c)
+			if !ok {
+				continue
+			}
+
+			taken[i], taken[j] = true, true
+			a := changes[j]
+			renames = append(renames, Change{Path: a.Path, OldPath: c.Path, Kind: Renamed, Old: c.Old, New: a.New})
+
+			if isDir {
+				within = append(within, c.Path, a.Path)
+				for _, inner := range DiffRenames(c.Old.(*DNode), a.New.(*DNode), same, key) {
+					renames = append(renames, inner.moved(c.Path, a.Path))
+				}
+			}
+		}
+	}
+
+	result := renames
+	for i, c := range changes {
+		if !taken[i] && !inside(c.Path) {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result
+}
+
+// moved returns the change, found within a directory renamed from oldDir
+// to newDir, with its paths in the trees being compared
+func (c Change) moved(oldDir, newDir string) Change {
+	oldPath := c.Path
+	if c.OldPath != "" {
+		oldPath = c.OldPath
+	}
+
+	if c.Kind == Removed {
+		c.Path = path.Join(oldDir, c.Path)
+		return c
+	}
+	c.Path = path.Join(newDir, c.Path)
+	if c.Kind != Added {
+		c.OldPath = path.Join(oldDir, oldPath)
+	}
+	return c
+}
+
+// contentKey identifies a directory by the names and keys of its entries,
+// if it has any, and all of them are identified
+func contentKey(dn *DNode, keyOf func(Node) (string, bool)) (string, bool) {
+	entries := sortedEntries(dn)
+	if len(entries) == 0 {
+		return "", false
+	}
+
+	h := sha256.New()
+	for _, n := range entries {
+		key, ok := keyOf(n)
+		if !ok {
+			return "", false
+		}
+		fmt.Fprintf(h, "%q %q\n", nodeName(n), key)
+	}
+	return "dir:" + hex.EncodeToString(h.Sum(nil)), true
+}
@@ -0,0 +1,105 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRenames(t *testing.T) {
+	// summary is the kinds and paths of the changes
+	summary := func(changes []Change) []string {
+		var s []string
+		for _, c := range changes {
+			line := c.Kind.String() + " " + c.Path
+			if c.OldPath != "" {
+				line += " from " + c.OldPath
+			}
+			s = append(s, line)
+		}
+		return s
+	}
+
+	t.Run("by inode", func(t *testing.T) {
+		require := require.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		home := path.Join(where, "home")
+		old, err := NewRoot(home).Run()
+		require.NoError(err)
+
+		require.NoError(os.Mkdir(path.Join(home, "moved"), 0o777))
+		require.NoError(os.Rename(path.Join(home, "ceswift"), path.Join(home, "moved", "cs")))
+		writeFile(t, path.Join(home, "moved", "cs", ".cshrc"), "echo moved")
+		require.NoError(os.Rename(path.Join(home, "wsfitzpa", "bin", "zrun"), path.Join(home, "wsfitzpa", "zrun")))
+		cur, err := NewRoot(home).Run()
+		require.NoError(err)
+
+		assert.Equal(t, []string{
+			"added moved",
+			"renamed moved/cs from ceswift",
+			"modified moved/cs/.cshrc from ceswift/.cshrc",
+			"renamed wsfitzpa/zrun from wsfitzpa/bin/zrun",
+		}, summary(DiffRenames(old, cur, DefaultComparer, ByInode)))
+		assert.Len(t, Diff(old, cur), 11, "without renames")
+	})
+
+	scan := func(mfs fstest.MapFS) *DNode {
+		r := NewRoot(".")
+		r.FS = mfs
+		r.Hashes = []Hasher{SHA256}
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	t.Run("by contents", func(t *testing.T) {
+		old := scan(fstest.MapFS{
+			"a/x":     {Data: []byte("hello")},
+			"a/sub/y": {Data: []byte("world")},
+			"b":       {Data: []byte("bye")},
+			"empty":   {},
+			"kept":    {Data: []byte("kept")},
+		})
+		cur := scan(fstest.MapFS{
+			"c/x":     {Data: []byte("hello")},
+			"c/sub/y": {Data: []byte("world")},
+			"d/b":     {Data: []byte("bye")},
+			"void":    {},
+			"kept":    {Data: []byte("kept")},
+		})
+
+		for _, key := range []RenameKey{ByHash(SHA256.Name), BySizeAndModTime} {
+			changes := DiffRenames(old, cur, DefaultComparer, key)
+			assert.Equal(t, []string{
+				"renamed c from a",
+				"added d",
+				"renamed d/b from b",
+				"removed empty",
+				"added void",
+			}, summary(changes))
+			assert.Equal(t, Renamed, changes[0].Kind)
+			assert.Same(t, old.Lookup("a"), changes[0].Old)
+			assert.Same(t, cur.Lookup("c"), changes[0].New)
+		}
+	})
+
+	t.Run("the same name is preferred", func(t *testing.T) {
+		old := scan(fstest.MapFS{"data": {Data: []byte("x")}})
+		cur := scan(fstest.MapFS{
+			"other":    {Data: []byte("x")},
+			"sub/data": {Data: []byte("x")},
+		})
+
+		assert.Equal(t, []string{
+			"added other",
+			"added sub",
+			"renamed sub/data from data",
+		}, summary(DiffRenames(old, cur, DefaultComparer, ByHash(SHA256.Name))))
+	})
+}
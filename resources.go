@@ -0,0 +1,126 @@
+package ctree
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceUsage is what a Run of a Root cost, for capacity planning
+type ResourceUsage struct {
+	// Wall is how long the Run took
+	Wall time.Duration
+	// User and System are the CPU time the process spent during the
+	// Run, in user and kernel mode; zero where the platform cannot tell
+	User, System time.Duration
+	// PeakRSS estimates, in bytes, the most memory the Run needed: it is
+	// the largest resident set size of the whole process so far, so it
+	// may include what the process did before; zero where the platform
+	// cannot tell
+	PeakRSS int64
+	// Syscalls is how many read and write system calls the process made
+	// during the Run; zero where the platform does not count them
+	Syscalls int64
+
+	// BytesRead is how much of the contents of files the Run read
+	BytesRead int64
+	// Opens, ReadDirs, and Stats count how many files the Run opened,
+	// directories it read, and files it looked up, on any platform
+	Opens    int64
+	ReadDirs int64
+	Stats    int64
+}
+
+// CPU is the total CPU time of the Run
+func (u ResourceUsage) CPU() time.Duration {
+	return u.User + u.System
+}
+
+// usageCounters count what a Run does, while it runs
+type usageCounters struct {
+	bytesRead, opens, readDirs, stats int64
+}
+
+// processUsage is what the operating system says the process has used
+type processUsage struct {
+	user, system time.Duration
+	peakRSS      int64
+	syscalls     int64
+}
+
+// ResourceUsage returns what the most recent Run cost; it is zero until a
+// Run has finished
+func (r *Root) ResourceUsage() ResourceUsage {
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+	return r.usage
+}
+
+// startUsage zeroes the counts, and returns the usage of the process so
+// far, for a new Run
+func (r *Root) startUsage() processUsage {
+	for _, n := range []*int64{
+		&r.counters.bytesRead, &r.counters.opens,
+		&r.counters.readDirs, &r.counters.stats,
+	} {
+		atomic.StoreInt64(n, 0)
+	}
+	return usageOfProcess()
+}
+
+// endUsage records the ResourceUsage of a Run which started at start,
+// when the process had used before
+func (r *Root) endUsage(start time.Time, before processUsage) {
+	after := usageOfProcess()
+
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+	r.usage = ResourceUsage{
+		Wall:      time.Since(start),
+		User:      after.user - before.user,
+		System:    after.system - before.system,
+		PeakRSS:   after.peakRSS,
+		Syscalls:  after.syscalls - before.syscalls,
+		BytesRead: atomic.LoadInt64(&r.counters.bytesRead),
+		Opens:     atomic.LoadInt64(&r.counters.opens),
+		ReadDirs:  atomic.LoadInt64(&r.counters.readDirs),
+		Stats:     atomic.LoadInt64(&r.counters.stats),
+	}
+}
+
+// counted returns a reader of rd which counts what is read in the Root's
+// ResourceUsage, and which can Seek if rd can
+func (r *Root) counted(rd io.Reader) io.Reader {
+	cr := countingReader{rd, &r.counters.bytesRead}
+	if s, ok := rd.(io.Seeker); ok {
+		return countingReadSeeker{cr, s}
+	}
+	return cr
+}
+
+type countingReader struct {
+	io.Reader
+	n *int64
+}
+
+func (cr countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	atomic.AddInt64(cr.n, int64(n))
+	return n, err
+}
+
+type countingReadSeeker struct {
+	countingReader
+	io.Seeker
+}
+
+type countingWriter struct {
+	io.Writer
+	n *int64
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.Writer.Write(p)
+	atomic.AddInt64(cw.n, int64(n))
+	return n, err
+}
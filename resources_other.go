@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ctree
+
+// usageOfProcess knows nothing where there is no getrusage
+func usageOfProcess() processUsage {
+	return processUsage{}
+}
@@ -0,0 +1,79 @@
+package ctree
+
+import (
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceUsage(t *testing.T) {
+	t.Run("counts what the run reads", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		assert.Zero(r.ResourceUsage())
+
+		r.Hashes = []Hasher{SHA256}
+		_, err := r.Run()
+		require.NoError(err)
+
+		usage := r.ResourceUsage()
+		assert.Equal(int64(62), usage.BytesRead)
+		assert.Equal(int64(4), usage.Opens)
+		assert.Equal(int64(5), usage.ReadDirs)
+		assert.Equal(int64(9), usage.Stats)
+		assert.Positive(usage.Wall)
+
+		r.Hashes = nil
+		_, err = r.Run()
+		require.NoError(err)
+		usage = r.ResourceUsage()
+		assert.Zero(usage.BytesRead, "each run counts afresh")
+		assert.Zero(usage.Opens)
+		assert.Equal(int64(5), usage.ReadDirs)
+	})
+
+	t.Run("processors and classifiers are counted", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = tfs
+		r.Processors = []Processor{&SecretScanner{}}
+		_, err := r.Run()
+		require.NoError(t, err)
+		assert.Equal(t, int64(62), r.ResourceUsage().BytesRead)
+
+		r.Processors = nil
+		RegisterClassifier("test-usage", func(*Leaf, []byte) []string { return nil })
+		r.Classify = []string{"test-usage"}
+		_, err = r.Run()
+		require.NoError(t, err)
+		assert.Equal(t, int64(62), r.ResourceUsage().BytesRead)
+	})
+
+	t.Run("the process", func(t *testing.T) {
+		if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+			t.Skip("no getrusage")
+		}
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(path.Join(where, "home"))
+		r.Hashes = []Hasher{SHA256, BLAKE3}
+		_, err := r.Run()
+		require.NoError(err)
+
+		usage := r.ResourceUsage()
+		assert.Equal(int64(62), usage.BytesRead)
+		assert.Positive(usage.PeakRSS)
+		assert.GreaterOrEqual(usage.CPU(), usage.User)
+		if runtime.GOOS == "linux" {
+			assert.Positive(usage.Syscalls)
+		}
+	})
+}
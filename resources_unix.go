@@ -0,0 +1,31 @@
+//go:build unix
+
+package ctree
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// usageOfProcess asks the operating system what the process has used
+func usageOfProcess() processUsage {
+	var ru unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &ru); err != nil {
+		return processUsage{}
+	}
+
+	// ru_maxrss is in kilobytes, except where it is in bytes
+	rss := int64(ru.Maxrss)
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		rss *= 1024
+	}
+
+	return processUsage{
+		user:     time.Duration(ru.Utime.Nano()),
+		system:   time.Duration(ru.Stime.Nano()),
+		peakRSS:  rss,
+		syscalls: syscallCount(),
+	}
+}
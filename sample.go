@@ -0,0 +1,65 @@
+package ctree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// SampleLeaves returns n leaves from beneath dn, chosen at random, without
+// repeats, such as for spot-checking a backup by restoring a few files.
+// If weightBySize is set, a leaf's chance of being chosen is in
+// proportion to its size, so that the sample covers more of the bytes,
+// and empty leaves are never chosen. Fewer than n are returned if there
+// aren't enough. The sample is sorted by path.
+func SampleLeaves(dn *DNode, n int, weightBySize bool) []*Leaf {
+	return SampleLeavesRand(dn, n, weightBySize, rand.New(rand.NewSource(rand.Int63())))
+}
+
+// SampleLeavesRand is like SampleLeaves, but draws from rng, so that
+// samples can be repeated
+func SampleLeavesRand(dn *DNode, n int, weightBySize bool, rng *rand.Rand) []*Leaf {
+	var leaves []*Leaf
+	dn.walk(func(_ string, node Node) bool {
+		if l, ok := node.(*Leaf); ok && (!weightBySize || (*l.info).Size() > 0) {
+			leaves = append(leaves, l)
+		}
+		return true
+	})
+	sortLeaves(leaves) // the same leaves for the same rng, whatever the walk's order
+
+	if n <= 0 {
+		return nil
+	}
+	if n > len(leaves) {
+		n = len(leaves)
+	}
+
+	if weightBySize {
+		// each leaf gets a key of log(u)/weight, for u uniform in (0, 1],
+		// and those with the largest keys are chosen, as by
+		// Efraimidis and Spirakis
+		keys := make(map[*Leaf]float64, len(leaves))
+		for _, l := range leaves {
+			keys[l] = math.Log(1-rng.Float64()) / float64((*l.info).Size())
+		}
+		sort.SliceStable(leaves, func(i, j int) bool {
+			return keys[leaves[i]] > keys[leaves[j]]
+		})
+	} else {
+		for i := 0; i < n; i++ {
+			j := i + rng.Intn(len(leaves)-i)
+			leaves[i], leaves[j] = leaves[j], leaves[i]
+		}
+	}
+
+	sample := leaves[:n]
+	sortLeaves(sample)
+	return sample
+}
+
+func sortLeaves(leaves []*Leaf) {
+	sort.Slice(leaves, func(i, j int) bool {
+		return leaves[i].path < leaves[j].path
+	})
+}
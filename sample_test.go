@@ -0,0 +1,83 @@
+package ctree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleLeaves(t *testing.T) {
+	mfs := fstest.MapFS{
+		"big":   {Data: make([]byte, 10000)},
+		"empty": {},
+	}
+	for i := 0; i < 20; i++ {
+		mfs[fmt.Sprintf("dir%d/small%d", i%3, i)] = &fstest.MapFile{Data: make([]byte, 10)}
+	}
+
+	r := NewRoot(".")
+	r.FS = mfs
+	dn, err := r.Run()
+	require.NoError(t, err)
+
+	paths := func(leaves []*Leaf) []string {
+		var ps []string
+		for _, l := range leaves {
+			ps = append(ps, l.Path())
+		}
+		return ps
+	}
+
+	t.Run("sizes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Empty(SampleLeaves(dn, 0, false))
+		assert.Len(SampleLeaves(dn, 5, false), 5)
+		assert.Len(SampleLeaves(dn, 100, false), 22)
+		assert.Len(SampleLeaves(dn, 100, true), 21, "empty leaves have no weight")
+
+		sample := paths(SampleLeaves(dn, 10, false))
+		assert.IsIncreasing(sample)
+		seen := map[string]bool{}
+		for _, p := range sample {
+			assert.False(seen[p], p)
+			seen[p] = true
+		}
+	})
+
+	t.Run("repeatable", func(t *testing.T) {
+		a := SampleLeavesRand(dn, 5, true, rand.New(rand.NewSource(1)))
+		b := SampleLeavesRand(dn, 5, true, rand.New(rand.NewSource(1)))
+		assert.Equal(t, paths(a), paths(b))
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(2))
+		big, empty := 0, 0
+		for i := 0; i < 1000; i++ {
+			for _, l := range SampleLeavesRand(dn, 1, true, rng) {
+				switch l.Path() {
+				case "big":
+					big++
+				case "empty":
+					empty++
+				}
+			}
+		}
+		// big holds 10000 of 10200 bytes
+		assert.Greater(t, big, 950)
+		assert.Zero(t, empty)
+
+		big = 0
+		for i := 0; i < 1000; i++ {
+			if paths(SampleLeavesRand(dn, 1, false, rng))[0] == "big" {
+				big++
+			}
+		}
+		assert.Less(t, big, 150, "1 in 22 without weights")
+	})
+}
@@ -0,0 +1,58 @@
+package ctree
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SaveSnapshotAtomic writes dn to the file name as WriteSnapshot does,
+// compressed with gzip if name ends in ".gz", or zstd if in ".zst". The
+// snapshot is written to a temporary file beside name, synced, and
+// renamed over it, so a crash leaves either the old file or the new one,
+// never part of one.
+func SaveSnapshotAtomic(name string, dn *DNode) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	var w io.Writer = f
+	var zw io.WriteCloser
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		zw = gzip.NewWriter(f)
+		w = zw
+	case strings.HasSuffix(name, ".zst"):
+		zw = NewZstdWriter(f)
+		w = zw
+	}
+
+	if err := WriteSnapshot(w, dn); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
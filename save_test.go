@@ -0,0 +1,57 @@
+package ctree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveSnapshotAtomic(t *testing.T) {
+	load := func(t *testing.T, name string) *DNode {
+		f, err := os.Open(name)
+		require.NoError(t, err)
+		defer f.Close()
+
+		dn, err := ReadSnapshot(f)
+		require.NoError(t, err)
+		return dn
+	}
+
+	for _, name := range []string{"snap.json", "snap.json.gz", "snap.json.zst"} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			dn := scanCopy(t, nil)
+			dir := t.TempDir()
+			name := filepath.Join(dir, name)
+
+			require.NoError(os.WriteFile(name, []byte("old"), 0o644))
+			require.NoError(SaveSnapshotAtomic(name, dn))
+			assert.Empty(Diff(dn, load(t, name)))
+
+			fi, err := os.Stat(name)
+			require.NoError(err)
+			assert.Equal(os.FileMode(0o644), fi.Mode().Perm())
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(err)
+			assert.Len(entries, 1, "temporary files are left")
+		})
+	}
+
+	t.Run("failures leave nothing behind", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "snap.json")
+		require.NoError(t, os.Mkdir(name, 0o755))
+
+		assert.Error(t, SaveSnapshotAtomic(name, scanCopy(t, nil)))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -0,0 +1,81 @@
+package ctree
+
+import (
+	"os"
+	"os/user"
+	"runtime/debug"
+	"time"
+)
+
+// modulePath is used to find the version of ctree in the build info
+const modulePath = "github.com/samf/ctree"
+
+// ScanInfo records where, when, and how a tree was scanned, so that saved
+// snapshots can be audited later
+type ScanInfo struct {
+	Hostname string      `json:"hostname"`
+	Root     string      `json:"root"`
+	Start    time.Time   `json:"start"`
+	End      time.Time   `json:"end"`
+	Options  ScanOptions `json:"options"`
+	Version  string      `json:"version"`
+	User     string      `json:"user"`
+
+	// LimitReached is set if the walk stopped at Root.MaxNodes or
+	// Root.MaxBytes, so the tree is not all there
+	LimitReached bool `json:"limit_reached,omitempty"`
+}
+
+// ScanOptions records the settings of the Root that performed a scan
+type ScanOptions struct {
+	Threads      int      `json:"threads"`
+	WorkListSize int      `json:"work_list_size"`
+	Hashes       []string `json:"hashes,omitempty"`
+}
+
+// newScanInfo starts the ScanInfo for a run of r
+func newScanInfo(r *Root) *ScanInfo {
+	si := &ScanInfo{
+		Root:  r.root,
+		Start: time.Now(),
+		Options: ScanOptions{
+			Threads:      r.Threads,
+			WorkListSize: r.WorkListSize,
+		},
+		Version: Version(),
+	}
+
+	for _, h := range r.Hashes {
+		si.Options.Hashes = append(si.Options.Hashes, h.Name)
+	}
+
+	si.Hostname, _ = os.Hostname()
+
+	if u, err := user.Current(); err == nil {
+		si.User = u.Username
+	} else {
+		si.User = os.Getenv("USER")
+	}
+
+	return si
+}
+
+// Version returns the version of ctree built into the running program, or
+// "(devel)" if it cannot be determined
+func Version() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	if bi.Main.Path == modulePath && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+
+	return "(devel)"
+}
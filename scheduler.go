@@ -0,0 +1,311 @@
+package ctree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeep is how many snapshots a Job keeps by default
+const DefaultKeep = 7
+
+// snapshotTimeFormat names saved snapshots so they sort by time
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+// Job is a scan that a Scheduler runs repeatedly
+type Job struct {
+	// Name identifies the job, and prefixes its snapshot files
+	Name     string
+	Root     *Root
+	Schedule Schedule
+	// Keep is how many snapshot files to keep; DefaultKeep if zero
+	Keep int
+}
+
+// jobState is what a Scheduler knows about a Job
+type jobState struct {
+	Job
+
+	next     time.Time
+	latest   *DNode
+	previous *DNode
+	err      error
+}
+
+// Scheduler runs scans on their schedules, keeping the latest results in
+// memory and, if Dir is set, saving each result as a snapshot there
+type Scheduler struct {
+	// Dir, if set, is where snapshots are saved, as
+	// <job name>-<UTC time>.json
+	Dir string
+
+	mu   sync.Mutex
+	jobs map[string]*jobState
+	wake chan struct{} // tells Run that a job was added
+}
+
+// NewScheduler creates a Scheduler which saves snapshots to dir, unless
+// dir is empty
+func NewScheduler(dir string) *Scheduler {
+	return &Scheduler{
+		Dir:  dir,
+		jobs: map[string]*jobState{},
+	}
+}
+
+// Add adds a job to the scheduler, which runs it when it is next due,
+// even if the scheduler is already running
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || strings.ContainsAny(job.Name, `/\`) {
+		return fmt.Errorf("%q: bad job name", job.Name)
+	}
+	if job.Root == nil || job.Schedule == nil {
+		return fmt.Errorf("%q: job needs a Root and a Schedule", job.Name)
+	}
+	if job.Keep <= 0 {
+		job.Keep = DefaultKeep
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = map[string]*jobState{}
+	}
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%q: duplicate job name", job.Name)
+	}
+	s.jobs[job.Name] = &jobState{Job: job, next: job.Schedule.Next(time.Now())}
+
+	select {
+	case s.wakeup() <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// wakeup returns the channel that tells Run that a job was added. The
+// caller must hold mu.
+func (s *Scheduler) wakeup() chan struct{} {
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+	return s.wake
+}
+
+// Run runs jobs as they come due, until ctx is done. Jobs run one at a
+// time, so a Root shared between jobs is never walked twice at once.
+// Errors from jobs do not stop the scheduler; see LastError.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	now := time.Now()
+	for _, js := range s.jobs {
+		js.next = js.Schedule.Next(now)
+	}
+	added := s.wakeup()
+	s.mu.Unlock()
+
+	for {
+		wake, ok := s.soonest()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-added:
+				continue
+			}
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-added:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		for _, name := range s.due(time.Now()) {
+			s.RunJob(name)
+		}
+	}
+}
+
+// soonest returns when the next job is due
+func (s *Scheduler) soonest() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var wake time.Time
+	for _, js := range s.jobs {
+		if js.next.IsZero() {
+			continue
+		}
+		if wake.IsZero() || js.next.Before(wake) {
+			wake = js.next
+		}
+	}
+
+	return wake, !wake.IsZero()
+}
+
+// due returns the names of the jobs due at now, in name order, and
+// schedules their next runs
+func (s *Scheduler) due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{}
+	for name, js := range s.jobs {
+		if !js.next.IsZero() && !js.next.After(now) {
+			names = append(names, name)
+			js.next = js.Schedule.Next(now)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RunJob runs the named job now, saving its snapshot and pruning old
+// ones if Dir is set
+func (s *Scheduler) RunJob(name string) error {
+	js, err := s.job(name)
+	if err != nil {
+		return err
+	}
+
+	dn, err := js.Root.Run()
+	if err == nil && s.Dir != "" {
+		err = s.save(js, dn)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js.err = err
+	if dn != nil {
+		js.previous, js.latest = js.latest, dn
+	}
+
+	return err
+}
+
+// Latest returns the result of the most recent run of the named job, or
+// nil if it has not run
+func (s *Scheduler) Latest(name string) *DNode {
+	js, err := s.job(name)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return js.latest
+}
+
+// Changes returns the differences between the two most recent runs of
+// the named job, or nil if it has not run twice
+func (s *Scheduler) Changes(name string) []Change {
+	js, err := s.job(name)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	previous, latest := js.previous, js.latest
+	s.mu.Unlock()
+
+	if previous == nil || latest == nil {
+		return nil
+	}
+	return Diff(previous, latest)
+}
+
+// LastError returns the error from the most recent run of the named job
+func (s *Scheduler) LastError(name string) error {
+	js, err := s.job(name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return js.err
+}
+
+// Snapshots returns the paths of the saved snapshots of the named job,
+// oldest first
+func (s *Scheduler) Snapshots(name string) ([]string, error) {
+	if s.Dir == "" {
+		return nil, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(s.Dir, name+"-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the snapshots of other jobs whose names start with this one's
+	snaps := []string{}
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), name+"-"), ".json")
+		if _, err := time.Parse(snapshotTimeFormat, stamp); err == nil {
+			snaps = append(snaps, m)
+		}
+	}
+	sort.Strings(snaps)
+
+	return snaps, nil
+}
+
+// ErrNoJob is returned when a Scheduler is asked about a job it lacks
+var ErrNoJob = errors.New("no such job")
+
+func (s *Scheduler) job(name string) (*jobState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrNoJob)
+	}
+	return js, nil
+}
+
+// save writes dn to Dir, then removes all but the newest Keep snapshots
+func (s *Scheduler) save(js *jobState, dn *DNode) error {
+	when := time.Now().UTC()
+	if si := dn.ScanInfo(); si != nil {
+		when = si.Start.UTC()
+	}
+	name := fmt.Sprintf("%s-%s.json", js.Name, when.Format(snapshotTimeFormat))
+
+	if err := SaveSnapshotAtomic(filepath.Join(s.Dir, name), dn); err != nil {
+		return err
+	}
+
+	snaps, err := s.Snapshots(js.Name)
+	if err != nil {
+		return err
+	}
+	for len(snaps) > js.Keep {
+		if err := os.Remove(snaps[0]); err != nil {
+			return err
+		}
+		snaps = snaps[1:]
+	}
+
+	return nil
+}
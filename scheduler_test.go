@@ -0,0 +1,161 @@
+package ctree
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		require.NoError(t, err)
+		return tm
+	}
+
+	tests := []struct {
+		spec  string
+		after string
+		next  string
+	}{
+		{"* * * * *", "2024-03-01 10:00", "2024-03-01 10:01"},
+		{"*/15 * * * *", "2024-03-01 10:01", "2024-03-01 10:15"},
+		{"30 2 * * *", "2024-03-01 10:00", "2024-03-02 02:30"},
+		{"0 0 1 * *", "2024-12-15 00:00", "2025-01-01 00:00"},
+		{"0 9 * * 1-5", "2024-03-01 10:00", "2024-03-04 09:00"}, // friday
+		{"0 0 * * 7", "2024-03-01 10:00", "2024-03-03 00:00"},
+		{"0 0 13 * 5", "2024-03-02 00:00", "2024-03-08 00:00"}, // either matches
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"@hourly", "2024-03-01 10:59", "2024-03-01 11:00"},
+		{"0,30 8-9 * * *", "2024-03-01 08:30", "2024-03-01 09:00"},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.spec)
+		require.NoError(t, err, test.spec)
+		assert.Equal(t, at(test.next), c.Next(at(test.after)), test.spec)
+	}
+
+	never, err := ParseCron("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(at("2024-01-01 00:00")).IsZero())
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := ParseCron(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 17, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC), Every(time.Hour).Next(start))
+	assert.True(t, Every(0).Next(start).IsZero())
+}
+
+func TestScheduler(t *testing.T) {
+	where := t.TempDir()
+	ttree.build(t, where)
+
+	t.Run("bad jobs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := NewScheduler("")
+		assert.Error(s.Add(Job{Name: "a/b", Root: NewRoot(where), Schedule: Every(time.Hour)}))
+		assert.Error(s.Add(Job{Name: "a"}))
+		assert.NoError(s.Add(Job{Name: "a", Root: NewRoot(where), Schedule: Every(time.Hour)}))
+		assert.Error(s.Add(Job{Name: "a", Root: NewRoot(where), Schedule: Every(time.Hour)}))
+		assert.ErrorIs(s.RunJob("b"), ErrNoJob)
+	})
+
+	t.Run("results are kept", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		s := NewScheduler(dir)
+		require.NoError(s.Add(Job{Name: "home", Root: NewRoot(where), Schedule: Every(time.Hour), Keep: 2}))
+		require.NoError(s.Add(Job{Name: "home-other", Root: NewRoot(where), Schedule: Every(time.Hour)}))
+		require.NoError(s.RunJob("home-other"))
+
+		assert.Nil(s.Latest("home"))
+		assert.Nil(s.Changes("home"))
+
+		require.NoError(s.RunJob("home"))
+		assert.NotNil(s.Latest("home"))
+		assert.Nil(s.Changes("home"))
+
+		writeFile(t, path.Join(where, "home", "new"), "new")
+		t.Cleanup(func() { os.Remove(path.Join(where, "home", "new")) })
+		require.NoError(s.RunJob("home"))
+		changes := s.Changes("home")
+		require.Len(changes, 1)
+		assert.Equal("home/new", changes[0].Path)
+
+		require.NoError(s.RunJob("home"))
+		snaps, err := s.Snapshots("home")
+		require.NoError(err)
+		assert.Len(snaps, 2)
+
+		f, err := os.Open(snaps[1])
+		require.NoError(err)
+		defer f.Close()
+		dn, err := ReadSnapshot(f)
+		require.NoError(err)
+		assert.Empty(Diff(s.Latest("home"), dn))
+
+		others, err := s.Snapshots("home-other")
+		require.NoError(err)
+		assert.Len(others, 1)
+	})
+
+	t.Run("errors are recorded", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := NewScheduler("")
+		assert.NoError(s.Add(Job{Name: "gone", Root: NewRoot("/does/not/exist"), Schedule: Every(time.Hour)}))
+		assert.Error(s.RunJob("gone"))
+		assert.Error(s.LastError("gone"))
+	})
+
+	t.Run("jobs run on schedule", func(t *testing.T) {
+		require := require.New(t)
+
+		s := NewScheduler("")
+		require.NoError(s.Add(Job{Name: "fast", Root: NewRoot(where), Schedule: Every(10 * time.Millisecond)}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		go func() {
+			for s.Latest("fast") == nil {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+		}()
+		err := s.Run(ctx)
+		require.ErrorIs(err, context.Canceled)
+		assert.NotNil(t, s.Latest("fast"))
+	})
+
+	t.Run("jobs added while running", func(t *testing.T) {
+		require := require.New(t)
+
+		s := NewScheduler("")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done := make(chan error)
+		go func() { done <- s.Run(ctx) }()
+
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(s.Add(Job{Name: "late", Root: NewRoot(where), Schedule: Every(10 * time.Millisecond)}))
+		for s.Latest("late") == nil && ctx.Err() == nil {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		require.ErrorIs(<-done, context.Canceled)
+		assert.NotNil(t, s.Latest("late"))
+	})
+}
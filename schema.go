@@ -0,0 +1,286 @@
+package ctree
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotSchemaID identifies the JSON Schema of the snapshot format
+const SnapshotSchemaID = "https://github.com/samf/ctree/snapshot.schema.json"
+
+// jsonSchema is the subset of JSON Schema (draft 2020-12) needed to
+// describe snapshots
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *jsonSchema            `json:"items,omitempty"`
+	// Values is additionalProperties, describing the values of a map
+	Values *jsonSchema            `json:"additionalProperties,omitempty"`
+	Defs   map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// SnapshotSchema returns the JSON Schema of the format written by
+// WriteSnapshot. It is generated from the types that WriteSnapshot
+// encodes, so it cannot drift from them; snapshot.schema.json is a copy.
+func SnapshotSchema() []byte {
+	b, err := json.MarshalIndent(snapshotSchema(), "", "  ")
+	if err != nil {
+		panic(err) // the schema is built from fixed types
+	}
+	return append(b, '\n')
+}
+
+func snapshotSchema() *jsonSchema {
+	g := schemaGen{
+		defs: map[string]*jsonSchema{},
+		names: map[reflect.Type]string{
+			reflect.TypeOf(snapNode{}): "node",
+			reflect.TypeOf(ScanInfo{}): "scan",
+		},
+	}
+
+	s := g.schemaOf(reflect.TypeOf(snapshot{}))
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.ID = SnapshotSchemaID
+	s.Title = "ctree snapshot"
+	s.Description = "A directory tree, as written by ctree.WriteSnapshot"
+	s.Defs = g.defs
+
+	return s
+}
+
+// schemaGen builds schemas from Go types the way encoding/json encodes
+// them
+type schemaGen struct {
+	defs  map[string]*jsonSchema
+	names map[reflect.Type]string // types kept in defs, by name
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	fileModeType = reflect.TypeOf(fs.FileMode(0))
+	rawType      = reflect.TypeOf(json.RawMessage(nil))
+	textType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *schemaGen) schemaOf(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if name, ok := g.names[t]; ok {
+		if _, done := g.defs[name]; !done {
+			g.defs[name] = nil // stop recursion
+			g.defs[name] = g.structSchema(t)
+		}
+		return &jsonSchema{Ref: "#/$defs/" + name}
+	}
+
+	switch {
+	case t == timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t == fileModeType:
+		return uintSchema(32)
+	case t == rawType:
+		return &jsonSchema{} // anything
+	case t.Implements(textType):
+		return &jsonSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.structSchema(t)
+	case reflect.Map:
+		return &jsonSchema{Type: "object", Values: g.schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintSchema(t.Bits())
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	}
+
+	return &jsonSchema{}
+}
+
+func uintSchema(bits int) *jsonSchema {
+	min, max := 0.0, math.Exp2(float64(bits))-1
+	return &jsonSchema{Type: "integer", Minimum: &min, Maximum: &max}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{
+		Type:       "object",
+		Properties: map[string]*jsonSchema{},
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+// SchemaError is a place where a document does not match a schema
+type SchemaError struct {
+	// Pointer is the JSON Pointer to the offending value
+	Pointer string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	where := e.Pointer
+	if where == "" {
+		where = "/"
+	}
+	return fmt.Sprintf("%s: %s", where, e.Message)
+}
+
+// ValidateSnapshot checks that r holds a snapshot which matches
+// SnapshotSchema, returning every mismatch found, each as a *SchemaError
+func ValidateSnapshot(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	s := snapshotSchema()
+	v := validator{defs: s.Defs}
+	v.check(s, doc, "")
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	defs map[string]*jsonSchema
+	errs []error
+}
+
+func (v *validator) fail(ptr, format string, args ...any) {
+	v.errs = append(v.errs, &SchemaError{
+		Pointer: ptr,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) check(s *jsonSchema, doc any, ptr string) {
+	if s.Ref != "" {
+		s = v.defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			v.fail(ptr, "want an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				v.fail(ptr, "missing %q", name)
+			}
+		}
+		for name, val := range obj {
+			if ps, ok := s.Properties[name]; ok {
+				v.check(ps, val, ptr+"/"+escapePointer(name))
+			} else if s.Values != nil {
+				v.check(s.Values, val, ptr+"/"+escapePointer(name))
+			}
+		}
+
+	case "array":
+		arr, ok := doc.([]any)
+		if !ok {
+			v.fail(ptr, "want an array")
+			return
+		}
+		for i, val := range arr {
+			v.check(s.Items, val, ptr+"/"+strconv.Itoa(i))
+		}
+
+	case "string":
+		str, ok := doc.(string)
+		if !ok {
+			v.fail(ptr, "want a string")
+			return
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				v.fail(ptr, "%q is not a date-time", str)
+			}
+		}
+
+	case "boolean":
+		if _, ok := doc.(bool); !ok {
+			v.fail(ptr, "want a boolean")
+		}
+
+	case "integer", "number":
+		num, ok := doc.(json.Number)
+		if !ok {
+			v.fail(ptr, "want a number")
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			v.fail(ptr, "%s is not a number", num)
+			return
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			v.fail(ptr, "%s is not an integer", num)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			v.fail(ptr, "%s is less than %v", num, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			v.fail(ptr, "%s is more than %v", num, *s.Maximum)
+		}
+	}
+}
+
+// escapePointer escapes a name for use in a JSON Pointer
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,82 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "rewrite snapshot.schema.json")
+
+func TestSnapshotSchemaFile(t *testing.T) {
+	want := SnapshotSchema()
+	if *updateSchema {
+		require.NoError(t, os.WriteFile("snapshot.schema.json", want, 0666))
+	}
+
+	got, err := os.ReadFile("snapshot.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got),
+		"snapshot.schema.json is stale; run go test -run TestSnapshotSchemaFile -update-schema")
+}
+
+func TestSnapshotSchema(t *testing.T) {
+	var s map[string]any
+	require.NoError(t, json.Unmarshal(SnapshotSchema(), &s))
+
+	assert.Equal(t, SnapshotSchemaID, s["$id"])
+	assert.Equal(t, []any{"root"}, s["required"])
+
+	defs := s["$defs"].(map[string]any)
+	node := defs["node"].(map[string]any)
+	assert.ElementsMatch(t, []any{"path", "mode", "size", "mtime"}, node["required"])
+
+	props := node["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"$ref": "#/$defs/node"},
+		props["children"].(map[string]any)["items"])
+}
+
+func TestValidateSnapshot(t *testing.T) {
+	t.Run("snapshots are valid", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, scanCopy(t, nil)))
+		assert.NoError(t, ValidateSnapshot(&buf))
+	})
+
+	t.Run("mismatches are reported", func(t *testing.T) {
+		doc := `{
+			"scan": {"hostname": 7},
+			"root": {
+				"path": "/x", "mode": -1, "size": 1.5, "mtime": "yesterday",
+				"children": [{"path": "/x/y"}]
+			}
+		}`
+
+		err := ValidateSnapshot(strings.NewReader(doc))
+		require.Error(t, err)
+
+		pointers := map[string]bool{}
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var se *SchemaError
+			require.True(t, errors.As(e, &se))
+			pointers[se.Pointer] = true
+		}
+		for _, p := range []string{
+			"/scan", "/scan/hostname", "/root/mode", "/root/size",
+			"/root/mtime", "/root/children/0",
+		} {
+			assert.True(t, pointers[p], "no error at %s", p)
+		}
+	})
+
+	t.Run("bad json", func(t *testing.T) {
+		assert.Error(t, ValidateSnapshot(strings.NewReader("{")))
+	})
+}
@@ -0,0 +1,146 @@
+package ctree
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// SecretTag is the tag a SecretScanner gives the files it finds secrets in
+const SecretTag = "contains-secrets"
+
+// DefaultSecretScanSize is the largest file a SecretScanner reads, when it
+// does not say
+const DefaultSecretScanSize = 16 << 20
+
+// Lines longer than secretChunkSize, such as minified scripts, are scanned
+// in chunks of that size, each starting with the last secretOverlap bytes
+// of the one before, so that a credential split between two is still
+// found
+const (
+	secretChunkSize = 64 << 10
+	secretOverlap   = 1 << 10
+)
+
+// SecretPattern is a kind of credential, and how to recognize it in a line
+type SecretPattern struct {
+	Kind string
+	Re   *regexp.Regexp
+}
+
+// DefaultSecretPatterns are the credentials a SecretScanner looks for when
+// it is not given patterns
+var DefaultSecretPatterns = []SecretPattern{
+	{"aws-access-key-id", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-access-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"github-token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// SecretFinding is a line of a file holding what looks like a credential.
+// The credential itself is not kept.
+type SecretFinding struct {
+	Path string
+	Line int
+	Kind string
+}
+
+// SecretScanner is a Processor which finds credentials in text files,
+// such as cloud access keys, private keys, and API tokens, tagging the
+// files with SecretTag. Binary files, which have a NUL byte near the
+// start, are skipped.
+type SecretScanner struct {
+	// Patterns are what to look for; DefaultSecretPatterns if nil
+	Patterns []SecretPattern
+	// MaxSize is the largest file to read; DefaultSecretScanSize if
+	// zero
+	MaxSize int64
+
+	mu       sync.Mutex
+	findings []SecretFinding
+}
+
+var _ Processor = &SecretScanner{}
+
+// Wants reports whether l is small enough to read
+func (s *SecretScanner) Wants(l *Leaf) bool {
+	limit := s.MaxSize
+	if limit <= 0 {
+		limit = DefaultSecretScanSize
+	}
+	return (*l.info).Size() <= limit
+}
+
+// Process scans the lines of l for credentials
+func (s *SecretScanner) Process(l *Leaf, contents io.Reader) error {
+	patterns := s.Patterns
+	if patterns == nil {
+		patterns = DefaultSecretPatterns
+	}
+
+	br := bufio.NewReaderSize(contents, secretChunkSize)
+	if head, _ := br.Peek(512); bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+
+	var found []SecretFinding
+	var carry []byte
+	kinds := map[string]bool{} // found in the line so far
+	for line := 1; ; {
+		chunk, more, err := br.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk = append(carry, chunk...)
+		for _, p := range patterns {
+			if !kinds[p.Kind] && p.Re.Match(chunk) {
+				kinds[p.Kind] = true
+				found = append(found, SecretFinding{Path: l.path, Line: line, Kind: p.Kind})
+			}
+		}
+
+		if more {
+			carry = bytes.Clone(chunk[max(0, len(chunk)-secretOverlap):])
+			continue
+		}
+		carry = nil
+		clear(kinds)
+		line++
+	}
+
+	if len(found) > 0 {
+		l.AddTag(SecretTag)
+
+		s.mu.Lock()
+		s.findings = append(s.findings, found...)
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Findings returns what the scanner has found, sorted by path and line
+func (s *SecretScanner) Findings() []SecretFinding {
+	s.mu.Lock()
+	findings := append([]SecretFinding(nil), s.findings...)
+	s.mu.Unlock()
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings
+}
@@ -0,0 +1,74 @@
+package ctree
+
+import (
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequential(t *testing.T) {
+	mfs := fstest.MapFS{
+		"b/large":   {Data: make([]byte, LargeFileSize)},
+		"b/small":   {Data: []byte("small")},
+		"a/z/deep":  {Data: []byte("deep")},
+		"a/y":       {Data: []byte("y")},
+		"c":         {Data: []byte("c")},
+		"a/x/empty": {},
+	}
+
+	scan := func(threads int, audit func(op, p string)) *DNode {
+		r := NewRoot(".")
+		r.FS = mfs
+		r.Threads = threads
+		r.HashThreads = 4
+		r.Hashes = []Hasher{SHA256}
+		r.Budgets = map[string]int{"a": 1}
+		r.Audit = audit
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	t.Run("same tree as in parallel", func(t *testing.T) {
+		seq, par := scan(1, nil), scan(4, nil)
+		assert.Empty(t, Diff(seq, par))
+		large := seq.Lookup("b/large").(*Leaf).Hash(SHA256.Name)
+		assert.NotNil(t, large)
+		assert.Equal(t, large, par.Lookup("b/large").(*Leaf).Hash(SHA256.Name))
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		var first, second []string
+		before := runtime.NumGoroutine()
+		scan(1, func(op, p string) {
+			assert.LessOrEqual(t, runtime.NumGoroutine(), before, "no goroutines are started")
+			first = append(first, op+" "+p)
+		})
+		scan(1, func(op, p string) {
+			second = append(second, op+" "+p)
+		})
+
+		assert.Equal(t, first, second)
+		assert.Equal(t, []string{
+			"stat .",
+			"readdir .",
+			"stat a", "stat b", "stat c",
+			"open c",
+			"readdir a",
+			"stat a/x", "stat a/y", "stat a/z",
+			"open a/y",
+			"readdir a/x",
+			"stat a/x/empty",
+			"open a/x/empty",
+			"readdir a/z",
+			"stat a/z/deep",
+			"open a/z/deep",
+			"readdir b",
+			"stat b/large", "stat b/small",
+			"open b/large", "open b/small",
+		}, first)
+	})
+}
@@ -0,0 +1,59 @@
+package ctree
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"io"
+)
+
+// signContext keeps snapshot signatures from being valid for anything else
+// signed with the same key
+const signContext = "ctree snapshot"
+
+// ErrBadSignature is returned by VerifySignature when the data does not
+// match the signature
+var ErrBadSignature = errors.New("bad snapshot signature")
+
+var signOpts = &ed25519.Options{
+	Hash:    crypto.SHA512,
+	Context: signContext,
+}
+
+// Sign returns an Ed25519 signature of everything read from r, which is
+// usually a serialized snapshot
+func Sign(key ed25519.PrivateKey, r io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return key.Sign(nil, h.Sum(nil), signOpts)
+}
+
+// VerifySignature checks that sig is a signature of everything read from
+// r, made by the private half of key. It returns ErrBadSignature if not.
+func VerifySignature(key ed25519.PublicKey, r io.Reader, sig []byte) error {
+	h := sha512.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+
+	if err := ed25519.VerifyWithOptions(key, h.Sum(nil), sig, signOpts); err != nil {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+// WriteSignedSnapshot is like WriteSnapshot, but also returns a signature
+// of the bytes written, suitable for VerifySignature
+func WriteSignedSnapshot(w io.Writer, dn *DNode, key ed25519.PrivateKey) ([]byte, error) {
+	h := sha512.New()
+	if err := WriteSnapshot(io.MultiWriter(w, h), dn); err != nil {
+		return nil, err
+	}
+
+	return key.Sign(nil, h.Sum(nil), signOpts)
+}
@@ -0,0 +1,50 @@
+package ctree
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	require := require.New(t)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(err)
+
+	dn := scanCopy(t, nil)
+	var buf bytes.Buffer
+	sig, err := WriteSignedSnapshot(&buf, dn, priv)
+	require.NoError(err)
+	snap := buf.Bytes()
+
+	t.Run("good signature verifies", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(VerifySignature(pub, bytes.NewReader(snap), sig))
+
+		again, err := Sign(priv, bytes.NewReader(snap))
+		assert.NoError(err)
+		assert.NoError(VerifySignature(pub, bytes.NewReader(snap), again))
+	})
+
+	t.Run("tampering is detected", func(t *testing.T) {
+		assert := assert.New(t)
+
+		tampered := bytes.Replace(snap, []byte("wsfitzpa"), []byte("wsfitzpb"), 1)
+		err := VerifySignature(pub, bytes.NewReader(tampered), sig)
+		assert.ErrorIs(err, ErrBadSignature)
+	})
+
+	t.Run("the wrong key is detected", func(t *testing.T) {
+		assert := assert.New(t)
+
+		other, _, err := ed25519.GenerateKey(nil)
+		assert.NoError(err)
+		err = VerifySignature(other, bytes.NewReader(snap), sig)
+		assert.ErrorIs(err, ErrBadSignature)
+	})
+}
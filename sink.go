@@ -0,0 +1,145 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Sink is fed the tree as a walk finishes it, so that one walk can feed
+// several outputs, besides the tree Run returns, rather than scanning
+// again for each; see Root.Sinks. Dir is given each directory, with its
+// leaves, once everything beneath it is finished, so that a directory
+// comes after its children. Each Sink is called once at a time, from any
+// of the workers; once it returns an error, it is given nothing more, and
+// Run returns the error.
+type Sink interface {
+	Dir(dn *DNode) error
+}
+
+// SinkFunc is a function which is a Sink
+type SinkFunc func(dn *DNode) error
+
+// Dir calls f
+func (f SinkFunc) Dir(dn *DNode) error {
+	return f(dn)
+}
+
+// sinkState is one of a Root's Sinks, during a Run
+type sinkState struct {
+	Sink
+	mu  sync.Mutex
+	err error
+}
+
+// startSinks readies the Root's Sinks for a Run
+func (r *Root) startSinks() {
+	r.sinks = make([]*sinkState, len(r.Sinks))
+	for i, s := range r.Sinks {
+		r.sinks[i] = &sinkState{Sink: s}
+	}
+}
+
+// sink gives dn, whose subtree is finished, to each of the Root's Sinks
+func (r *Root) sink(dn *DNode) {
+	for _, s := range r.sinks {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = s.Dir(dn)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sinkErr returns the errors of the Root's Sinks
+func (r *Root) sinkErr() error {
+	var errs []error
+	for i, s := range r.sinks {
+		if s.err != nil {
+			errs = append(errs, fmt.Errorf("sink %d: %w", i, s.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NDJSONSink returns a Sink writing a Record for each directory, followed
+// by its leaves in name order, to w, one JSON object per line, as
+// WriteNDJSON does, but as the walk finishes them. Each directory is
+// written with one call to Write.
+func NDJSONSink(w io.Writer) Sink {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	return SinkFunc(func(dn *DNode) error {
+		buf.Reset()
+		if err := enc.Encode(RecordOf(dn)); err != nil {
+			return err
+		}
+		leaves := append([]*Leaf(nil), dn.leaves...)
+		sortLeaves(leaves)
+		for _, l := range leaves {
+			if err := enc.Encode(RecordOf(l)); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+}
+
+// Metrics is a Sink totalling what a walk finds, which may be read while
+// the walk goes on, to show progress or export to a monitoring system.
+// Its zero value is ready to use.
+type Metrics struct {
+	mu     sync.Mutex
+	counts MetricCounts
+}
+
+// MetricCounts are the totals of a Metrics
+type MetricCounts struct {
+	// Dirs counts the directories finished, and Errors those of them
+	// with errors
+	Dirs   int64
+	Errors int64
+	// Leaves totals every leaf, and ByType the leaves of each TypeName
+	Leaves Usage
+	ByType map[string]Usage
+}
+
+// Dir counts dn and its leaves
+func (m *Metrics) Dir(dn *DNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := &m.counts
+	if c.ByType == nil {
+		c.ByType = map[string]Usage{}
+	}
+	c.Dirs++
+	if dn.err != nil {
+		c.Errors++
+	}
+	for _, l := range dn.leaves {
+		c.Leaves.add(l)
+		u := c.ByType[TypeName((*l.info).Mode())]
+		u.add(l)
+		c.ByType[TypeName((*l.info).Mode())] = u
+	}
+	return nil
+}
+
+// Counts returns the totals so far
+func (m *Metrics) Counts() MetricCounts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.counts
+	c.ByType = make(map[string]Usage, len(m.counts.ByType))
+	for t, u := range m.counts.ByType {
+		c.ByType[t] = u
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package ctree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinks(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var ndjson bytes.Buffer
+	var metrics Metrics
+	var mu sync.Mutex
+	var order []string
+
+	r := NewRoot("home")
+	r.FS = tfs
+	r.Threads = 4
+	r.Sinks = []Sink{
+		NDJSONSink(&ndjson),
+		&metrics,
+		SinkFunc(func(dn *DNode) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, dn.Path())
+			return nil
+		}),
+	}
+	dn, err := r.Run()
+	require.NoError(err)
+
+	t.Run("each directory after its children", func(t *testing.T) {
+		dirs := map[string]*DNode{"home": dn}
+		dn.walk(func(_ string, n Node) bool {
+			if child, ok := n.(*DNode); ok {
+				dirs[child.path] = child
+			}
+			return true
+		})
+
+		assert.Len(order, 5)
+		seen := map[string]bool{}
+		for _, p := range order {
+			for _, child := range dirs[p].children {
+				assert.True(seen[child.path], "%s before %s", child.path, p)
+			}
+			seen[p] = true
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		lines := func(b []byte) []string {
+			var lines []string
+			sc := bufio.NewScanner(bytes.NewReader(b))
+			for sc.Scan() {
+				var rec Record
+				require.NoError(json.Unmarshal(sc.Bytes(), &rec))
+				lines = append(lines, sc.Text())
+			}
+			sort.Strings(lines)
+			return lines
+		}
+
+		var whole bytes.Buffer
+		require.NoError(WriteNDJSON(&whole, dn))
+		assert.Len(lines(ndjson.Bytes()), 9)
+		assert.Equal(lines(whole.Bytes()), lines(ndjson.Bytes()), "the records of WriteNDJSON")
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		c := metrics.Counts()
+		assert.Equal(int64(5), c.Dirs)
+		assert.Zero(c.Errors)
+		assert.Equal(Usage{Files: 4, Bytes: 62}, c.Leaves)
+		assert.Equal(map[string]Usage{"file": {Files: 4, Bytes: 62}}, c.ByType)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		broken := errors.New("broken")
+		calls := 0
+		r.Sinks = []Sink{SinkFunc(func(*DNode) error {
+			calls++
+			return broken
+		})}
+		dn, err := r.Run()
+		assert.NotNil(dn)
+		assert.ErrorIs(err, broken)
+		assert.Equal(1, calls, "a sink which fails is given nothing more")
+	})
+}
@@ -0,0 +1,300 @@
+package ctree
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// snapshot is the JSON form of a tree
+type snapshot struct {
+	Scan *ScanInfo `json:"scan,omitempty"`
+	// Base, if set, is the path of the root, and the paths of the nodes
+	// are relative to it
+	Base string    `json:"base,omitempty"`
+	Root *snapNode `json:"root"`
+}
+
+type snapNode struct {
+	Path        string                     `json:"path"`
+	Mode        fs.FileMode                `json:"mode"`
+	Size        int64                      `json:"size"`
+	ModTime     time.Time                  `json:"mtime"`
+	UID         *uint32                    `json:"uid,omitempty"`
+	GID         *uint32                    `json:"gid,omitempty"`
+	Dev         *uint64                    `json:"dev,omitempty"`
+	Ino         *uint64                    `json:"ino,omitempty"`
+	Gen         *uint64                    `json:"gen,omitempty"`
+	CTime       *time.Time                 `json:"ctime,omitempty"`
+	BTime       *time.Time                 `json:"btime,omitempty"`
+	Attrs       *FileAttr                  `json:"attrs,omitempty"`
+	Compression string                     `json:"compression,omitempty"`
+	Error       string                     `json:"error,omitempty"`
+	ErrKind     string                     `json:"error_kind,omitempty"`
+	Hashes      map[string]string          `json:"hashes,omitempty"` // hex digests
+	Tags        []string                   `json:"tags,omitempty"`
+	Annotations map[string]json.RawMessage `json:"annotations,omitempty"`
+	Children    []*snapNode                `json:"children,omitempty"`
+	Leaves      []*snapNode                `json:"leaves,omitempty"`
+}
+
+// WriteSnapshot writes dn, and its ScanInfo if it has one, to w as JSON
+func WriteSnapshot(w io.Writer, dn *DNode) error {
+	snap := snapshot{
+		Scan: dn.scan,
+		Root: toSnap(dn),
+	}
+
+	return json.NewEncoder(w).Encode(&snap)
+}
+
+// WriteRelativeSnapshot is like WriteSnapshot, but records the path of dn
+// once, and the paths of the nodes relative to it, so that snapshots of
+// the same tree taken on different machines or mount points are the same
+// path for path
+func WriteRelativeSnapshot(w io.Writer, dn *DNode) error {
+	root := toSnap(dn)
+	relativize(root, ".")
+
+	snap := snapshot{
+		Scan: dn.scan,
+		Base: dn.path,
+		Root: root,
+	}
+
+	return json.NewEncoder(w).Encode(&snap)
+}
+
+func relativize(sn *snapNode, rel string) {
+	sn.Path = rel
+	for _, leaf := range sn.Leaves {
+		leaf.Path = path.Join(rel, path.Base(leaf.Path))
+	}
+	for _, child := range sn.Children {
+		relativize(child, path.Join(rel, path.Base(child.Path)))
+	}
+}
+
+// ReadSnapshot reads a tree written by WriteSnapshot or
+// WriteRelativeSnapshot, which may be compressed with gzip or zstd
+func ReadSnapshot(r io.Reader) (*DNode, error) {
+	var snap snapshot
+
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, err
+	}
+	if snap.Root == nil {
+		return nil, errors.New("snapshot has no root")
+	}
+	if !snap.Root.Mode.IsDir() {
+		return nil, &NodeError{Path: snap.Root.Path, Kind: ErrNotDir}
+	}
+
+	dn := fromSnap(snap.Root, snap.Base).(*DNode)
+	dn.scan = snap.Scan
+
+	return dn, nil
+}
+
+// ReadSnapshotAt reads a snapshot as ReadSnapshot does, then moves the
+// tree to base, so that trees from different places can be compared path
+// for path
+func ReadSnapshotAt(r io.Reader, base string) (*DNode, error) {
+	dn, err := ReadSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+
+	base = slash(base)
+	moved := rebase(dn, base, nil)
+	moved.name = path.Base(base)
+	moved.scan = dn.scan
+
+	return moved, nil
+}
+
+func toSnap(n Node) *snapNode {
+	sn := snapEntry(n)
+	if dn, ok := n.(*DNode); ok {
+		for _, child := range dn.children {
+			sn.Children = append(sn.Children, toSnap(child))
+		}
+	}
+	return sn
+}
+
+// snapEntry describes n, and the leaves of a directory, but not its
+// children
+func snapEntry(n Node) *snapNode {
+	fi := *n.Info()
+	sn := &snapNode{
+		Path:    n.Path(),
+		Mode:    fi.Mode(),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}
+
+	si := statOf(fi)
+	if si.hasOwner {
+		sn.UID, sn.GID = &si.uid, &si.gid
+	}
+	if si.hasDev {
+		sn.Dev, sn.Ino = &si.dev, &si.ino
+	}
+	if si.hasGen {
+		sn.Gen = &si.gen
+	}
+	if !si.ctime.IsZero() {
+		sn.CTime = &si.ctime
+	}
+	if !si.btime.IsZero() {
+		sn.BTime = &si.btime
+	}
+	if si.hasAttrs {
+		sn.Attrs = &si.attrs
+	}
+	sn.Compression = si.compress
+
+	sn.Tags = n.Tags()
+
+	dn, ok := n.(*DNode)
+	if !ok {
+		if l, ok := n.(*Leaf); ok {
+			if len(l.hashes) > 0 {
+				sn.Hashes = map[string]string{}
+				for name, sum := range l.hashes {
+					sn.Hashes[name] = hex.EncodeToString(sum)
+				}
+			}
+			for name, a := range l.annotations {
+				if raw, err := json.Marshal(a); err == nil {
+					if sn.Annotations == nil {
+						sn.Annotations = map[string]json.RawMessage{}
+					}
+					sn.Annotations[name] = raw
+				}
+			}
+		}
+		return sn
+	}
+
+	if dn.err != nil {
+		sn.Error = dn.err.Error()
+		sn.ErrKind = kindName(dn.err)
+	}
+	for _, leaf := range dn.leaves {
+		sn.Leaves = append(sn.Leaves, snapEntry(leaf))
+	}
+
+	return sn
+}
+
+// fromSnap builds the tree at sn, whose paths are relative to base unless
+// base is empty
+func fromSnap(sn *snapNode, base string) Node {
+	si := &statInfo{}
+	if sn.UID != nil && sn.GID != nil {
+		si.uid, si.gid, si.hasOwner = *sn.UID, *sn.GID, true
+	}
+	if sn.Dev != nil && sn.Ino != nil {
+		si.dev, si.ino, si.hasDev = *sn.Dev, *sn.Ino, true
+	}
+	if sn.Gen != nil {
+		si.gen, si.hasGen = *sn.Gen, true
+	}
+	if sn.CTime != nil {
+		si.ctime = *sn.CTime
+	}
+	if sn.BTime != nil {
+		si.btime = *sn.BTime
+	}
+	if sn.Attrs != nil {
+		si.attrs, si.hasAttrs = *sn.Attrs, true
+	}
+	si.compress = sn.Compression
+
+	p := sn.Path
+	if base != "" {
+		p = path.Join(base, p)
+	}
+
+	var fi os.FileInfo = &nodeInfo{
+		name:    path.Base(p),
+		size:    sn.Size,
+		mode:    sn.Mode,
+		modTime: sn.ModTime,
+		sys:     si,
+	}
+
+	node := newNode(p, &fi)
+	for _, tag := range sn.Tags {
+		node.AddTag(tag)
+	}
+
+	dn, ok := node.(*DNode)
+	if !ok {
+		if l, ok := node.(*Leaf); ok {
+			if len(sn.Hashes) > 0 {
+				l.hashes = map[string][]byte{}
+				for name, sum := range sn.Hashes {
+					if b, err := hex.DecodeString(sum); err == nil {
+						l.hashes[name] = b
+					}
+				}
+			}
+			for name, raw := range sn.Annotations {
+				l.Annotate(name, raw)
+			}
+		}
+		return node
+	}
+
+	if sn.Error != "" {
+		dn.err = errors.New(sn.Error)
+		if kind, ok := errorKinds[sn.ErrKind]; ok {
+			dn.err = &NodeError{Path: p, Kind: kind, Err: dn.err}
+		}
+	}
+	for _, child := range sn.Children {
+		if cdn, ok := fromSnap(child, base).(*DNode); ok {
+			cdn.parent = dn
+			dn.children = append(dn.children, cdn)
+		}
+	}
+	for _, leaf := range sn.Leaves {
+		if l, ok := fromSnap(leaf, base).(*Leaf); ok {
+			l.parent = dn
+			dn.leaves = append(dn.leaves, l)
+		}
+	}
+
+	return dn
+}
+
+// nodeInfo is a FileInfo for nodes which did not come from the operating
+// system, such as those read from a snapshot
+type nodeInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     any
+}
+
+var _ fs.FileInfo = &nodeInfo{}
+
+func (ni *nodeInfo) Name() string       { return ni.name }
+func (ni *nodeInfo) Size() int64        { return ni.size }
+func (ni *nodeInfo) Mode() fs.FileMode  { return ni.mode }
+func (ni *nodeInfo) ModTime() time.Time { return ni.modTime }
+func (ni *nodeInfo) IsDir() bool        { return ni.mode.IsDir() }
+func (ni *nodeInfo) Sys() any           { return ni.sys }
@@ -0,0 +1,137 @@
+package ctree
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+
+		assert.Equal(dn.Path(), loaded.Path())
+		assert.Equal(dn.TotalLength(), loaded.TotalLength())
+		assert.Empty(Diff(dn, loaded))
+		assert.Empty(Diff(loaded, dn))
+	})
+
+	t.Run("scan info is kept", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		si := dn.ScanInfo()
+		require.NotNil(si)
+		assert.Equal(dn.Path(), si.Root)
+		assert.Equal(DefaultThreadsFor(dn.Path()), si.Options.Threads)
+		assert.False(si.End.Before(si.Start))
+		assert.NotEmpty(si.Version)
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+
+		lsi := loaded.ScanInfo()
+		require.NotNil(lsi)
+		assert.Equal(si.Hostname, lsi.Hostname)
+		assert.Equal(si.User, lsi.User)
+		assert.Equal(si.Options, lsi.Options)
+		assert.True(si.Start.Equal(lsi.Start))
+		assert.True(si.End.Equal(lsi.End))
+	})
+
+	t.Run("errors are kept", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		dn, err := NewRoot(where).Run()
+		require.NoError(err)
+		bin := dn.children[0].children[0].children[0]
+		bin.err = os.ErrPermission
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+
+		errs := loaded.Errors()
+		require.Len(errs, 1)
+		assert.Equal(os.ErrPermission.Error(), errs[0].Error())
+	})
+
+	t.Run("a leaf is not a snapshot", func(t *testing.T) {
+		assert := assert.New(t)
+
+		snap := `{"root": {"path": "` + path.Join("x", "y") + `", "mode": 0}}`
+		dn, err := ReadSnapshot(strings.NewReader(snap))
+		assert.Nil(dn)
+		assert.ErrorIs(err, ErrNotDir)
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+
+		var buf bytes.Buffer
+		require.NoError(WriteRelativeSnapshot(&buf, dn))
+		assert.NotContains(buf.String(), `"path":"`+dn.Path())
+		assert.Contains(buf.String(), `"base":"`+dn.Path()+`"`)
+		assert.Contains(buf.String(), `"path":"ceswift/bin/worms"`)
+		assert.NoError(ValidateSnapshot(bytes.NewReader(buf.Bytes())))
+
+		loaded, err := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+		require.NoError(err)
+		assert.Equal(dn.Path(), loaded.Path())
+		assert.Equal(path.Join(dn.Path(), "ceswift/bin/worms"),
+			loaded.Lookup("ceswift/bin/worms").Path())
+		assert.Empty(Diff(dn, loaded))
+
+		moved, err := ReadSnapshotAt(bytes.NewReader(buf.Bytes()), "/mnt/home")
+		require.NoError(err)
+		assert.Equal("/mnt/home", moved.Path())
+		assert.Equal("/mnt/home/wsfitzpa/bin/zrun", moved.Lookup("wsfitzpa/bin/zrun").Path())
+		assert.Equal(dn.ScanInfo().Hostname, moved.ScanInfo().Hostname)
+		assert.Empty(Diff(dn, moved))
+	})
+
+	t.Run("nanoseconds are kept", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		when := epoch.Add(123456789)
+		dn := scanCopy(t, func(where string) {
+			require.NoError(os.Chtimes(path.Join(where, "ceswift/.cshrc"), when, when))
+		})
+
+		for _, write := range []func(io.Writer, *DNode) error{WriteSnapshot, WriteRelativeSnapshot} {
+			var buf bytes.Buffer
+			require.NoError(write(&buf, dn))
+			loaded, err := ReadSnapshot(&buf)
+			require.NoError(err)
+
+			mtime := (*loaded.Lookup("ceswift/.cshrc").Info()).ModTime()
+			assert.True(when.Equal(mtime), mtime)
+			assert.Empty(Diff(dn, loaded))
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package ctree
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStaleRetries is how many times an operation failing with a stale
+// file handle is retried, unless Root.StaleRetries says otherwise
+const DefaultStaleRetries = 3
+
+// staleBackoff is how long to wait before the first retry of a stale
+// operation; it doubles with each retry
+var staleBackoff = 50 * time.Millisecond
+
+// retryStale retries op, on the node at p, which failed with err, until
+// it succeeds, fails with an error other than a stale file handle, or
+// runs out of retries. Before each retry the path is looked up again from
+// the root, so that an NFS client which has failed over gets fresh
+// handles for every directory along the way.
+func (r *Root) retryStale(p string, err error, op func() error) error {
+	retries := r.StaleRetries
+	if retries == 0 {
+		retries = DefaultStaleRetries
+	}
+
+	backoff := staleBackoff
+	for i := 0; i < retries && errors.Is(err, errStale); i++ {
+		atomic.AddInt64(&r.stats.StaleRetries, 1)
+		time.Sleep(backoff)
+		backoff *= 2
+
+		r.relookup(p)
+		err = op()
+	}
+
+	return err
+}
+
+// relookup stats each directory from the root down to the one holding p
+func (r *Root) relookup(p string) {
+	if p == r.root {
+		return
+	}
+	rel := strings.TrimPrefix(p, strings.TrimSuffix(r.root, "/")+"/")
+	if r.root == "." {
+		rel = p
+	}
+
+	dir := r.root
+	r.stat(dir)
+	names := strings.Split(rel, "/")
+	for _, name := range names[:len(names)-1] {
+		dir = path.Join(dir, name)
+		r.stat(dir)
+	}
+}
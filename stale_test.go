@@ -0,0 +1,81 @@
+package ctree
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleRetries(t *testing.T) {
+	defer func(d time.Duration) { staleBackoff = d }(staleBackoff)
+	staleBackoff = time.Millisecond
+
+	stale := func(times int) *FaultFS {
+		return &FaultFS{
+			FS: tfs,
+			Faults: []Fault{
+				{Pattern: "home/ceswift/bin", Ops: []string{OpReadDir}, Err: errStale, Times: times},
+				{Pattern: "home/wsfitzpa/.cshrc", Ops: []string{OpStat}, Err: errStale, Times: times},
+			},
+		}
+	}
+
+	t.Run("recovered", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = stale(2)
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		assert.Empty(t, dn.Errors())
+		assert.Equal(t, 9, dn.TotalLength())
+		assert.Equal(t, int64(4), r.Stats().StaleRetries)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = stale(DefaultStaleRetries + 1)
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		errs := dn.Errors()
+		require.Len(t, errs, 2)
+		for _, err := range errs {
+			assert.ErrorIs(t, err, errStale)
+		}
+		assert.Equal(t, int64(2*DefaultStaleRetries), r.Stats().StaleRetries)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = stale(1)
+		r.StaleRetries = -1
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		assert.Len(t, dn.Errors(), 2)
+		assert.Zero(t, r.Stats().StaleRetries)
+	})
+
+	t.Run("looked up again from the root", func(t *testing.T) {
+		ffs := &FaultFS{
+			FS: tfs,
+			Faults: []Fault{
+				{Pattern: "home/ceswift/bin", Ops: []string{OpReadDir}, Err: errStale, Times: 1},
+				{Pattern: "home/ceswift", Ops: []string{OpStat}, Err: syscall.ENOENT, Times: 1},
+			},
+		}
+		r := NewRoot("home")
+		r.FS = ffs
+		dn, err := r.Run()
+		require.NoError(t, err)
+		assert.Empty(t, dn.Errors())
+
+		// the stat of home/ceswift came from the lookup, and used up
+		// its fault; the entry's own stat happened before
+		_, err = ffs.Stat("home/ceswift")
+		assert.NoError(t, err)
+	})
+}
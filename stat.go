@@ -0,0 +1,66 @@
+package ctree
+
+import (
+	"io/fs"
+	"time"
+)
+
+// statInfo holds the details of a node which are only available from the
+// platform-specific Sys of its FileInfo. Nodes which did not come from
+// the operating system, such as those read from snapshots, carry a
+// *statInfo as their Sys.
+type statInfo struct {
+	uid, gid  uint32
+	hasOwner  bool
+	nlink     uint64
+	blocks    int64 // in 512 byte units
+	hasBlocks bool
+	dev, ino  uint64
+	hasDev    bool // dev and ino are both known
+	gen       uint64
+	hasGen    bool
+	ctime     time.Time // zero if unknown
+	btime     time.Time // zero if unknown
+	attrs     FileAttr
+	hasAttrs  bool
+	compress  string // the filesystem's compression algorithm, if known
+}
+
+// statOf returns what can be found of fi's statInfo
+func statOf(fi fs.FileInfo) statInfo {
+	if si, ok := fi.Sys().(*statInfo); ok {
+		return *si
+	}
+	return sysStat(fi)
+}
+
+// withStat returns fi, with si as its statInfo
+func withStat(fi fs.FileInfo, si statInfo) fs.FileInfo {
+	return &nodeInfo{
+		name:    fi.Name(),
+		size:    fi.Size(),
+		mode:    fi.Mode(),
+		modTime: fi.ModTime(),
+		sys:     &si,
+	}
+}
+
+// Owner returns the user and group IDs that own n, if they are known
+func Owner(n Node) (uid, gid uint32, ok bool) {
+	si := statOf(*n.Info())
+	return si.uid, si.gid, si.hasOwner
+}
+
+// ChangeTime returns when n's inode last changed, by a write or by a
+// change to its metadata, if it is known
+func ChangeTime(n Node) (time.Time, bool) {
+	si := statOf(*n.Info())
+	return si.ctime, !si.ctime.IsZero()
+}
+
+// BirthTime returns when n was created, if the platform and filesystem
+// record it. On Linux, it is only known if the Root asked for BirthTimes.
+func BirthTime(n Node) (time.Time, bool) {
+	si := statOf(*n.Info())
+	return si.btime, !si.btime.IsZero()
+}
@@ -0,0 +1,18 @@
+//go:build darwin || freebsd || netbsd
+
+package ctree
+
+import (
+	"syscall"
+	"time"
+)
+
+// statTimes fills in what st has beyond the fields common to every Unix
+func statTimes(st *syscall.Stat_t, si *statInfo) {
+	si.ctime = time.Unix(int64(st.Ctimespec.Sec), int64(st.Ctimespec.Nsec))
+	si.gen, si.hasGen = uint64(st.Gen), true
+	si.attrs, si.hasAttrs = bsdAttrs(st.Flags), true
+	if st.Birthtimespec.Sec > 0 {
+		si.btime = time.Unix(int64(st.Birthtimespec.Sec), int64(st.Birthtimespec.Nsec))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build aix || dragonfly || openbsd
+
+package ctree
+
+import (
+	"syscall"
+	"time"
+)
+
+// statTimes fills in what st has beyond the fields common to every Unix
+func statTimes(st *syscall.Stat_t, si *statInfo) {
+	si.ctime = time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	si.gen, si.hasGen = uint64(st.Gen), true
+}
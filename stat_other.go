@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package ctree
+
+import "io/fs"
+
+func sysStat(fi fs.FileInfo) statInfo {
+	return statInfo{}
+}
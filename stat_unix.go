@@ -0,0 +1,30 @@
+//go:build unix
+
+package ctree
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func sysStat(fi fs.FileInfo) statInfo {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return statInfo{}
+	}
+
+	si := statInfo{
+		uid:       st.Uid,
+		gid:       st.Gid,
+		hasOwner:  true,
+		nlink:     uint64(st.Nlink),
+		blocks:    int64(st.Blocks),
+		hasBlocks: true,
+		dev:       uint64(st.Dev),
+		ino:       uint64(st.Ino),
+		hasDev:    true,
+	}
+	statTimes(st, &si)
+
+	return si
+}
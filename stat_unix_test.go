@@ -0,0 +1,99 @@
+//go:build unix
+
+package ctree
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeAndBirthTimes(t *testing.T) {
+	scan := func(t *testing.T, where string) *DNode {
+		r := NewRoot(where)
+		r.BirthTimes = true
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	t.Run("recorded and kept in snapshots", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		writeFile(t, path.Join(where, "f"), "contents")
+		dn := scan(t, where)
+		f := dn.Lookup("f")
+
+		ct, ok := ChangeTime(f)
+		require.True(ok)
+		assert.WithinDuration(time.Now(), ct, time.Minute)
+
+		bt, hasBirth := BirthTime(f)
+		if hasBirth {
+			assert.WithinDuration(time.Now(), bt, time.Minute)
+		}
+
+		var buf bytes.Buffer
+		require.NoError(WriteSnapshot(&buf, dn))
+		loaded, err := ReadSnapshot(&buf)
+		require.NoError(err)
+
+		lct, ok := ChangeTime(loaded.Lookup("f"))
+		assert.True(ok)
+		assert.True(ct.Equal(lct))
+		lbt, ok := BirthTime(loaded.Lookup("f"))
+		assert.Equal(hasBirth, ok)
+		assert.True(bt.Equal(lbt))
+	})
+
+	t.Run("catch changes mtime misses", func(t *testing.T) {
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		f := path.Join(where, "f")
+		writeFile(t, f, "contents")
+		require.NoError(t, os.Chtimes(f, epoch, epoch))
+		before := scan(t, where)
+
+		time.Sleep(10 * time.Millisecond)
+		writeFile(t, f, "CONTENTS")
+		require.NoError(t, os.Chtimes(f, epoch, epoch))
+		after := scan(t, where)
+
+		assert.Empty(Diff(before, after))
+		changes := DiffFunc(before, after, AllOf(DefaultComparer, SameChangeTime))
+		if assert.Len(changes, 1) {
+			assert.Equal("f", changes[0].Path)
+			assert.Equal(Modified, changes[0].Kind)
+		}
+		assert.Empty(DiffFunc(before, after, SameBirthTime))
+
+		if _, ok := BirthTime(before.Lookup("f")); !ok {
+			t.Skip("no birth times here")
+		}
+		require.NoError(t, os.Remove(f))
+		writeFile(t, f, "CONTENTS")
+		require.NoError(t, os.Chtimes(f, epoch, epoch))
+		replaced := scan(t, where)
+		assert.Len(DiffFunc(after, replaced, SameBirthTime), 1)
+	})
+
+	t.Run("unknown times are the same", func(t *testing.T) {
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		f := dn.Lookup("ceswift/.cshrc")
+		_, ok := ChangeTime(f)
+		assert.False(t, ok)
+		assert.True(t, SameChangeTime(f, scanCopy(t, nil).Lookup("ceswift/.cshrc")))
+	})
+}
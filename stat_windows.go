@@ -0,0 +1,32 @@
+package ctree
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// File attributes, from winnt.h
+const (
+	fileAttributeCompressed = 0x00000800
+	fileAttributeEncrypted  = 0x00004000
+)
+
+func sysStat(fi fs.FileInfo) statInfo {
+	d, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return statInfo{}
+	}
+
+	si := statInfo{
+		btime:    time.Unix(0, d.CreationTime.Nanoseconds()),
+		hasAttrs: true,
+	}
+	if d.FileAttributes&fileAttributeCompressed != 0 {
+		si.attrs |= AttrCompressed
+	}
+	if d.FileAttributes&fileAttributeEncrypted != 0 {
+		si.attrs |= AttrEncrypted
+	}
+	return si
+}
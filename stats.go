@@ -0,0 +1,79 @@
+package ctree
+
+import "sync/atomic"
+
+// Stats counts what happened during the most recent Run of a Root, or the
+// one under way
+type Stats struct {
+	// Queued is how many directories were handed to the work list
+	Queued int64
+	// Inline is how many directories were walked by the worker that
+	// found them, because the work list was full
+	Inline int64
+	// OverBudget is how many directories were walked by the worker that
+	// found them, because their subtree was using all of its Budgets
+	OverBudget int64
+	// PriorityErrors is how many workers could not lower their
+	// priority, when LowPriority is set
+	PriorityErrors int64
+	// Vanished is how many files and directories were removed between
+	// being listed and being read
+	Vanished int64
+	// StaleRetries is how many operations were retried because of a
+	// stale file handle
+	StaleRetries int64
+	// PseudoSkipped is how many directories were not walked because
+	// they are on pseudo filesystems; see Root.IncludePseudoFS
+	PseudoSkipped int64
+}
+
+// InlineRate is the fraction of directories which could not be queued,
+// because the work list was full. A high rate suggests WorkListSize is
+// too small.
+func (s Stats) InlineRate() float64 {
+	total := s.Queued + s.Inline
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Inline) / float64(total)
+}
+
+// Stats returns the counts from the most recent Run
+func (r *Root) Stats() Stats {
+	return Stats{
+		Queued:         atomic.LoadInt64(&r.stats.Queued),
+		Inline:         atomic.LoadInt64(&r.stats.Inline),
+		OverBudget:     atomic.LoadInt64(&r.stats.OverBudget),
+		PriorityErrors: atomic.LoadInt64(&r.stats.PriorityErrors),
+		Vanished:       atomic.LoadInt64(&r.stats.Vanished),
+		StaleRetries:   atomic.LoadInt64(&r.stats.StaleRetries),
+		PseudoSkipped:  atomic.LoadInt64(&r.stats.PseudoSkipped),
+	}
+}
+
+// reset zeroes the counts, for a new Run
+func (s *Stats) reset() {
+	for _, n := range []*int64{
+		&s.Queued, &s.Inline, &s.OverBudget, &s.PriorityErrors,
+		&s.Vanished, &s.StaleRetries, &s.PseudoSkipped,
+	} {
+		atomic.StoreInt64(n, 0)
+	}
+}
+
+// autoTune grows the work list if AutoTune is set and the last Run had to
+// walk too many directories inline
+func (r *Root) autoTune() {
+	if !r.AutoTune || r.Stats().InlineRate() <= AutoTuneThreshold {
+		return
+	}
+
+	size := 2 * r.WorkListSize
+	if size < DefaultWorkListSize {
+		size = DefaultWorkListSize
+	}
+	if size > MaxAutoWorkListSize {
+		size = MaxAutoWorkListSize
+	}
+	r.WorkListSize = size
+}
@@ -0,0 +1,71 @@
+package ctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	where := t.TempDir()
+	ttree.build(t, where)
+
+	t.Run("everything is queued", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot(where)
+		r.Threads = 4
+		_, err := r.Run()
+		require.NoError(err)
+
+		stats := r.Stats()
+		assert.Equal(int64(5), stats.Queued)
+		assert.Zero(stats.Inline)
+		assert.Zero(stats.InlineRate())
+	})
+
+	t.Run("a sequential walk has no work list", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot(where)
+		r.Threads = 1
+		r.WorkListSize = 0
+		r.AutoTune = true
+		_, err := r.Run()
+		require.NoError(err)
+
+		stats := r.Stats()
+		assert.Zero(stats.Queued)
+		assert.Zero(stats.Inline)
+		assert.Zero(stats.InlineRate())
+		assert.Equal(0, r.WorkListSize)
+	})
+
+	t.Run("nothing can be queued", func(t *testing.T) {
+		assert.Equal(t, 1.0, Stats{Inline: 5}.InlineRate())
+		assert.Equal(t, 0.2, Stats{Queued: 4, Inline: 1}.InlineRate())
+	})
+
+	t.Run("auto tuning grows the work list", func(t *testing.T) {
+		assert := assert.New(t)
+
+		r := NewRoot(where)
+		r.WorkListSize = 0
+		r.AutoTune = true
+		r.stats.Inline = 5
+		r.autoTune()
+		assert.Equal(DefaultWorkListSize, r.WorkListSize)
+
+		r.stats.reset()
+		r.autoTune()
+		assert.Equal(DefaultWorkListSize, r.WorkListSize)
+
+		r.stats.Inline = 5
+		r.WorkListSize = MaxAutoWorkListSize
+		r.autoTune()
+		assert.Equal(MaxAutoWorkListSize, r.WorkListSize)
+	})
+}
@@ -0,0 +1,8 @@
+//go:build unix && !linux
+
+package ctree
+
+// syscallCount is not known on this platform
+func syscallCount() int64 {
+	return 0
+}
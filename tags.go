@@ -0,0 +1,76 @@
+package ctree
+
+import (
+	"sort"
+)
+
+// AddTag tags the directory, such as to mark it for a later query; see
+// ByTag. Tags are kept in snapshots.
+func (dn *DNode) AddTag(tag string) {
+	dn.tags = addTag(dn.tags, tag)
+}
+
+// HasTag reports whether the directory has been given tag
+func (dn *DNode) HasTag(tag string) bool {
+	return hasTag(dn.tags, tag)
+}
+
+// Tags returns the tags given to the directory, sorted
+func (dn *DNode) Tags() []string {
+	return dn.tags
+}
+
+// AddTag tags the leaf, as classifiers and Processors do. Tags are kept
+// in snapshots.
+func (l *Leaf) AddTag(tag string) {
+	l.tags = addTag(l.tags, tag)
+}
+
+// HasTag reports whether the leaf has been given tag
+func (l *Leaf) HasTag(tag string) bool {
+	return hasTag(l.tags, tag)
+}
+
+// Tags returns the tags given to the leaf, sorted
+func (l *Leaf) Tags() []string {
+	return l.tags
+}
+
+// ByTag returns dn, and the nodes beneath it, which have tag, sorted by
+// path
+func (dn *DNode) ByTag(tag string) []Node {
+	var found []Node
+	if dn.HasTag(tag) {
+		found = append(found, dn)
+	}
+	dn.walk(func(_ string, n Node) bool {
+		if n.HasTag(tag) {
+			found = append(found, n)
+		}
+		return true
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Path() < found[j].Path()
+	})
+
+	return found
+}
+
+// addTag returns tags with tag added, keeping them sorted and unique.
+// tags is not changed, since copies of trees share it.
+func addTag(tags []string, tag string) []string {
+	i := sort.SearchStrings(tags, tag)
+	if i < len(tags) && tags[i] == tag {
+		return tags
+	}
+	added := make([]string, 0, len(tags)+1)
+	added = append(added, tags[:i]...)
+	added = append(added, tag)
+	return append(added, tags[i:]...)
+}
+
+func hasTag(tags []string, tag string) bool {
+	i := sort.SearchStrings(tags, tag)
+	return i < len(tags) && tags[i] == tag
+}
@@ -0,0 +1,81 @@
+package ctree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	scan := func() *DNode {
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(t, err)
+		return dn
+	}
+	dn := scan()
+
+	bin := dn.Lookup("ceswift/bin")
+	zrun := dn.Lookup("wsfitzpa/bin/zrun")
+	bin.AddTag("review")
+	zrun.AddTag("review")
+	zrun.AddTag("keep")
+	zrun.AddTag("keep")
+	dn.AddTag("root")
+
+	t.Run("queries", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.True(bin.HasTag("review"))
+		assert.False(bin.HasTag("keep"))
+		assert.Equal([]string{"keep", "review"}, zrun.Tags())
+		assert.Equal([]Node{bin, zrun}, dn.ByTag("review"))
+		assert.Equal([]Node{dn}, dn.ByTag("root"))
+		assert.Empty(dn.ByTag("missing"))
+		assert.Empty(dn.Lookup("ceswift").(*DNode).ByTag("keep"))
+	})
+
+	t.Run("snapshots", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(&buf, dn))
+		require.NoError(t, ValidateSnapshot(bytes.NewReader(buf.Bytes())))
+		read, err := ReadSnapshot(&buf)
+		require.NoError(t, err)
+
+		assert.Equal([]string{"root"}, read.Tags())
+		assert.Equal([]string{"review"}, read.Lookup("ceswift/bin").Tags())
+		assert.Equal([]string{"keep", "review"}, read.Lookup("wsfitzpa/bin/zrun").Tags())
+		assert.Equal([]string{"review"}, RecordOf(bin).Tags)
+	})
+
+	t.Run("copies", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cp := dn.Truncate(0, 0)
+		cp.Lookup("wsfitzpa/bin/zrun").AddTag("copied")
+		cp.Lookup("ceswift/bin").AddTag("copied")
+
+		assert.Equal([]string{"copied", "review"}, cp.Lookup("ceswift/bin").Tags())
+		assert.Equal([]string{"keep", "review"}, zrun.Tags())
+		assert.Equal([]string{"review"}, bin.Tags())
+	})
+
+	t.Run("diffs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		old := scan()
+		changes := DiffFunc(old, dn, AllOf(DefaultComparer, SameTags))
+		var paths []string
+		for _, c := range changes {
+			paths = append(paths, c.Path)
+			assert.Equal(Modified, c.Kind)
+		}
+		assert.Equal([]string{"ceswift/bin", "wsfitzpa/bin/zrun"}, paths)
+		assert.Empty(Diff(old, dn))
+	})
+}
@@ -0,0 +1,54 @@
+package ctree
+
+import "runtime"
+
+const (
+	// MaxDefaultThreads is the most threads DefaultThreadsFor suggests
+	MaxDefaultThreads = 64
+	// NetworkThreadFactor is how many threads per CPU DefaultThreadsFor
+	// suggests for network filesystems, where threads mostly wait
+	NetworkThreadFactor = 4
+	// MemoryPerThread is how much memory DefaultThreadsFor allows for
+	// each thread, when the process has a memory limit
+	MemoryPerThread = 64 << 20
+)
+
+// DefaultThreadsFor suggests how many threads to use to walk the tree at
+// path. It allows a thread for each CPU the process may use, taking
+// cgroup CPU quotas into account, with more for network filesystems,
+// then limits that by any cgroup memory limit.
+func DefaultThreadsFor(path string) int {
+	threads := availableCPUs()
+	if isNetworkFS(path) {
+		threads *= NetworkThreadFactor
+	}
+
+	if limit, ok := memoryLimit(); ok {
+		if most := int(limit / MemoryPerThread); threads > most {
+			threads = most
+		}
+	}
+
+	if threads < 1 {
+		threads = 1
+	}
+	if threads > MaxDefaultThreads {
+		threads = MaxDefaultThreads
+	}
+
+	return threads
+}
+
+// availableCPUs is how many CPUs the process can use
+func availableCPUs() int {
+	cpus := runtime.NumCPU()
+
+	if quota, ok := cpuQuota(); ok && quota < float64(cpus) {
+		cpus = int(quota + 0.5)
+	}
+	if cpus < 1 {
+		cpus = 1
+	}
+
+	return cpus
+}
@@ -0,0 +1,290 @@
+package ctree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoTrash is returned by RemoveTree when asked to use the trash on a
+// platform which has none that ctree knows of
+var ErrNoTrash = errors.New("no trash on this platform")
+
+// trash moves what a RemoveTree would delete into a trash or quarantine
+// directory, where the node being removed, top, becomes dest
+type trash struct {
+	top, dest string
+
+	mu       sync.Mutex
+	manifest trashManifest
+}
+
+// trashManifest records where a RemoveTree moved everything, so that
+// Restore can put it back. Info is the freedesktop.org trash info file
+// made for the node, if any.
+type trashManifest struct {
+	Time    time.Time    `json:"time"`
+	Info    string       `json:"info,omitempty"`
+	Entries []trashEntry `json:"entries"`
+}
+
+// trashEntry is a leaf moved to the trash, or a directory removed, which
+// is recreated in the trash to hold what was beneath it
+type trashEntry struct {
+	Path  string      `json:"path"`
+	Trash string      `json:"trash"`
+	Mode  fs.FileMode `json:"mode"`
+}
+
+// newTrash finds where to move n, beneath the platform's trash or the
+// quarantine directory, and claims the place for it
+func newTrash(n Node, opts *RemoveOptions) (*trash, error) {
+	t := &trash{top: n.Path(), manifest: trashManifest{Time: time.Now()}}
+
+	dir, info := opts.Quarantine, ""
+	if opts.Trash {
+		var err error
+		if dir, info, err = platformTrash(); err != nil {
+			return nil, err
+		}
+	} else if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	_, isDir := n.(*DNode)
+	name := path.Base(t.top)
+	for i := 1; ; i++ {
+		try := name
+		if i > 1 {
+			try = fmt.Sprintf("%s.%d", name, i)
+		}
+		err := t.claim(filepath.Join(dir, try), isDir, info, try)
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+	}
+}
+
+// claim makes dest, beneath the trash, the place for the node, with a
+// trash info file of the given name in infoDir, if it is set. It fails
+// with fs.ErrExist if either is taken.
+func (t *trash) claim(dest string, isDir bool, infoDir, name string) error {
+	if infoDir != "" {
+		if err := t.claimInfo(filepath.Join(infoDir, name+".trashinfo")); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if isDir {
+		err = os.Mkdir(dest, 0o700)
+	} else if _, statErr := os.Lstat(dest); statErr == nil {
+		err = fs.ErrExist
+	}
+	if err != nil {
+		if t.manifest.Info != "" {
+			os.Remove(t.manifest.Info)
+			t.manifest.Info = ""
+		}
+		return err
+	}
+
+	t.dest = dest
+	return nil
+}
+
+// platformTrash returns the directory of the user's trash that files go
+// in, and the one their freedesktop.org trash info files go in, if any
+func platformTrash() (files, info string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, ".Trash"), "", nil
+	case "windows", "plan9", "ios", "android", "js", "wasip1":
+		return "", "", ErrNoTrash
+	}
+
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		data = filepath.Join(home, ".local", "share")
+	}
+	files = filepath.Join(data, "Trash", "files")
+	info = filepath.Join(data, "Trash", "info")
+	for _, dir := range []string{files, info} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", "", err
+		}
+	}
+	return files, info, nil
+}
+
+// claimInfo creates the freedesktop.org trash info file at p, saying
+// where the node came from, unless it already exists
+func (t *trash) claimInfo(p string) error {
+	abs, err := filepath.Abs(t.top)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	t.manifest.Info = p
+
+	u := url.URL{Path: filepath.ToSlash(abs)}
+	_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		u.EscapedPath(), t.manifest.Time.Format("2006-01-02T15:04:05"))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// where returns where p, the path of a node beneath top, goes
+func (t *trash) where(p string) string {
+	if p == t.top {
+		return t.dest
+	}
+	return filepath.Join(t.dest, filepath.FromSlash(strings.TrimPrefix(p, t.top+"/")))
+}
+
+// leaf moves the leaf at p to the trash
+func (t *trash) leaf(p string, mode fs.FileMode) error {
+	to := t.where(p)
+	if err := os.MkdirAll(filepath.Dir(to), 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(p, to); err != nil {
+		return err
+	}
+	t.record(trashEntry{Path: p, Trash: to, Mode: mode})
+	return nil
+}
+
+// dir removes the empty directory at p, whose contents have been moved
+// to the trash
+func (t *trash) dir(p string, mode fs.FileMode) error {
+	to := t.where(p)
+	if err := os.MkdirAll(to, 0o700); err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	t.record(trashEntry{Path: p, Trash: to, Mode: mode})
+	return nil
+}
+
+func (t *trash) record(e trashEntry) {
+	t.mu.Lock()
+	t.manifest.Entries = append(t.manifest.Entries, e)
+	t.mu.Unlock()
+}
+
+// finish gives up the place in the trash if nothing was moved there, and
+// writes the manifest to w, if it is set
+func (t *trash) finish(w io.Writer) error {
+	m := &t.manifest
+	if len(m.Entries) == 0 {
+		os.Remove(t.dest)
+		if m.Info != "" {
+			os.Remove(m.Info)
+			m.Info = ""
+		}
+	}
+	if w == nil {
+		return nil
+	}
+
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+	return json.NewEncoder(w).Encode(m)
+}
+
+// Restore puts back what a RemoveTree moved to the trash or quarantine,
+// from the manifest it wrote. Directories are recreated, and files moved
+// back, unless something else is now in their place, and then the
+// directories are given their modes. The emptied directories of the
+// trash, and its info file, are removed. All errors are returned, joined.
+func Restore(manifest io.Reader) error {
+	var m trashManifest
+	if err := json.NewDecoder(manifest).Decode(&m); err != nil {
+		return fmt.Errorf("trash manifest: %w", err)
+	}
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+
+	// directories are made writable until their files are back in them
+	var errs []error
+	var made []trashEntry
+	for _, e := range m.Entries {
+		if !e.Mode.IsDir() {
+			continue
+		}
+		if err := os.Mkdir(e.Path, 0o700); err == nil {
+			made = append(made, e)
+		} else if !errors.Is(err, fs.ErrExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, e := range m.Entries {
+		if e.Mode.IsDir() {
+			continue
+		}
+		if _, err := os.Lstat(e.Path); err == nil {
+			errs = append(errs, &fs.PathError{Op: "restore", Path: e.Path, Err: fs.ErrExist})
+			continue
+		}
+		if err := os.MkdirAll(path.Dir(e.Path), 0o777); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(e.Trash, e.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the deepest first, so that a directory which may not be written to
+	// doesn't stop the ones beneath it being changed
+	for i := len(made) - 1; i >= 0; i-- {
+		if err := os.Chmod(made[i].Path, made[i].Mode.Perm()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the deepest first, so that each is empty when its turn comes
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		if e := m.Entries[i]; e.Mode.IsDir() {
+			os.Remove(e.Trash)
+		}
+	}
+	if len(errs) == 0 && m.Info != "" {
+		if err := os.Remove(m.Info); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
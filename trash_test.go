@@ -0,0 +1,172 @@
+package ctree
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	rescan := func(t *testing.T, p string) *DNode {
+		dn, err := NewRoot(p).Run()
+		require.NoError(t, err)
+		return dn
+	}
+
+	t.Run("quarantine and restore", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		before := rescan(t, dn.Path())
+		quarantine := filepath.Join(t.TempDir(), "quarantine")
+
+		var manifest bytes.Buffer
+		require.NoError(RemoveTree(dn.Lookup("ceswift"), &RemoveOptions{
+			Quarantine: quarantine,
+			Manifest:   &manifest,
+		}))
+
+		assert.NoDirExists(path.Join(dn.Path(), "ceswift"))
+		assert.Nil(dn.Lookup("ceswift"))
+		assert.FileExists(filepath.Join(quarantine, "ceswift/bin/worms"))
+		assert.FileExists(filepath.Join(quarantine, "ceswift/.cshrc"))
+
+		var m trashManifest
+		require.NoError(json.Unmarshal(manifest.Bytes(), &m))
+		assert.Empty(m.Info)
+		var paths []string
+		for _, e := range m.Entries {
+			paths = append(paths, strings.TrimPrefix(e.Path, dn.Path()+"/"))
+		}
+		assert.Equal([]string{"ceswift", "ceswift/.cshrc", "ceswift/bin", "ceswift/bin/worms"}, paths)
+
+		require.NoError(Restore(&manifest))
+		assert.Empty(Diff(before, rescan(t, dn.Path())))
+		assert.NoDirExists(filepath.Join(quarantine, "ceswift"))
+	})
+
+	t.Run("names are not reused", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		quarantine := t.TempDir()
+		opts := &RemoveOptions{Quarantine: quarantine}
+
+		require.NoError(RemoveTree(dn.Lookup("ceswift/.cshrc"), opts))
+		require.NoError(RemoveTree(dn.Lookup("wsfitzpa/.cshrc"), opts))
+
+		assert.FileExists(filepath.Join(quarantine, ".cshrc"))
+		assert.FileExists(filepath.Join(quarantine, ".cshrc.2"))
+	})
+
+	t.Run("only what was scanned", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		extra := path.Join(dn.Path(), "ceswift/bin/new")
+		writeFile(t, extra, "new")
+		quarantine := t.TempDir()
+
+		var manifest bytes.Buffer
+		err := RemoveTree(dn.Lookup("ceswift"), &RemoveOptions{
+			Quarantine: quarantine,
+			Manifest:   &manifest,
+		})
+		assert.Error(err)
+
+		assert.FileExists(extra)
+		assert.FileExists(filepath.Join(quarantine, "ceswift/bin/worms"))
+
+		require.NoError(Restore(&manifest))
+		assert.FileExists(path.Join(dn.Path(), "ceswift/bin/worms"))
+		assert.FileExists(path.Join(dn.Path(), "ceswift/.cshrc"))
+	})
+
+	t.Run("restore into read-only directories", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dn := scanCopy(t, nil)
+		var manifest bytes.Buffer
+		require.NoError(RemoveTree(dn.Lookup("ceswift"), &RemoveOptions{
+			Quarantine: t.TempDir(),
+			Manifest:   &manifest,
+		}))
+
+		// as if ceswift/bin had been read-only
+		var m trashManifest
+		require.NoError(json.Unmarshal(manifest.Bytes(), &m))
+		bin := path.Join(dn.Path(), "ceswift/bin")
+		for i, e := range m.Entries {
+			if e.Path == bin {
+				m.Entries[i].Mode = os.ModeDir | 0o555
+			}
+		}
+		manifest.Reset()
+		require.NoError(json.NewEncoder(&manifest).Encode(&m))
+
+		require.NoError(Restore(&manifest))
+		defer os.Chmod(bin, 0o755)
+		assert.FileExists(path.Join(bin, "worms"))
+		fi, err := os.Stat(bin)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0o555), fi.Mode().Perm())
+	})
+
+	t.Run("restore keeps what took the place", func(t *testing.T) {
+		dn := scanCopy(t, nil)
+		cshrc := path.Join(dn.Path(), "wsfitzpa/.cshrc")
+
+		var manifest bytes.Buffer
+		require.NoError(t, RemoveTree(dn.Lookup("wsfitzpa/.cshrc"), &RemoveOptions{
+			Quarantine: t.TempDir(),
+			Manifest:   &manifest,
+		}))
+		writeFile(t, cshrc, "replaced")
+
+		assert.Error(t, Restore(&manifest))
+		contents, err := os.ReadFile(cshrc)
+		require.NoError(t, err)
+		assert.Equal(t, "replaced", string(contents))
+	})
+
+	t.Run("the trash", func(t *testing.T) {
+		switch runtime.GOOS {
+		case "darwin", "windows", "plan9", "ios", "android", "js", "wasip1":
+			t.Skip("no freedesktop.org trash")
+		}
+		require := require.New(t)
+		assert := assert.New(t)
+
+		data := t.TempDir()
+		t.Setenv("XDG_DATA_HOME", data)
+
+		dn := scanCopy(t, nil)
+		var manifest bytes.Buffer
+		require.NoError(RemoveTree(dn.Lookup("wsfitzpa"), &RemoveOptions{
+			Trash:    true,
+			Manifest: &manifest,
+		}))
+
+		assert.FileExists(filepath.Join(data, "Trash/files/wsfitzpa/bin/zrun"))
+		info, err := os.ReadFile(filepath.Join(data, "Trash/info/wsfitzpa.trashinfo"))
+		require.NoError(err)
+		assert.Contains(string(info), "[Trash Info]\nPath="+dn.Path()+"/wsfitzpa\nDeletionDate=")
+
+		require.NoError(Restore(&manifest))
+		assert.FileExists(path.Join(dn.Path(), "wsfitzpa/bin/zrun"))
+		assert.NoFileExists(filepath.Join(data, "Trash/info/wsfitzpa.trashinfo"))
+		assert.NoDirExists(filepath.Join(data, "Trash/files/wsfitzpa"))
+	})
+}
@@ -0,0 +1,116 @@
+package ctree
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// elision is the Sys of a placeholder made by Truncate
+type elision struct {
+	count int
+}
+
+// Truncate returns a copy of the tree at dn pruned for showing, so that UIs
+// and logs can preview trees too big to show whole. A directory keeps at
+// most maxNodesPerDir of its entries, the first in name order, and a
+// directory maxDepth beneath dn keeps none. Whatever a directory loses is
+// stood for by a placeholder leaf named "…and N more", whose size is the
+// total of the leaves it stands for; Elided tells placeholders apart. A
+// limit of zero or less is no limit.
+func (dn *DNode) Truncate(maxNodesPerDir, maxDepth int) *DNode {
+	cp := truncate(dn, maxNodesPerDir, maxDepth, 0)
+	cp.scan = dn.scan
+	return cp
+}
+
+func truncate(dn *DNode, maxNodes, maxDepth, depth int) *DNode {
+	cp := &DNode{
+		name:  dn.name,
+		path:  dn.path,
+		info:  dn.info,
+		err:   dn.err,
+		depth: depth,
+		tags:  dn.tags,
+	}
+
+	entries := sortedEntries(dn)
+	keep := len(entries)
+	if maxNodes > 0 && keep > maxNodes {
+		keep = maxNodes
+	}
+	if maxDepth > 0 && depth >= maxDepth {
+		keep = 0
+	}
+
+	for _, n := range entries[:keep] {
+		switch n := n.(type) {
+		case *DNode:
+			child := truncate(n, maxNodes, maxDepth, depth+1)
+			child.parent = cp
+			cp.children = append(cp.children, child)
+		case *Leaf:
+			cp.leaves = append(cp.leaves, n.copyTo(cp))
+		}
+	}
+
+	if rest := entries[keep:]; len(rest) > 0 {
+		cp.leaves = append(cp.leaves, placeholder(cp, rest))
+	}
+
+	return cp
+}
+
+// copyTo returns a copy of l, in the directory dn
+func (l *Leaf) copyTo(dn *DNode) *Leaf {
+	return &Leaf{
+		name:        l.name,
+		path:        l.path,
+		parent:      dn,
+		info:        l.info,
+		hashes:      l.hashes,
+		tags:        l.tags,
+		annotations: l.annotations,
+	}
+}
+
+// placeholder makes a leaf of dn standing for the nodes in rest
+func placeholder(dn *DNode, rest []Node) *Leaf {
+	var size int64
+	for _, n := range rest {
+		switch n := n.(type) {
+		case *DNode:
+			size += n.Usage().Bytes
+		case *Leaf:
+			size += (*n.info).Size()
+		}
+	}
+
+	name := fmt.Sprintf("…and %d more", len(rest))
+	var fi os.FileInfo = &nodeInfo{
+		name: name,
+		size: size,
+		mode: os.ModeIrregular,
+		sys:  &elision{count: len(rest)},
+	}
+
+	return &Leaf{
+		name:   name,
+		path:   path.Join(dn.path, name),
+		parent: dn,
+		info:   &fi,
+	}
+}
+
+// Elided returns the number of entries the placeholder n, made by
+// Truncate, stands for, or false if n is not a placeholder
+func Elided(n Node) (int, bool) {
+	if _, ok := n.(*Leaf); !ok {
+		return 0, false
+	}
+	e, ok := (*n.Info()).Sys().(*elision)
+	if !ok {
+		return 0, false
+	}
+	return e.count, true
+}
@@ -0,0 +1,72 @@
+package ctree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	dn := scanCopy(t, nil)
+
+	t.Run("no limits copies", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cp := dn.Truncate(0, 0)
+		assert.NotSame(dn, cp)
+		assert.Empty(Diff(dn, cp))
+		assert.Equal(dn.ScanInfo(), cp.ScanInfo())
+	})
+
+	t.Run("entries per directory", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		cp := dn.Truncate(1, 0)
+		entries := cp.Entries()
+		require.Len(entries, 2)
+		assert.Equal("ceswift", nodeName(entries[0]))
+		assert.Equal("…and 1 more", nodeName(entries[1]))
+		assert.Equal(dn.Path()+"/…and 1 more", entries[1].Path())
+		assert.Equal(int64(38), (*entries[1].Info()).Size())
+		assert.Same(cp, entries[1].(*Leaf).parent)
+
+		n, ok := Elided(entries[1])
+		assert.True(ok)
+		assert.Equal(1, n)
+		_, ok = Elided(entries[0])
+		assert.False(ok)
+
+		assert.NotNil(cp.Lookup("ceswift/.cshrc"))
+		assert.Nil(cp.Lookup("ceswift/bin"))
+		assert.Len(dn.Entries(), 2, "the original is untouched")
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cp := dn.Truncate(0, 1)
+		for _, name := range []string{"ceswift", "wsfitzpa"} {
+			sub := cp.Lookup(name).(*DNode)
+			entries := sub.Entries()
+			if assert.Len(entries, 1) {
+				n, ok := Elided(entries[0])
+				assert.True(ok)
+				assert.Equal(2, n)
+			}
+		}
+	})
+
+	t.Run("previews", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteTree(&buf, dn.Truncate(1, 2), nil))
+		assert.Equal(t, dn.Path()+`
+├── ceswift
+│   ├── .cshrc
+│   └── …and 1 more
+└── …and 1 more
+`, buf.String())
+	})
+}
@@ -0,0 +1,32 @@
+package ctree
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// vanish counts p, which was removed during the walk, and records it if
+// the Root asks. It returns false if it should be treated as an error.
+func (r *Root) vanish(p string) bool {
+	atomic.AddInt64(&r.stats.Vanished, 1)
+
+	if r.RecordVanished {
+		r.errMu.Lock()
+		r.vanished = append(r.vanished, p)
+		r.errMu.Unlock()
+	}
+
+	return !r.VanishedErrors
+}
+
+// Vanished returns the sorted paths of the files and directories which
+// were removed during the most recent Run, if RecordVanished was set
+func (r *Root) Vanished() []string {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+
+	paths := append([]string(nil), r.vanished...)
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,77 @@
+package ctree
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVanished(t *testing.T) {
+	// .cshrc vanishes between its directory being read and its stat, and
+	// wsfitzpa/bin between being listed and being read
+	vanishing := func() *FaultFS {
+		return &FaultFS{
+			FS: tfs,
+			Faults: []Fault{
+				{Pattern: "home/ceswift/.cshrc", Ops: []string{OpStat}, Err: fs.ErrNotExist},
+				{Pattern: "home/wsfitzpa/bin", Ops: []string{OpReadDir}, Err: fs.ErrNotExist},
+			},
+		}
+	}
+
+	t.Run("counted", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = vanishing()
+		dn, err := r.Run()
+		require.NoError(err)
+
+		assert.Empty(dn.Errors())
+		assert.Equal(int64(2), r.Stats().Vanished)
+		assert.Empty(r.Vanished())
+		assert.Nil(dn.Lookup("ceswift/.cshrc"))
+		assert.Equal(7, dn.TotalLength())
+	})
+
+	t.Run("recorded", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = vanishing()
+		r.RecordVanished = true
+		dn, err := r.Run()
+		require.NoError(err)
+
+		assert.Empty(dn.Errors())
+		assert.Equal([]string{"home/ceswift/.cshrc", "home/wsfitzpa/bin"}, r.Vanished())
+
+		r.FS = tfs
+		_, err = r.Run()
+		require.NoError(err)
+		assert.Empty(r.Vanished())
+		assert.Zero(r.Stats().Vanished)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		r := NewRoot("home")
+		r.FS = vanishing()
+		r.VanishedErrors = true
+		dn, err := r.Run()
+		require.NoError(err)
+
+		errs := dn.Errors()
+		require.Len(errs, 2)
+		for _, err := range errs {
+			assert.ErrorIs(err, ErrVanished)
+		}
+		assert.Equal(int64(2), r.Stats().Vanished)
+	})
+}
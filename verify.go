@@ -0,0 +1,167 @@
+package ctree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// DiscrepancyKind describes how a tree disagrees with a sequential walk
+type DiscrepancyKind int
+
+const (
+	// Missing means the sequential walk found a path that the tree lacks
+	Missing DiscrepancyKind = iota + 1
+	// Unexpected means the tree has a path the sequential walk did not find
+	Unexpected
+	// WrongType means the path is a directory in one, but not the other
+	WrongType
+	// ErrorMismatch means only one of them failed to read a directory
+	ErrorMismatch
+)
+
+func (k DiscrepancyKind) String() string {
+	switch k {
+	case Missing:
+		return "missing"
+	case Unexpected:
+		return "unexpected"
+	case WrongType:
+		return "wrong type"
+	case ErrorMismatch:
+		return "error mismatch"
+	}
+	return "unknown"
+}
+
+// Discrepancy describes one path where a tree disagrees with a
+// sequential walk
+type Discrepancy struct {
+	Path string
+	Kind DiscrepancyKind
+}
+
+// Verify cross-checks dn, which should have come from r.Run, against a
+// sequential walk of the same tree using filepath.WalkDir (or
+// fs.WalkDir, if r has a Backend or an FS). The walk leaves out what
+// r.Run did: directories deeper than MaxDepth, pseudo filesystems, and
+// directories the tree found were cycles. A tree whose walk stopped at
+// MaxNodes or MaxBytes can't be verified, and an error matching
+// ErrLimitReached is returned. The discrepancies found are returned
+// sorted by path. Since the filesystem may change between the two walks,
+// discrepancies on a live filesystem are not necessarily bugs.
+func (r *Root) Verify(dn *DNode) ([]Discrepancy, error) {
+	if si := dn.ScanInfo(); si != nil && si.LimitReached {
+		return nil, &NodeError{Path: dn.path, Kind: ErrLimitReached}
+	}
+
+	// by clean path, since the walk cleans what it joins to the root,
+	// and the tree, what it joins to the Root's Path
+	tree := map[string]Node{}
+	for _, n := range dn.Flatten() {
+		tree[path.Clean(n.Path())] = n
+	}
+	rootPath := path.Clean(slash(r.Path))
+
+	type seen struct {
+		dir    bool
+		failed bool
+		depth  int
+		info   fs.FileInfo
+	}
+	walked := map[string]*seen{}
+
+	fn := func(p string, d fs.DirEntry, err error) error {
+		p = path.Clean(slash(p))
+		s, ok := walked[p]
+		if !ok {
+			s = &seen{}
+			walked[p] = s
+		}
+		if d != nil {
+			s.dir = d.IsDir()
+		}
+		if err != nil {
+			s.failed = true
+			return nil
+		}
+		if !s.dir || p == rootPath {
+			return nil
+		}
+
+		// the directories r.Run would not have read
+		parent, ok := walked[path.Dir(p)]
+		if !ok {
+			return fmt.Errorf("verify: %q walked before its directory", p)
+		}
+		s.depth = parent.depth + 1
+		if r.MaxDepth > 0 && s.depth >= r.MaxDepth {
+			s.failed = true
+			return fs.SkipDir
+		}
+		s.info, _ = d.Info()
+		if s.info != nil && parent.info != nil && r.pseudo(p, s.info, parent.info) {
+			return fs.SkipDir
+		}
+		// which of two paths to the same directory was walked first
+		// depends on the order of the walk, so the tree's word is taken
+		if cdn, ok := tree[p].(*DNode); ok && errors.Is(cdn.err, ErrCycle) {
+			s.failed = true
+			return fs.SkipDir
+		}
+		return nil
+	}
+
+	// the root's info, for finding pseudo filesystems beneath it
+	root := &seen{dir: true}
+	root.info, _ = r.backend().Stat(r.Path)
+	walked[rootPath] = root
+
+	var err error
+	b := r.backend()
+	if _, ok := b.(OSBackend); ok {
+		err = filepath.WalkDir(r.Path, fn)
+	} else {
+		err = fs.WalkDir(backendFS{b}, r.Path, fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []Discrepancy{}
+	report := func(p string, kind DiscrepancyKind) {
+		problems = append(problems, Discrepancy{Path: p, Kind: kind})
+	}
+
+	for p, n := range tree {
+		s, ok := walked[p]
+		if !ok {
+			report(p, Unexpected)
+			continue
+		}
+
+		cdn, isDir := n.(*DNode)
+		if isDir != s.dir {
+			report(p, WrongType)
+			continue
+		}
+		if isDir && (cdn.err != nil) != s.failed {
+			report(p, ErrorMismatch)
+		}
+	}
+
+	for p := range walked {
+		if _, ok := tree[p]; !ok {
+			report(p, Missing)
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	return problems, nil
+}
@@ -0,0 +1,118 @@
+package ctree
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	t.Run("a good scan verifies", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		newTreeGen(7).build(t, where)
+		r := NewRoot(where)
+		dn, err := r.Run()
+		require.NoError(err)
+
+		problems, err := r.Verify(dn)
+		require.NoError(err)
+		assert.Empty(problems)
+	})
+
+	t.Run("an fs.FS scan verifies", func(t *testing.T) {
+		require := require.New(t)
+
+		r := NewRoot("home")
+		r.FS = tfs
+		dn, err := r.Run()
+		require.NoError(err)
+
+		problems, err := r.Verify(dn)
+		require.NoError(err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("changes are reported", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where)
+		dn, err := r.Run()
+		require.NoError(err)
+
+		home := path.Join(where, "home")
+		require.NoError(os.RemoveAll(path.Join(home, "ceswift", "bin")))
+		require.NoError(os.Mkdir(path.Join(home, "ceswift", "bin"), 0777))
+		require.NoError(os.Remove(path.Join(home, "wsfitzpa", ".cshrc")))
+		require.NoError(os.Mkdir(path.Join(home, "wsfitzpa", ".cshrc"), 0777))
+		writeFile(t, path.Join(home, "new"), "new")
+
+		problems, err := r.Verify(dn)
+		require.NoError(err)
+		assert.Equal([]Discrepancy{
+			{Path: path.Join(home, "ceswift", "bin", "worms"), Kind: Unexpected},
+			{Path: path.Join(home, "new"), Kind: Missing},
+			{Path: path.Join(home, "wsfitzpa", ".cshrc"), Kind: WrongType},
+		}, problems)
+	})
+
+	t.Run("what the walk left out is left out", func(t *testing.T) {
+		require := require.New(t)
+
+		where := t.TempDir()
+		newTreeGen(7).build(t, where)
+		r := NewRoot(where)
+		r.MaxDepth = 2
+		dn, err := r.Run()
+		require.NoError(err)
+		require.NotEmpty(dn.Errors())
+
+		problems, err := r.Verify(dn)
+		require.NoError(err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("an unclean root", func(t *testing.T) {
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where + "/")
+		r.MaxDepth = 2
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		problems, err := r.Verify(dn)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		r := NewRoot(".")
+		r.FS = loopFS{}
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		problems, err := r.Verify(dn)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("not after reaching a limit", func(t *testing.T) {
+		where := t.TempDir()
+		ttree.build(t, where)
+		r := NewRoot(where)
+		r.MaxNodes = 3
+		dn, err := r.Run()
+		require.NoError(t, err)
+
+		_, err = r.Verify(dn)
+		assert.ErrorIs(t, err, ErrLimitReached)
+	})
+}
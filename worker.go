@@ -0,0 +1,180 @@
+package ctree
+
+import (
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync/atomic"
+)
+
+// phase is the kind of work a worker is doing, for profiling
+type phase int
+
+const (
+	phaseReadDir phase = iota
+	phaseStat
+	phaseHash
+	numPhases
+)
+
+func (p phase) String() string {
+	switch p {
+	case phaseReadDir:
+		return "readdir"
+	case phaseStat:
+		return "stat"
+	case phaseHash:
+		return "hash"
+	}
+	return "unknown"
+}
+
+// worker is the state of one of the goroutines walking a Root. While
+// working, its goroutine carries pprof labels naming the root, the
+// worker, and the phase of work, so that CPU profiles of programs using
+// ctree can show where scan time goes.
+type worker struct {
+	r      *Root
+	id     int
+	labels [numPhases]context.Context
+	buf    []byte // for reading files to hash
+}
+
+func (r *Root) newWorker(id int) *worker {
+	w := &worker{r: r, id: id}
+
+	base := pprof.WithLabels(context.Background(), pprof.Labels(
+		"ctree.root", r.Path,
+		"ctree.worker", strconv.Itoa(id),
+	))
+	for p := phase(0); p < numPhases; p++ {
+		w.labels[p] = pprof.WithLabels(base, pprof.Labels("ctree.phase", p.String()))
+	}
+
+	return w
+}
+
+// enter labels the worker's goroutine as doing phase p, unless the
+// worker has no labels
+func (w *worker) enter(p phase) {
+	if w.labels[p] != nil {
+		pprof.SetGoroutineLabels(w.labels[p])
+	}
+}
+
+// lowerPriority runs the worker at idle priority if the Root asks for it
+func (w *worker) lowerPriority() {
+	if !w.r.LowPriority {
+		return
+	}
+
+	// never unlocked, so that the thread exits along with the worker,
+	// rather than going back to the runtime deprioritized
+	runtime.LockOSThread()
+	if err := lowerPriority(); err != nil {
+		atomic.AddInt64(&w.r.stats.PriorityErrors, 1)
+	}
+}
+
+// walkParallel walks the tree at dn with the Root's Threads of workers,
+// and HashThreads hashing large files
+func (r *Root) walkParallel(dn *DNode) {
+	for i := 0; i < r.hashThreads; i++ {
+		r.hashWG.Add(1)
+		go r.newWorker(r.Threads + i).hashLarge()
+	}
+	for i := 0; i < r.Threads; i++ {
+		r.wg.Add(1)
+		go r.newWorker(i).run()
+	}
+
+	r.work <- dn
+
+	r.wg.Wait()
+	close(r.hashWork)
+	r.hashWG.Wait()
+}
+
+// sequential reports whether the Root walks its tree by plain recursion,
+// without starting workers
+func (r *Root) sequential() bool {
+	return r.Threads == 1
+}
+
+// walkSequential walks the tree at dn by recursion, on the calling
+// goroutine. Its worker has no pprof labels, which would replace the
+// caller's. With LowPriority, it walks on a goroutine of its own instead,
+// whose thread may be deprioritized and then thrown away.
+func (r *Root) walkSequential(dn *DNode) {
+	w := &worker{r: r}
+	walk := func() {
+		r.Pool.acquireWorker(r.Priority)
+		dn.work(w)
+		r.Pool.releaseWorker()
+	}
+
+	if !r.LowPriority {
+		walk()
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		w.lowerPriority()
+		walk()
+	}()
+	r.wg.Wait()
+}
+
+// run takes directories from the work queue until the walk is done
+func (w *worker) run() {
+	r := w.r
+
+	defer r.wg.Done()
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	w.lowerPriority()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case dn := <-r.work:
+			r.Pool.acquireWorker(r.Priority)
+			dn.work(w)
+			r.Pool.releaseWorker()
+			dn.release()
+			remaining := atomic.AddInt32(&r.pending, -1)
+			if remaining < 1 {
+				close(r.stop)
+				return
+			}
+		}
+	}
+}
+
+// acquire takes a place in the node's budget, so it can be handed to
+// another worker. It returns false if the budget is used up.
+func (dn *DNode) acquire() bool {
+	if dn.budget == nil {
+		return true
+	}
+
+	select {
+	case dn.budget <- struct{}{}:
+		dn.slot = true
+		return true
+	default:
+		return false
+	}
+}
+
+// release gives back any place the node holds in its budget
+func (dn *DNode) release() {
+	if dn.slot {
+		dn.slot = false
+		<-dn.budget
+	}
+}
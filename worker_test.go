@@ -0,0 +1,26 @@
+package ctree
+
+import (
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	w := NewRoot("/some/where").newWorker(3)
+	for p, want := range map[phase]string{
+		phaseReadDir: "readdir",
+		phaseStat:    "stat",
+	} {
+		ctx := w.labels[p]
+		root, _ := pprof.Label(ctx, "ctree.root")
+		id, _ := pprof.Label(ctx, "ctree.worker")
+		got, _ := pprof.Label(ctx, "ctree.phase")
+		assert.Equal("/some/where", root)
+		assert.Equal("3", id)
+		assert.Equal(want, got)
+	}
+}
@@ -0,0 +1,114 @@
+package ctree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// ZipFunc is called by ZipWalk for each path found in either tree. rel is
+// relative to the roots of the trees, and either left or right will be nil
+// if the path is missing from that side. Returning fs.SkipDir for a
+// directory skips its contents; any other error stops the walk.
+type ZipFunc func(rel string, left, right Node) error
+
+// ZipWalk walks two trees in lockstep, calling fn with the nodes from
+// both sides for each path. Entries within a directory are visited in
+// name order, and a directory is visited before its contents.
+func ZipWalk(left, right *DNode, fn ZipFunc) error {
+	err := zipDir("", left, right, fn)
+	if errors.Is(err, fs.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+func zipDir(rel string, left, right *DNode, fn ZipFunc) error {
+	lnodes := sortedEntries(left)
+	rnodes := sortedEntries(right)
+
+	for len(lnodes) > 0 || len(rnodes) > 0 {
+		var l, r Node
+
+		switch {
+		case len(rnodes) == 0:
+			l, lnodes = lnodes[0], lnodes[1:]
+		case len(lnodes) == 0:
+			r, rnodes = rnodes[0], rnodes[1:]
+		default:
+			lname, rname := nodeName(lnodes[0]), nodeName(rnodes[0])
+			if lname <= rname {
+				l, lnodes = lnodes[0], lnodes[1:]
+			}
+			if rname <= lname {
+				r, rnodes = rnodes[0], rnodes[1:]
+			}
+		}
+
+		name := nodeName(l)
+		if l == nil {
+			name = nodeName(r)
+		}
+		crel := path.Join(rel, name)
+
+		err := fn(crel, l, r)
+		if errors.Is(err, fs.SkipDir) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		ldir, _ := l.(*DNode)
+		rdir, _ := r.(*DNode)
+		if ldir == nil && rdir == nil {
+			continue
+		}
+		if err := zipDir(crel, ldir, rdir, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedEntries returns the leaves and children of dn, sorted by name
+func sortedEntries(dn *DNode) []Node {
+	return sortEntries(dn, ByteOrder)
+}
+
+// sortEntries returns the leaves and children of dn, sorted by name in
+// order
+func sortEntries(dn *DNode, order NameOrder) []Node {
+	if order == nil {
+		order = ByteOrder
+	}
+	if dn == nil {
+		return nil
+	}
+
+	nodes := make([]Node, 0, len(dn.leaves)+len(dn.children))
+	for _, leaf := range dn.leaves {
+		nodes = append(nodes, leaf)
+	}
+	for _, child := range dn.children {
+		nodes = append(nodes, child)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return order(nodeName(nodes[i]), nodeName(nodes[j]))
+	})
+
+	return nodes
+}
+
+func nodeName(n Node) string {
+	switch n := n.(type) {
+	case *DNode:
+		return n.name
+	case *Leaf:
+		return n.name
+	}
+	return ""
+}
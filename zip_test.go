@@ -0,0 +1,75 @@
+package ctree
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipWalk(t *testing.T) {
+	a := scanCopy(t, nil)
+	b := scanCopy(t, func(where string) {
+		require.NoError(t, os.RemoveAll(path.Join(where, "ceswift")))
+		writeFile(t, path.Join(where, "extra"), "extra")
+	})
+
+	t.Run("pairs are matched by path", func(t *testing.T) {
+		assert := assert.New(t)
+
+		type pair struct{ left, right bool }
+		seen := []string{}
+		pairs := map[string]pair{}
+		err := ZipWalk(a, b, func(rel string, l, r Node) error {
+			seen = append(seen, rel)
+			pairs[rel] = pair{l != nil, r != nil}
+			return nil
+		})
+		assert.NoError(err)
+
+		assert.Equal([]string{
+			"ceswift",
+			"ceswift/.cshrc",
+			"ceswift/bin",
+			"ceswift/bin/worms",
+			"extra",
+			"wsfitzpa",
+			"wsfitzpa/.cshrc",
+			"wsfitzpa/bin",
+			"wsfitzpa/bin/zrun",
+		}, seen)
+		assert.Equal(pair{true, false}, pairs["ceswift/bin/worms"])
+		assert.Equal(pair{false, true}, pairs["extra"])
+		assert.Equal(pair{true, true}, pairs["wsfitzpa/bin/zrun"])
+	})
+
+	t.Run("SkipDir skips contents", func(t *testing.T) {
+		assert := assert.New(t)
+
+		seen := []string{}
+		err := ZipWalk(a, b, func(rel string, l, r Node) error {
+			seen = append(seen, rel)
+			if _, ok := l.(*DNode); ok {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		assert.NoError(err)
+		assert.Equal([]string{"ceswift", "extra", "wsfitzpa"}, seen)
+	})
+
+	t.Run("errors stop the walk", func(t *testing.T) {
+		assert := assert.New(t)
+
+		calls := 0
+		err := ZipWalk(a, b, func(string, Node, Node) error {
+			calls++
+			return fs.ErrInvalid
+		})
+		assert.ErrorIs(err, fs.ErrInvalid)
+		assert.Equal(1, calls)
+	})
+}